@@ -0,0 +1,576 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math"
+)
+
+// maxReservoir is the most main data a frame can borrow from earlier frames (main_data_begin is 9 bits).
+const maxReservoir = 511
+
+var errBadGranule = errors.New("mp3: granule overruns its data")
+
+// bandTable holds scalefactor band boundaries for long and short blocks.
+type bandTable struct {
+	long  [23]int
+	short [14]int
+}
+
+var bandTables = map[int]*bandTable{
+	44100: {
+		long:  [23]int{0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 52, 62, 74, 90, 110, 134, 162, 196, 238, 288, 342, 418, 576},
+		short: [14]int{0, 4, 8, 12, 16, 22, 30, 40, 52, 66, 84, 106, 136, 192},
+	},
+	48000: {
+		long:  [23]int{0, 4, 8, 12, 16, 20, 24, 30, 36, 42, 50, 60, 72, 88, 106, 128, 156, 190, 230, 276, 330, 384, 576},
+		short: [14]int{0, 4, 8, 12, 16, 22, 28, 38, 50, 64, 80, 100, 126, 192},
+	},
+	32000: {
+		long:  [23]int{0, 4, 8, 12, 16, 20, 24, 30, 36, 44, 54, 66, 82, 102, 126, 156, 194, 240, 296, 364, 448, 550, 576},
+		short: [14]int{0, 4, 8, 12, 16, 22, 30, 42, 58, 78, 104, 138, 180, 192},
+	},
+	22050: {
+		long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+		short: [14]int{0, 4, 8, 12, 18, 24, 32, 42, 56, 74, 100, 132, 174, 192},
+	},
+	24000: {
+		long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 114, 136, 162, 194, 232, 278, 332, 394, 464, 540, 576},
+		short: [14]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 136, 180, 192},
+	},
+	16000: {
+		long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+		short: [14]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	},
+	11025: {
+		long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+		short: [14]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	},
+	12000: {
+		long:  [23]int{0, 6, 12, 18, 24, 30, 36, 44, 54, 66, 80, 96, 116, 140, 168, 200, 238, 284, 336, 396, 464, 522, 576},
+		short: [14]int{0, 4, 8, 12, 18, 26, 36, 48, 62, 80, 104, 134, 174, 192},
+	},
+	8000: {
+		long:  [23]int{0, 12, 24, 36, 48, 60, 72, 88, 108, 132, 160, 192, 232, 280, 336, 400, 476, 566, 568, 570, 572, 574, 576},
+		short: [14]int{0, 8, 16, 24, 36, 52, 72, 96, 124, 160, 162, 164, 166, 192},
+	},
+}
+
+// slenTable maps MPEG-1 scalefac_compress to the bit widths of the two scalefactor groups.
+var slenTable = [16][2]int{
+	{0, 0}, {0, 1}, {0, 2}, {0, 3}, {3, 0}, {1, 1}, {1, 2}, {1, 3},
+	{2, 1}, {2, 2}, {2, 3}, {3, 1}, {3, 2}, {3, 3}, {4, 2}, {4, 3},
+}
+
+// lsfBandCounts is the number of scalefactors in each of the four MPEG-2 groups,
+// by slen table and block kind (long, short, mixed).
+var lsfBandCounts = [6][3][4]int{
+	{{6, 5, 5, 5}, {9, 9, 9, 9}, {6, 9, 9, 9}},
+	{{6, 5, 7, 3}, {9, 9, 12, 6}, {6, 9, 12, 6}},
+	{{11, 10, 0, 0}, {18, 18, 0, 0}, {15, 18, 0, 0}},
+	{{7, 7, 7, 0}, {12, 12, 12, 0}, {6, 15, 12, 0}},
+	{{6, 6, 6, 3}, {12, 9, 9, 6}, {6, 12, 9, 6}},
+	{{8, 8, 5, 0}, {15, 12, 9, 0}, {6, 18, 9, 0}},
+}
+
+var pretab = [22]int{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 3, 3, 3, 2, 0}
+
+// pow83 is |x|^(8/3): the square of the requantized magnitude |x|^(4/3).
+var pow83 = func() []float64 {
+	t := make([]float64, 8207) // 15 plus 13 linbits
+	for i := range t {
+		t[i] = math.Pow(float64(i), 8.0/3)
+	}
+	return t
+}()
+
+type granule struct {
+	part23Length     int
+	bigValues        int
+	globalGain       int
+	scalefacCompress int
+	windowSwitching  bool
+	blockType        int
+	mixed            bool
+	tableSelect      [3]int
+	subblockGain     [3]int
+	region0Count     int
+	region1Count     int
+	preflag          bool
+	scalefacScale    bool
+	count1TableB     bool
+}
+
+type sideInfo struct {
+	mainDataBegin int
+	scfsi         [2][4]bool
+	granules      [2][2]granule
+}
+
+// mp3Decoder carries the state that spans frames: the bit reservoir and the
+// scalefactors reused through scfsi.
+type mp3Decoder struct {
+	reservoir []byte
+	sfLong    [2][22]int
+	sfShort   [2][13][3]int
+	spectrum  [576]int
+}
+
+// mp3Envelope decodes Layer III audio down to its spectral coefficients and returns
+// one level per granule of 576 samples: the RMS of the requantized coefficients
+// (2^((global_gain-210)/4 - scalefactors) * |is|^(4/3)) over the granule's channels.
+//
+// It is an approximation of the PCM level, not a decoder. Stereo processing, alias
+// reduction, the IMDCT and the polyphase synthesis filterbank are skipped:
+//   - the filterbank is close to orthogonal, so the energy of a granule's coefficients
+//     follows the energy of the PCM it produces, up to a constant factor and smeared
+//     over the half-overlapping neighbouring granule;
+//   - mid/side stereo keeps the energy of the left/right pair;
+//   - intensity stereo bands of the right channel hold positions, not levels, and
+//     count as silence, so such passages come out slightly quieter.
+//
+// Only relative levels within a file matter for waveform peaks, so the values are
+// not comparable with the PCM levels of WAV envelopes.
+func mp3Envelope(r io.ReadSeeker, size int64) (*Envelope, error) {
+	tag, err := readID3v2(r)
+	if err != nil {
+		return nil, err
+	}
+	if tag != nil {
+		if _, err := r.Seek(tag.size, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	br := bufio.NewReaderSize(r, 64<<10)
+	d := &mp3Decoder{}
+	var env *Envelope
+	sampleRate := 0
+	locked := false
+	for {
+		hdr, err := br.Peek(4)
+		if err != nil {
+			break
+		}
+		h, ok := parseFrameHeader(hdr)
+		if !ok || h.layer != 3 || (sampleRate != 0 && h.sampleRate != sampleRate) {
+			locked = false
+			_, _ = br.Discard(1)
+			continue
+		}
+		n := h.frameLength()
+		frame, err := br.Peek(n)
+		if err != nil {
+			break // truncated last frame
+		}
+		if !locked {
+			// after a resync only trust a header that is followed by another one
+			if next, err := br.Peek(n + 4); err == nil {
+				if _, ok := parseFrameHeader(next[n:]); !ok {
+					_, _ = br.Discard(1)
+					continue
+				}
+			}
+		}
+		if env == nil {
+			sampleRate = h.sampleRate
+			env = &Envelope{Rate: float64(h.sampleRate) / 576}
+			if isInfoFrame(h, frame) {
+				_, _ = br.Discard(n)
+				locked = true
+				continue
+			}
+		}
+		env.Values = d.decodeFrame(h, frame, env.Values)
+		_, _ = br.Discard(n)
+		locked = true
+	}
+	if env == nil {
+		return nil, errNoFrame
+	}
+	return env, nil
+}
+
+// isInfoFrame reports whether the frame carries a Xing/Info/VBRI header instead of audio.
+func isInfoFrame(h frameHeader, frame []byte) bool {
+	xo := h.xingOffset()
+	if len(frame) >= xo+4 {
+		if id := frame[xo : xo+4]; bytes.Equal(id, []byte("Xing")) || bytes.Equal(id, []byte("Info")) {
+			return true
+		}
+	}
+	return len(frame) >= 40 && bytes.Equal(frame[36:40], []byte("VBRI"))
+}
+
+// decodeFrame appends one level per granule. Frames that cannot be decoded, e.g.
+// because the reservoir they refer to was lost, still advance time as silence.
+func (d *mp3Decoder) decodeFrame(h frameHeader, frame []byte, levels []float32) []float32 {
+	mpeg1 := h.version == 3
+	ngr := 1
+	if mpeg1 {
+		ngr = 2
+	}
+	first := len(levels)
+	for gr := 0; gr < ngr; gr++ {
+		levels = append(levels, 0)
+	}
+
+	bands := bandTables[h.sampleRate]
+	si, main, ok := parseSideInfo(h, frame)
+	if !ok || bands == nil {
+		d.reservoir = d.reservoir[:0]
+		return levels
+	}
+	if si.mainDataBegin > len(d.reservoir) {
+		d.keep(main)
+		return levels
+	}
+	data := make([]byte, 0, si.mainDataBegin+len(main))
+	data = append(data, d.reservoir[len(d.reservoir)-si.mainDataBegin:]...)
+	data = append(data, main...)
+	d.keep(main)
+
+	bits := &bitReader{data: data}
+	for gr := 0; gr < ngr; gr++ {
+		var energy float64
+		for ch := 0; ch < h.channels; ch++ {
+			g := &si.granules[gr][ch]
+			start := bits.pos
+			end := start + g.part23Length
+			if mpeg1 {
+				d.readScalefactors(bits, g, si.scfsi[ch], gr, ch)
+			} else {
+				intensityRight := ch == 1 && h.mode == 1 && h.modeExt&1 != 0
+				d.readScalefactorsLSF(bits, g, ch, intensityRight)
+			}
+			if err := d.readSpectrum(bits, g, bands, end); err == nil {
+				energy += d.energy(g, ch, bands, mpeg1)
+			}
+			bits.pos = end
+		}
+		levels[first+gr] = float32(math.Sqrt(energy / float64(576*h.channels)))
+	}
+	return levels
+}
+
+func (d *mp3Decoder) keep(main []byte) {
+	d.reservoir = append(d.reservoir, main...)
+	if n := len(d.reservoir); n > maxReservoir {
+		d.reservoir = append(d.reservoir[:0], d.reservoir[n-maxReservoir:]...)
+	}
+}
+
+// parseSideInfo reads the side information and returns it with the frame's own main data.
+func parseSideInfo(h frameHeader, frame []byte) (*sideInfo, []byte, bool) {
+	mpeg1 := h.version == 3
+	off := 4
+	if h.crc {
+		off += 2
+	}
+	var size int
+	switch {
+	case mpeg1 && h.channels == 1:
+		size = 17
+	case mpeg1:
+		size = 32
+	case h.channels == 1:
+		size = 9
+	default:
+		size = 17
+	}
+	if len(frame) < off+size {
+		return nil, nil, false
+	}
+
+	br := &bitReader{data: frame[off : off+size]}
+	si := &sideInfo{}
+	ngr := 1
+	if mpeg1 {
+		ngr = 2
+		si.mainDataBegin = br.bits(9)
+		if h.channels == 1 {
+			br.bits(5)
+		} else {
+			br.bits(3)
+		}
+		for ch := 0; ch < h.channels; ch++ {
+			for i := 0; i < 4; i++ {
+				si.scfsi[ch][i] = br.bit() == 1
+			}
+		}
+	} else {
+		si.mainDataBegin = br.bits(8)
+		if h.channels == 1 {
+			br.bits(1)
+		} else {
+			br.bits(2)
+		}
+	}
+
+	for gr := 0; gr < ngr; gr++ {
+		for ch := 0; ch < h.channels; ch++ {
+			g := &si.granules[gr][ch]
+			g.part23Length = br.bits(12)
+			g.bigValues = br.bits(9)
+			g.globalGain = br.bits(8)
+			if mpeg1 {
+				g.scalefacCompress = br.bits(4)
+			} else {
+				g.scalefacCompress = br.bits(9)
+			}
+			g.windowSwitching = br.bit() == 1
+			if g.windowSwitching {
+				g.blockType = br.bits(2)
+				g.mixed = br.bit() == 1
+				for i := 0; i < 2; i++ {
+					g.tableSelect[i] = br.bits(5)
+				}
+				for i := 0; i < 3; i++ {
+					g.subblockGain[i] = br.bits(3)
+				}
+			} else {
+				for i := 0; i < 3; i++ {
+					g.tableSelect[i] = br.bits(5)
+				}
+				g.region0Count = br.bits(4)
+				g.region1Count = br.bits(3)
+			}
+			if mpeg1 {
+				g.preflag = br.bit() == 1
+			}
+			g.scalefacScale = br.bit() == 1
+			g.count1TableB = br.bit() == 1
+		}
+	}
+	return si, frame[off+size:], true
+}
+
+// readScalefactors reads MPEG-1 scalefactors; with scfsi set, the second granule
+// reuses groups from the first.
+func (d *mp3Decoder) readScalefactors(br *bitReader, g *granule, scfsi [4]bool, gr, ch int) {
+	slen1, slen2 := slenTable[g.scalefacCompress][0], slenTable[g.scalefacCompress][1]
+	if g.blockType == 2 {
+		sfb := 0
+		if g.mixed {
+			for ; sfb < 8; sfb++ {
+				d.sfLong[ch][sfb] = br.bits(slen1)
+			}
+			sfb = 3
+		}
+		for ; sfb < 12; sfb++ {
+			n := slen1
+			if sfb >= 6 {
+				n = slen2
+			}
+			for w := 0; w < 3; w++ {
+				d.sfShort[ch][sfb][w] = br.bits(n)
+			}
+		}
+		return
+	}
+	groups := [5]int{0, 6, 11, 16, 21}
+	for i := 0; i < 4; i++ {
+		if gr == 1 && scfsi[i] {
+			continue
+		}
+		n := slen1
+		if i >= 2 {
+			n = slen2
+		}
+		for sfb := groups[i]; sfb < groups[i+1]; sfb++ {
+			d.sfLong[ch][sfb] = br.bits(n)
+		}
+	}
+}
+
+// readScalefactorsLSF reads MPEG-2/2.5 scalefactors, whose layout is coded in the
+// 9-bit scalefac_compress. The right channel of intensity stereo uses its own tables.
+func (d *mp3Decoder) readScalefactorsLSF(br *bitReader, g *granule, ch int, intensityRight bool) {
+	var slen [4]int
+	var table int
+	sfc := g.scalefacCompress
+	if intensityRight {
+		sfc >>= 1
+		switch {
+		case sfc < 180:
+			slen = [4]int{sfc / 36, sfc % 36 / 6, sfc % 36 % 6, 0}
+			table = 3
+		case sfc < 244:
+			sfc -= 180
+			slen = [4]int{sfc & 63 >> 4, sfc & 15 >> 2, sfc & 3, 0}
+			table = 4
+		default:
+			sfc -= 244
+			slen = [4]int{sfc / 3, sfc % 3, 0, 0}
+			table = 5
+		}
+	} else {
+		switch {
+		case sfc < 400:
+			slen = [4]int{(sfc >> 4) / 5, (sfc >> 4) % 5, sfc & 15 >> 2, sfc & 3}
+		case sfc < 500:
+			sfc -= 400
+			slen = [4]int{(sfc >> 2) / 5, (sfc >> 2) % 5, sfc & 3, 0}
+			table = 1
+		default:
+			sfc -= 500
+			slen = [4]int{sfc / 3, sfc % 3, 0, 0}
+			table = 2
+			g.preflag = true
+		}
+	}
+
+	kind := 0
+	if g.blockType == 2 {
+		kind = 1
+		if g.mixed {
+			kind = 2
+		}
+	}
+	var vals [39]int
+	n := 0
+	for i, count := range lsfBandCounts[table][kind] {
+		for j := 0; j < count; j++ {
+			vals[n] = br.bits(slen[i])
+			n++
+		}
+	}
+
+	switch kind {
+	case 0:
+		for i := 0; i < n && i < 21; i++ {
+			d.sfLong[ch][i] = vals[i]
+		}
+	case 1:
+		for i := 0; i < n && i < 36; i++ {
+			d.sfShort[ch][i/3][i%3] = vals[i]
+		}
+	case 2:
+		for i := 0; i < 6; i++ {
+			d.sfLong[ch][i] = vals[i]
+		}
+		for i := 6; i < n && i < 33; i++ {
+			d.sfShort[ch][3+(i-6)/3][(i-6)%3] = vals[i]
+		}
+	}
+}
+
+// readSpectrum Huffman-decodes the quantized coefficients of one granule; end is
+// the bit position where its part2_3 data stops.
+func (d *mp3Decoder) readSpectrum(br *bitReader, g *granule, bands *bandTable, end int) error {
+	is := &d.spectrum
+	*is = [576]int{}
+
+	bigEnd := g.bigValues * 2
+	if bigEnd > 576 {
+		bigEnd = 576
+	}
+	var region1, region2 int
+	if g.windowSwitching {
+		if g.blockType == 2 && !g.mixed {
+			region1 = bands.short[3] * 3
+		} else {
+			region1 = bands.long[8]
+		}
+		region2 = 576
+	} else {
+		region1 = bands.long[min(g.region0Count+1, 22)]
+		region2 = bands.long[min(g.region0Count+g.region1Count+2, 22)]
+	}
+
+	i := 0
+	for ; i < bigEnd; i += 2 {
+		sel := g.tableSelect[0]
+		if i >= region2 {
+			sel = g.tableSelect[2]
+		} else if i >= region1 {
+			sel = g.tableSelect[1]
+		}
+		x, y, err := decodePair(br, sel)
+		if err != nil {
+			return err
+		}
+		is[i], is[i+1] = x, y
+	}
+	if br.pos > end {
+		return errBadGranule
+	}
+	for i+4 <= 576 && br.pos < end {
+		q, err := decodeQuad(br, g.count1TableB)
+		if err != nil {
+			return err
+		}
+		if br.pos > end {
+			break // the last quadruple ran into the next granule's bits
+		}
+		copy(is[i:i+4], q[:])
+		i += 4
+	}
+	return nil
+}
+
+// energy requantizes the spectrum and returns the sum of squared coefficients.
+func (d *mp3Decoder) energy(g *granule, ch int, bands *bandTable, mpeg1 bool) float64 {
+	mult := 0.5
+	if g.scalefacScale {
+		mult = 1
+	}
+	gain := 0.25 * float64(g.globalGain-210)
+	var total float64
+	i := 0
+	if g.blockType != 2 || g.mixed {
+		longBands := 22
+		if g.blockType == 2 {
+			longBands = 6
+			if mpeg1 {
+				longBands = 8
+			}
+		}
+		for sfb := 0; sfb < longBands; sfb++ {
+			sf := 0
+			if sfb < 21 {
+				sf = d.sfLong[ch][sfb]
+				if g.preflag {
+					sf += pretab[sfb]
+				}
+			}
+			scale := math.Exp2(2 * (gain - mult*float64(sf)))
+			total += bandEnergy(d.spectrum[bands.long[sfb]:bands.long[sfb+1]]) * scale
+		}
+		i = bands.long[longBands]
+	}
+	if g.blockType == 2 {
+		sfb := 0
+		if g.mixed {
+			sfb = 3
+		}
+		for ; sfb < 13; sfb++ {
+			width := bands.short[sfb+1] - bands.short[sfb]
+			for w := 0; w < 3; w++ {
+				sf := 0
+				if sfb < 12 {
+					sf = d.sfShort[ch][sfb][w]
+				}
+				scale := math.Exp2(2 * (gain - 2*float64(g.subblockGain[w]) - mult*float64(sf)))
+				total += bandEnergy(d.spectrum[i:i+width]) * scale
+				i += width
+			}
+		}
+	}
+	return total
+}
+
+func bandEnergy(vals []int) float64 {
+	var e float64
+	for _, v := range vals {
+		if v < 0 {
+			v = -v
+		}
+		if v < len(pow83) {
+			e += pow83[v]
+		}
+	}
+	return e
+}
@@ -0,0 +1,289 @@
+package audio
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+// bitWriter builds big-endian bit strings, the inverse of bitReader.
+type bitWriter struct {
+	data []byte
+	n    int // bits written
+}
+
+func (w *bitWriter) put(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if w.n%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		if v>>i&1 == 1 {
+			w.data[len(w.data)-1] |= 0x80 >> (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+func TestParseFrameHeader(t *testing.T) {
+	cases := []struct {
+		name   string
+		header []byte
+		want   frameHeader
+		length int
+	}{
+		{"MPEG-1 128k 44.1kHz joint stereo", []byte{0xFF, 0xFB, 0x90, 0x64},
+			frameHeader{version: 3, layer: 3, bitrate: 128, sampleRate: 44100, channels: 2, mode: 1, modeExt: 2}, 417},
+		{"MPEG-1 128k 44.1kHz padded", []byte{0xFF, 0xFB, 0x92, 0x64},
+			frameHeader{version: 3, layer: 3, bitrate: 128, sampleRate: 44100, padding: 1, channels: 2, mode: 1, modeExt: 2}, 418},
+		{"MPEG-1 320k 48kHz stereo with CRC", []byte{0xFF, 0xFA, 0xE4, 0x00},
+			frameHeader{version: 3, layer: 3, bitrate: 320, sampleRate: 48000, channels: 2, crc: true}, 960},
+		{"MPEG-2 32k 16kHz mono", []byte{0xFF, 0xF3, 0x48, 0xC4},
+			frameHeader{version: 2, layer: 3, bitrate: 32, sampleRate: 16000, channels: 1, mode: 3}, 144},
+		{"MPEG-2.5 8k 8kHz mono", []byte{0xFF, 0xE3, 0x18, 0xC0},
+			frameHeader{version: 0, layer: 3, bitrate: 8, sampleRate: 8000, channels: 1, mode: 3}, 72},
+		{"MPEG-1 Layer II 192k 48kHz", []byte{0xFF, 0xFD, 0xA4, 0x00},
+			frameHeader{version: 3, layer: 2, bitrate: 192, sampleRate: 48000, channels: 2}, 576},
+	}
+	for _, c := range cases {
+		h, ok := parseFrameHeader(c.header)
+		if !ok {
+			t.Errorf("%s: not parsed", c.name)
+			continue
+		}
+		if h != c.want {
+			t.Errorf("%s: %+v, want %+v", c.name, h, c.want)
+		}
+		if n := h.frameLength(); n != c.length {
+			t.Errorf("%s: frame length %d, want %d", c.name, n, c.length)
+		}
+	}
+
+	for name, b := range map[string][]byte{
+		"no sync":          {0xFF, 0x1B, 0x90, 0x64},
+		"reserved version": {0xFF, 0xEB, 0x90, 0x64},
+		"reserved layer":   {0xFF, 0xF9, 0x90, 0x64},
+		"free bitrate":     {0xFF, 0xFB, 0x00, 0x64},
+		"bad bitrate":      {0xFF, 0xFB, 0xF0, 0x64},
+		"reserved rate":    {0xFF, 0xFB, 0x9C, 0x64},
+		"short":            {0xFF, 0xFB, 0x90},
+	} {
+		if _, ok := parseFrameHeader(b); ok {
+			t.Errorf("%s: % X parsed", name, b)
+		}
+	}
+}
+
+// Table 1 and count1 table A as printed in ISO 11172-3, Annex B.
+func TestHuffmanGolden(t *testing.T) {
+	w := &bitWriter{}
+	w.put(0b1, 1)    // (0, 0)
+	w.put(0b001, 3)  // (0, 1)
+	w.put(0b1, 1)    //   y negative
+	w.put(0b01, 2)   // (1, 0)
+	w.put(0b0, 1)    //   x positive
+	w.put(0b000, 3)  // (1, 1)
+	w.put(0b10, 2)   //   x negative, y positive
+	w.put(0b0101, 4) // quad A 0001
+	w.put(0b0, 1)    //   y positive
+	w.put(0b1, 1)    // quad A 0000
+	w.put(0b0000, 4) // quad B 1111 (codes are inverted)
+	w.put(0b0101, 4) //   signs +, -, +, -
+	br := &bitReader{data: w.data}
+
+	want := [][2]int{{0, 0}, {0, -1}, {1, 0}, {-1, 1}}
+	for i, p := range want {
+		x, y, err := decodePair(br, 1)
+		if err != nil || x != p[0] || y != p[1] {
+			t.Fatalf("pair %d = (%d, %d) %v, want %v", i, x, y, err, p)
+		}
+	}
+	for i, want := range [][4]int{{0, 0, 0, 1}, {0, 0, 0, 0}} {
+		if q, err := decodeQuad(br, false); err != nil || q != want {
+			t.Fatalf("quad A %d = %v %v, want %v", i, q, err, want)
+		}
+	}
+	if q, err := decodeQuad(br, true); err != nil || q != [4]int{1, -1, 1, -1} {
+		t.Fatalf("quad B = %v %v", q, err)
+	}
+	if br.pos != w.n {
+		t.Errorf("read %d bits, wrote %d", br.pos, w.n)
+	}
+}
+
+// Every code of every table decodes to its own symbol, and linbits extend 15.
+func TestHuffmanTables(t *testing.T) {
+	for sel, h := range bigValueCodes {
+		if len(h.codes) != len(h.lens) || len(h.codes) != h.xlen*h.xlen {
+			t.Errorf("table %d: %d codes, %d lengths for xlen %d", sel, len(h.codes), len(h.lens), h.xlen)
+			continue
+		}
+		tree := buildTree(h)
+		for sym := range h.codes {
+			w := &bitWriter{}
+			w.put(int(h.codes[sym]), int(h.lens[sym]))
+			if got, err := tree.decode(&bitReader{data: w.data}); err != nil || got != sym {
+				t.Errorf("table %d: code for %d decodes to %d (%v)", sel, sym, got, err)
+			}
+		}
+	}
+
+	// table 17 is tree 16 with 2 linbits: (15+2, 15+3), both negative
+	h := bigValueCodes[16]
+	sym := 15*16 + 15
+	w := &bitWriter{}
+	w.put(int(h.codes[sym]), int(h.lens[sym]))
+	w.put(2, 2)
+	w.put(1, 1)
+	w.put(3, 2)
+	w.put(1, 1)
+	x, y, err := decodePair(&bitReader{data: w.data}, 17)
+	if err != nil || x != -17 || y != -18 {
+		t.Errorf("linbits pair = (%d, %d) %v, want (-17, -18)", x, y, err)
+	}
+}
+
+// mono32k is an MPEG-1 Layer III header: 32 kbit/s, 32 kHz, mono. Its frames are
+// 144 bytes with 17 bytes of side information.
+var mono32k = []byte{0xFF, 0xFB, 0x18, 0xC0}
+
+type testGranule struct {
+	gain   int
+	coeffs []int // big_values pairs coded with table 1, values -1..1
+}
+
+// testFrame builds a frame whose granules hold the given coefficients and no
+// scalefactors (scalefac_compress 0), with the main data in the frame itself.
+func testFrame(t *testing.T, granules [2]testGranule) []byte {
+	t.Helper()
+	codes := map[[2]int]struct{ code, len int }{{0, 0}: {1, 1}, {0, 1}: {1, 3}, {1, 0}: {1, 2}, {1, 1}: {0, 3}}
+	var main bitWriter
+	var lengths [2]int
+	for gr, g := range granules {
+		start := main.n
+		for i := 0; i < len(g.coeffs); i += 2 {
+			x, y := g.coeffs[i], g.coeffs[i+1]
+			c := codes[[2]int{x * x, y * y}]
+			main.put(c.code, c.len)
+			for _, v := range []int{x, y} {
+				if v < 0 {
+					main.put(1, 1)
+				} else if v > 0 {
+					main.put(0, 1)
+				}
+			}
+		}
+		lengths[gr] = main.n - start
+	}
+
+	var side bitWriter
+	side.put(0, 9) // main_data_begin
+	side.put(0, 5) // private bits
+	side.put(0, 4) // scfsi
+	for gr, g := range granules {
+		side.put(lengths[gr], 12)
+		side.put(len(g.coeffs)/2, 9)
+		side.put(g.gain, 8)
+		side.put(0, 4) // scalefac_compress
+		side.put(0, 1) // window switching
+		side.put(1, 5) // table_select: table 1 for all regions
+		side.put(1, 5)
+		side.put(1, 5)
+		side.put(0, 4) // region0_count
+		side.put(0, 3) // region1_count
+		side.put(0, 3) // preflag, scalefac_scale, count1table_select
+	}
+	if len(side.data) != 17 {
+		t.Fatalf("side info is %d bytes", len(side.data))
+	}
+
+	frame := make([]byte, 144)
+	copy(frame, mono32k)
+	copy(frame[4:], side.data)
+	copy(frame[21:], main.data)
+	return frame
+}
+
+// The levels of hand-built frames follow the requantization of ISO 11172-3: without
+// scalefactors a coefficient is sign(is) * |is|^(4/3) * 2^((global_gain-210)/4).
+func TestMP3EnvelopeGolden(t *testing.T) {
+	silent := testGranule{gain: 210}
+	var stream []byte
+	// a small ID3v2 tag first, which the decoder skips
+	stream = append(stream, 'I', 'D', '3', 3, 0, 0, 0, 0, 0, 10)
+	stream = append(stream, make([]byte, 10)...)
+	stream = append(stream, testFrame(t, [2]testGranule{{gain: 210, coeffs: []int{1, 0}}, silent})...)
+	stream = append(stream, testFrame(t, [2]testGranule{{gain: 214, coeffs: []int{-1, 1}}, {gain: 210, coeffs: []int{0, 1, 1, 0}}})...)
+	stream = append(stream, testFrame(t, [2]testGranule{silent, silent})...)
+
+	env, err := DecodeEnvelope(bytes.NewReader(stream), int64(len(stream)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.Rate != 32000.0/576 {
+		t.Errorf("rate = %g, want %g", env.Rate, 32000.0/576)
+	}
+	want := []float64{
+		math.Sqrt(1.0 / 576),     // one coefficient of 1
+		0,                        // silent granule
+		math.Sqrt(2 * 4.0 / 576), // two of 1 at gain +4: each 2^1, energy 4
+		math.Sqrt(2.0 / 576),     // two of 1
+		0, 0,
+	}
+	if len(env.Values) != len(want) {
+		t.Fatalf("%d levels, want %d: %v", len(env.Values), len(want), env.Values)
+	}
+	for i := range want {
+		if math.Abs(float64(env.Values[i])-want[i]) > 1e-6 {
+			t.Errorf("level %d = %g, want %g", i, env.Values[i], want[i])
+		}
+	}
+	// bins of two granules: 1 against sqrt(8) of the loudest
+	if got := env.Peaks(3); got[0] != 90 || got[1] != 255 || got[2] != 0 {
+		t.Errorf("peaks = %v, want [90 255 0]", got)
+	}
+}
+
+// A frame whose main data begins in a reservoir that was never seen still takes
+// its time, as silence.
+func TestMP3EnvelopeLostReservoir(t *testing.T) {
+	frame := testFrame(t, [2]testGranule{{gain: 210, coeffs: []int{1, 1}}, {gain: 210}})
+	frame[4] = 0x40 // main_data_begin 128
+	stream := append(append([]byte{}, frame...), testFrame(t, [2]testGranule{{gain: 210, coeffs: []int{1, 0}}, {gain: 210}})...)
+
+	env, err := DecodeEnvelope(bytes.NewReader(stream), int64(len(stream)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env.Values) != 4 || env.Values[0] != 0 || env.Values[2] == 0 {
+		t.Errorf("levels = %v, want silence and then the second frame", env.Values)
+	}
+}
+
+func TestPeaks(t *testing.T) {
+	env := &Envelope{Rate: 10, Values: []float32{0, 0.1, 0.5, 0.2, 1, 0.25, 0, 0}}
+	cases := map[int][]int{
+		4:  {26, 128, 255, 0},
+		2:  {128, 255},
+		1:  {255},
+		20: {0, 26, 128, 51, 255, 64, 0, 0}, // no more bins than values
+	}
+	for bins, want := range cases {
+		got := env.Peaks(bins)
+		if len(got) != len(want) {
+			t.Errorf("Peaks(%d) = %v, want %v", bins, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Peaks(%d) = %v, want %v", bins, got, want)
+				break
+			}
+		}
+	}
+	if got := (&Envelope{Values: []float32{0, 0}}).Peaks(2); got[0] != 0 || got[1] != 0 {
+		t.Errorf("silence peaks = %v", got)
+	}
+	if d := env.Duration(); d != 0.8 {
+		t.Errorf("duration = %g, want 0.8", d)
+	}
+}
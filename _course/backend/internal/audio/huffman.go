@@ -0,0 +1,147 @@
+package audio
+
+import "errors"
+
+var errBadHuffman = errors.New("mp3: invalid huffman code")
+
+// bitReader reads big-endian bits; reads past the end yield zeros.
+type bitReader struct {
+	data []byte
+	pos  int // in bits
+}
+
+func (b *bitReader) bit() int {
+	i := b.pos >> 3
+	if i >= len(b.data) {
+		b.pos++
+		return 0
+	}
+	v := int(b.data[i]>>(7-b.pos&7)) & 1
+	b.pos++
+	return v
+}
+
+func (b *bitReader) bits(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		v = v<<1 | b.bit()
+	}
+	return v
+}
+
+// huffTree is a binary decoding tree. Entry i holds the children of node i:
+// positive values are node indexes, negative values are leaves storing ^symbol
+// and zero marks a code that does not exist.
+type huffTree [][2]int32
+
+func buildTree(h huffCode) huffTree {
+	t := huffTree{{}}
+	for sym, code := range h.codes {
+		n := int32(0)
+		for i := int(h.lens[sym]) - 1; i >= 0; i-- {
+			bit := (code >> i) & 1
+			if i == 0 {
+				t[n][bit] = ^int32(sym)
+				break
+			}
+			next := t[n][bit]
+			if next == 0 {
+				t = append(t, [2]int32{})
+				next = int32(len(t) - 1)
+				t[n][bit] = next
+			}
+			n = next
+		}
+	}
+	return t
+}
+
+func (t huffTree) decode(br *bitReader) (int, error) {
+	n := int32(0)
+	for {
+		v := t[n][br.bit()]
+		if v < 0 {
+			return int(^v), nil
+		}
+		if v == 0 {
+			return 0, errBadHuffman
+		}
+		n = v
+	}
+}
+
+type huffTable struct {
+	tree    huffTree
+	xlen    int
+	linbits int
+}
+
+// huffTables is indexed by table_select; tables 0, 4 and 14 have no codes.
+var huffTables = func() [32]huffTable {
+	var out [32]huffTable
+	trees := make(map[int]huffTree, len(bigValueCodes))
+	for n, h := range bigValueCodes {
+		trees[n] = buildTree(h)
+	}
+	for n, h := range bigValueCodes {
+		out[n] = huffTable{tree: trees[n], xlen: h.xlen}
+	}
+	for i, lb := range []int{1, 2, 3, 4, 6, 8, 10, 13} {
+		out[16+i] = huffTable{tree: trees[16], xlen: 16, linbits: lb}
+	}
+	for i, lb := range []int{4, 5, 6, 7, 8, 9, 11, 13} {
+		out[24+i] = huffTable{tree: trees[24], xlen: 16, linbits: lb}
+	}
+	return out
+}()
+
+var quadTreeA = buildTree(quadCodeA)
+
+// decodePair reads one big_values pair with its linbits and sign bits.
+func decodePair(br *bitReader, sel int) (int, int, error) {
+	if sel == 0 {
+		return 0, 0, nil
+	}
+	t := huffTables[sel]
+	if t.tree == nil {
+		return 0, 0, errBadHuffman
+	}
+	sym, err := t.tree.decode(br)
+	if err != nil {
+		return 0, 0, err
+	}
+	x, y := sym/t.xlen, sym%t.xlen
+	if t.linbits > 0 && x == 15 {
+		x += br.bits(t.linbits)
+	}
+	if x != 0 && br.bit() == 1 {
+		x = -x
+	}
+	if t.linbits > 0 && y == 15 {
+		y += br.bits(t.linbits)
+	}
+	if y != 0 && br.bit() == 1 {
+		y = -y
+	}
+	return x, y, nil
+}
+
+// decodeQuad reads one count1 quadruple (v, w, x, y) with its sign bits.
+func decodeQuad(br *bitReader, tableB bool) ([4]int, error) {
+	var sym int
+	if tableB {
+		sym = 15 - br.bits(4)
+	} else {
+		var err error
+		if sym, err = quadTreeA.decode(br); err != nil {
+			return [4]int{}, err
+		}
+	}
+	q := [4]int{sym >> 3 & 1, sym >> 2 & 1, sym >> 1 & 1, sym & 1}
+	for i := range q {
+		if q[i] != 0 && br.bit() == 1 {
+			q[i] = -1
+		}
+	}
+	return q, nil
+}
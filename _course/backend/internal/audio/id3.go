@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxTagSize bounds how much of an ID3v2 tag is read; larger tags are skipped unread.
+const maxTagSize = 16 << 20
+
+type id3Tag struct {
+	size     int64 // total bytes including header, i.e. where audio starts
+	title    string
+	artist   string
+	comment  string
+	subtitle string
+}
+
+// readID3v2 parses an ID3v2.2/2.3/2.4 tag at the current position. It returns nil when there is none.
+func readID3v2(r io.ReadSeeker) (*id3Tag, error) {
+	hdr := make([]byte, 10)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hdr[0:3], []byte("ID3")) {
+		_, err := r.Seek(-10, io.SeekCurrent)
+		return nil, err
+	}
+	version := hdr[3]
+	flags := hdr[5]
+	bodySize := int64(syncsafe(hdr[6:10]))
+	tag := &id3Tag{size: 10 + bodySize}
+	if flags&0x10 != 0 { // footer present
+		tag.size += 10
+	}
+	if bodySize > maxTagSize {
+		_, err := r.Seek(tag.size-10, io.SeekCurrent)
+		return tag, err
+	}
+
+	body := make([]byte, bodySize)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	if flags&0x80 != 0 && version < 4 { // tag-level unsynchronisation
+		body = bytes.ReplaceAll(body, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+	if flags&0x40 != 0 && version >= 3 && len(body) >= 4 { // extended header
+		ext := int(binary.BigEndian.Uint32(body[0:4]))
+		if version == 4 {
+			ext = syncsafe(body[0:4])
+		} else {
+			ext += 4
+		}
+		if ext < len(body) {
+			body = body[ext:]
+		}
+	}
+
+	idLen, hdrLen := 4, 10
+	if version == 2 {
+		idLen, hdrLen = 3, 6
+	}
+	for len(body) >= hdrLen && body[0] != 0 {
+		id := string(body[:idLen])
+		var size int
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case 4:
+			size = syncsafe(body[4:8])
+		default:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		if size <= 0 || hdrLen+size > len(body) {
+			break
+		}
+		data := body[hdrLen : hdrLen+size]
+		switch id {
+		case "TIT2", "TT2":
+			tag.title = decodeText(data)
+		case "TPE1", "TP1":
+			tag.artist = decodeText(data)
+		case "TIT3", "TT3":
+			tag.subtitle = decodeText(data)
+		case "COMM", "COM":
+			if tag.comment == "" {
+				tag.comment = decodeComment(data)
+			}
+		}
+		body = body[hdrLen+size:]
+	}
+	return tag, nil
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// decodeText decodes a text frame: one encoding byte followed by the string.
+func decodeText(data []byte) string {
+	if len(data) < 1 {
+		return ""
+	}
+	return cleanText(decodeString(data[0], data[1:]))
+}
+
+// decodeComment handles COMM: encoding, 3-byte language, short description, then the text.
+func decodeComment(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	enc := data[0]
+	rest := data[4:]
+	term := []byte{0}
+	if enc == 1 || enc == 2 {
+		term = []byte{0, 0}
+	}
+	for i := 0; i+len(term) <= len(rest); i += len(term) {
+		if bytes.Equal(rest[i:i+len(term)], term) {
+			return cleanText(decodeString(enc, rest[i+len(term):]))
+		}
+	}
+	return cleanText(decodeString(enc, rest))
+}
+
+func decodeString(enc byte, b []byte) string {
+	switch enc {
+	case 1, 2: // UTF-16 with BOM, UTF-16BE
+		bigEndian := enc == 2
+		if len(b) >= 2 {
+			if b[0] == 0xFF && b[1] == 0xFE {
+				bigEndian, b = false, b[2:]
+			} else if b[0] == 0xFE && b[1] == 0xFF {
+				bigEndian, b = true, b[2:]
+			}
+		}
+		u := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			if bigEndian {
+				u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+			} else {
+				u = append(u, uint16(b[i+1])<<8|uint16(b[i]))
+			}
+		}
+		return string(utf16.Decode(u))
+	case 3:
+		return string(b)
+	default: // ISO-8859-1
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c)
+		}
+		return string(r)
+	}
+}
+
+func cleanText(s string) string {
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
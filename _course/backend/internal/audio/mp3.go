@@ -0,0 +1,212 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// scanLimit is how far past the tag we look for the first MPEG frame.
+const scanLimit = 256 << 10
+
+var errNoFrame = errors.New("no mpeg audio frame found")
+
+var bitratesKbps = [2][3][16]int{
+	{ // MPEG-1: Layer I, II, III
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+	{ // MPEG-2 / 2.5: Layer I, II, III
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+}
+
+var sampleRates = [4][3]int{
+	{11025, 12000, 8000},  // MPEG-2.5
+	{0, 0, 0},             // reserved
+	{22050, 24000, 16000}, // MPEG-2
+	{44100, 48000, 32000}, // MPEG-1
+}
+
+// frameHeader is a decoded 4-byte MPEG audio frame header.
+type frameHeader struct {
+	version    int // 3 = MPEG-1, 2 = MPEG-2, 0 = MPEG-2.5
+	layer      int // 1, 2 or 3
+	bitrate    int // kbit/s
+	sampleRate int
+	padding    int
+	channels   int
+	crc        bool // a 16-bit CRC follows the header
+	mode       int  // 0 stereo, 1 joint stereo, 2 dual channel, 3 mono
+	modeExt    int
+}
+
+func parseFrameHeader(b []byte) (frameHeader, bool) {
+	var h frameHeader
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return h, false
+	}
+	h.version = int(b[1]>>3) & 3
+	layerBits := int(b[1]>>1) & 3
+	brIdx := int(b[2] >> 4)
+	srIdx := int(b[2]>>2) & 3
+	if h.version == 1 || layerBits == 0 || brIdx == 0 || brIdx == 15 || srIdx == 3 {
+		return h, false
+	}
+	h.layer = 4 - layerBits
+	row := 0
+	if h.version != 3 {
+		row = 1
+	}
+	h.bitrate = bitratesKbps[row][h.layer-1][brIdx]
+	h.sampleRate = sampleRates[h.version][srIdx]
+	h.padding = int(b[2]>>1) & 1
+	h.crc = b[1]&1 == 0
+	h.mode = int(b[3] >> 6)
+	h.modeExt = int(b[3]>>4) & 3
+	h.channels = 2
+	if h.mode == 3 {
+		h.channels = 1
+	}
+	return h, true
+}
+
+func (h frameHeader) samplesPerFrame() int {
+	switch {
+	case h.layer == 1:
+		return 384
+	case h.layer == 3 && h.version != 3:
+		return 576
+	default:
+		return 1152
+	}
+}
+
+func (h frameHeader) frameLength() int {
+	if h.layer == 1 {
+		return (12*h.bitrate*1000/h.sampleRate + h.padding) * 4
+	}
+	return h.samplesPerFrame()/8*h.bitrate*1000/h.sampleRate + h.padding
+}
+
+// xingOffset is where a Xing/Info header sits relative to the frame start.
+func (h frameHeader) xingOffset() int {
+	if h.version == 3 {
+		if h.channels == 1 {
+			return 4 + 17
+		}
+		return 4 + 32
+	}
+	if h.channels == 1 {
+		return 4 + 9
+	}
+	return 4 + 17
+}
+
+// findFrame locates the first frame header in buf that is followed by another valid header,
+// which filters out false syncs inside junk data.
+func findFrame(buf []byte) (int, frameHeader, bool) {
+	for i := 0; i+4 <= len(buf); i++ {
+		h, ok := parseFrameHeader(buf[i:])
+		if !ok {
+			continue
+		}
+		next := i + h.frameLength()
+		if next+4 <= len(buf) {
+			if _, ok := parseFrameHeader(buf[next:]); !ok {
+				continue
+			}
+		}
+		return i, h, true
+	}
+	return 0, frameHeader{}, false
+}
+
+func probeMP3(r io.ReadSeeker, size int64) (*ProbeResult, error) {
+	res := &ProbeResult{Format: "mp3"}
+
+	tag, err := readID3v2(r)
+	if err != nil {
+		return nil, err
+	}
+	var audioStart int64
+	if tag != nil {
+		audioStart = tag.size
+		res.Title = tag.title
+		res.Artist = tag.artist
+		res.Comment = tag.comment
+		if res.Comment == "" {
+			res.Comment = tag.subtitle
+		}
+	}
+	if _, err := r.Seek(audioStart, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, scanLimit)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	buf = buf[:n]
+	off, h, ok := findFrame(buf)
+	if !ok {
+		return nil, errNoFrame
+	}
+	audioStart += int64(off)
+	frame := buf[off:]
+
+	res.SampleRate = h.sampleRate
+	res.Channels = h.channels
+	res.Bitrate = h.bitrate
+
+	audioEnd := size
+	if hasID3v1(r, size) {
+		audioEnd -= 128
+	}
+	audioBytes := audioEnd - audioStart
+
+	var frames int64
+	if xo := h.xingOffset(); len(frame) >= xo+12 {
+		id := frame[xo : xo+4]
+		if bytes.Equal(id, []byte("Xing")) || bytes.Equal(id, []byte("Info")) {
+			flags := binary.BigEndian.Uint32(frame[xo+4 : xo+8])
+			if flags&1 != 0 {
+				frames = int64(binary.BigEndian.Uint32(frame[xo+8 : xo+12]))
+			}
+			res.VBR = bytes.Equal(id, []byte("Xing"))
+		}
+	}
+	if frames == 0 && len(frame) >= 4+32+18 && bytes.Equal(frame[36:40], []byte("VBRI")) {
+		frames = int64(binary.BigEndian.Uint32(frame[36+14 : 36+18]))
+		res.VBR = true
+	}
+
+	if frames > 0 {
+		res.Duration = float64(frames) * float64(h.samplesPerFrame()) / float64(h.sampleRate)
+		if res.Duration > 0 {
+			res.Bitrate = int(float64(audioBytes) * 8 / res.Duration / 1000)
+		}
+	} else if h.bitrate > 0 {
+		res.Duration = float64(audioBytes) * 8 / float64(h.bitrate*1000)
+	}
+	return res, nil
+}
+
+func hasID3v1(r io.ReadSeeker, size int64) bool {
+	if size < 128 {
+		return false
+	}
+	if _, err := r.Seek(size-128, io.SeekStart); err != nil {
+		return false
+	}
+	tag := make([]byte, 3)
+	if _, err := io.ReadFull(r, tag); err != nil {
+		return false
+	}
+	return bytes.Equal(tag, []byte("TAG"))
+}
@@ -0,0 +1,205 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errNoMovieHeader = errors.New("mp4: moov/mvhd not found")
+
+// maxMoovSize bounds the metadata box read into memory.
+const maxMoovSize = 32 << 20
+
+type box struct {
+	typ  string
+	data []byte
+}
+
+// probeMP4 reads the top-level boxes, loads moov and takes duration from mvhd and
+// tags from the iTunes-style udta/meta/ilst list.
+func probeMP4(r io.ReadSeeker, size int64) (*ProbeResult, error) {
+	var moov []byte
+	pos := int64(0)
+	hdr := make([]byte, 16)
+	for pos+8 <= size {
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, hdr[:8]); err != nil {
+			break
+		}
+		boxSize := int64(binary.BigEndian.Uint32(hdr[0:4]))
+		typ := string(hdr[4:8])
+		headerLen := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - pos
+		case 1:
+			if _, err := io.ReadFull(r, hdr[8:16]); err != nil {
+				return nil, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			headerLen = 16
+		}
+		if boxSize < headerLen {
+			break
+		}
+		if typ == "moov" {
+			if boxSize-headerLen > maxMoovSize {
+				return nil, errors.New("mp4: moov box too large")
+			}
+			moov = make([]byte, boxSize-headerLen)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return nil, err
+			}
+			break
+		}
+		pos += boxSize
+	}
+	if moov == nil {
+		return nil, errNoMovieHeader
+	}
+
+	res := &ProbeResult{Format: "mp4"}
+	foundHeader := false
+	for _, b := range children(moov) {
+		switch b.typ {
+		case "mvhd":
+			if d, ok := parseMvhd(b.data); ok {
+				res.Duration = d
+				foundHeader = true
+			}
+		case "trak":
+			parseTrak(b.data, res)
+		case "udta":
+			parseUdta(b.data, res)
+		}
+	}
+	if !foundHeader {
+		return nil, errNoMovieHeader
+	}
+	if res.Duration > 0 {
+		res.Bitrate = int(float64(size) * 8 / res.Duration / 1000)
+	}
+	return res, nil
+}
+
+// children splits a container payload into its boxes.
+func children(data []byte) []box {
+	var out []box
+	for len(data) >= 8 {
+		n := int(binary.BigEndian.Uint32(data[0:4]))
+		typ := string(data[4:8])
+		hl := 8
+		if n == 1 && len(data) >= 16 {
+			n = int(binary.BigEndian.Uint64(data[8:16]))
+			hl = 16
+		} else if n == 0 {
+			n = len(data)
+		}
+		if n < hl || n > len(data) {
+			break
+		}
+		out = append(out, box{typ: typ, data: data[hl:n]})
+		data = data[n:]
+	}
+	return out
+}
+
+func find(data []byte, path ...string) []byte {
+	for _, name := range path {
+		var next []byte
+		for _, b := range children(data) {
+			if b.typ == name {
+				next = b.data
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		data = next
+	}
+	return data
+}
+
+func parseMvhd(b []byte) (float64, bool) {
+	if len(b) < 4 {
+		return 0, false
+	}
+	if b[0] == 1 {
+		if len(b) < 32 {
+			return 0, false
+		}
+		scale := binary.BigEndian.Uint32(b[20:24])
+		dur := binary.BigEndian.Uint64(b[24:32])
+		if scale == 0 {
+			return 0, false
+		}
+		return float64(dur) / float64(scale), true
+	}
+	if len(b) < 20 {
+		return 0, false
+	}
+	scale := binary.BigEndian.Uint32(b[12:16])
+	dur := binary.BigEndian.Uint32(b[16:20])
+	if scale == 0 {
+		return 0, false
+	}
+	return float64(dur) / float64(scale), true
+}
+
+// parseTrak takes channel count and sample rate from the first audio sample entry.
+func parseTrak(trak []byte, res *ProbeResult) {
+	if res.SampleRate != 0 {
+		return
+	}
+	// hdlr: version/flags(4) pre_defined(4) handler_type(4)
+	if hdlr := find(trak, "mdia", "hdlr"); len(hdlr) < 12 || string(hdlr[8:12]) != "soun" {
+		return
+	}
+	stsd := find(trak, "mdia", "minf", "stbl", "stsd")
+	// stsd: version/flags(4) entry count(4), then sample entries
+	if len(stsd) < 8 {
+		return
+	}
+	entries := children(stsd[8:])
+	if len(entries) == 0 {
+		return
+	}
+	e := entries[0].data
+	// SampleEntry(8) + AudioSampleEntry: reserved(8) channelcount(2) samplesize(2) predefined(2) reserved(2) samplerate(4, 16.16)
+	if len(e) < 28 {
+		return
+	}
+	res.Channels = int(binary.BigEndian.Uint16(e[16:18]))
+	res.SampleRate = int(binary.BigEndian.Uint32(e[24:28]) >> 16)
+}
+
+func parseUdta(udta []byte, res *ProbeResult) {
+	meta := find(udta, "meta")
+	if len(meta) < 4 {
+		return
+	}
+	// meta is a full box: skip version/flags before its children
+	ilst := find(meta[4:], "ilst")
+	for _, item := range children(ilst) {
+		data := find(item.data, "data")
+		// data: type(4) locale(4) value
+		if len(data) < 8 {
+			continue
+		}
+		val := cleanText(string(data[8:]))
+		switch item.typ {
+		case "\xa9nam":
+			res.Title = val
+		case "\xa9ART":
+			res.Artist = val
+		case "desc", "\xa9cmt":
+			if res.Comment == "" {
+				res.Comment = val
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrUnknownFormat is returned when the stream is not MP3, WAV or MP4/M4A.
+var ErrUnknownFormat = errors.New("unknown audio format")
+
+// ProbeResult is what could be learned about an audio file without decoding it.
+type ProbeResult struct {
+	Format     string  `json:"format"`
+	Duration   float64 `json:"duration"` // seconds
+	Bitrate    int     `json:"bitrate"`  // kbit/s, average for VBR
+	SampleRate int     `json:"sampleRate"`
+	Channels   int     `json:"channels"`
+	VBR        bool    `json:"vbr,omitempty"`
+	Title      string  `json:"title,omitempty"`
+	Artist     string  `json:"artist,omitempty"`
+	Comment    string  `json:"comment,omitempty"`
+}
+
+// Seconds returns the duration rounded to whole seconds, as stored on episodes.
+func (p *ProbeResult) Seconds() int {
+	return int(math.Round(p.Duration))
+}
+
+// Probe detects the container by its magic bytes and extracts duration and tags.
+// size is the total length of r in bytes.
+func Probe(r io.ReadSeeker, size int64) (*ProbeResult, error) {
+	head := make([]byte, 12)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[0:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")):
+		return probeWAV(r, size)
+	case len(head) >= 8 && bytes.Equal(head[4:8], []byte("ftyp")):
+		return probeMP4(r, size)
+	case len(head) >= 3 && bytes.Equal(head[0:3], []byte("ID3")):
+		return probeMP3(r, size)
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return probeMP3(r, size)
+	}
+	return nil, ErrUnknownFormat
+}
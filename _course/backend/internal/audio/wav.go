@@ -0,0 +1,123 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var errBadWAV = errors.New("malformed wav file")
+
+// wavInfo is the fmt chunk plus the location of PCM data.
+type wavInfo struct {
+	format        uint16
+	channels      int
+	sampleRate    int
+	byteRate      int
+	blockAlign    int
+	bitsPerSample int
+	dataOffset    int64
+	dataSize      int64
+	title         string
+	comment       string
+}
+
+// readWAV walks the RIFF chunks and stops once both fmt and data are known.
+func readWAV(r io.ReadSeeker, size int64) (*wavInfo, error) {
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, err
+	}
+	info := &wavInfo{}
+	haveFmt := false
+	pos := int64(12)
+	hdr := make([]byte, 8)
+	for pos+8 <= size {
+		if _, err := io.ReadFull(r, hdr); err != nil {
+			break
+		}
+		id := string(hdr[0:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+		body := pos + 8
+		switch id {
+		case "fmt ":
+			if chunkSize < 16 {
+				return nil, errBadWAV
+			}
+			b := make([]byte, 16)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, err
+			}
+			info.format = binary.LittleEndian.Uint16(b[0:2])
+			info.channels = int(binary.LittleEndian.Uint16(b[2:4]))
+			info.sampleRate = int(binary.LittleEndian.Uint32(b[4:8]))
+			info.byteRate = int(binary.LittleEndian.Uint32(b[8:12]))
+			info.blockAlign = int(binary.LittleEndian.Uint16(b[12:14]))
+			info.bitsPerSample = int(binary.LittleEndian.Uint16(b[14:16]))
+			haveFmt = true
+		case "data":
+			info.dataOffset = body
+			info.dataSize = chunkSize
+			if body+chunkSize > size || chunkSize == 0xFFFFFFFF {
+				info.dataSize = size - body
+			}
+		case "LIST":
+			if chunkSize <= 64<<10 {
+				b := make([]byte, chunkSize)
+				if _, err := io.ReadFull(r, b); err == nil {
+					info.readInfoList(b)
+				}
+			}
+		}
+		pos = body + chunkSize + chunkSize&1
+		if _, err := r.Seek(pos, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	if !haveFmt || info.dataOffset == 0 || info.byteRate == 0 {
+		return nil, errBadWAV
+	}
+	return info, nil
+}
+
+// readInfoList extracts INAM (title) and ICMT (comment) from a LIST/INFO chunk.
+func (w *wavInfo) readInfoList(b []byte) {
+	if len(b) < 4 || string(b[0:4]) != "INFO" {
+		return
+	}
+	b = b[4:]
+	for len(b) >= 8 {
+		id := string(b[0:4])
+		n := int(binary.LittleEndian.Uint32(b[4:8]))
+		if 8+n > len(b) {
+			return
+		}
+		val := cleanText(string(b[8 : 8+n]))
+		switch id {
+		case "INAM":
+			w.title = val
+		case "ICMT":
+			w.comment = val
+		}
+		next := 8 + n + n&1
+		if next > len(b) {
+			return
+		}
+		b = b[next:]
+	}
+}
+
+func probeWAV(r io.ReadSeeker, size int64) (*ProbeResult, error) {
+	info, err := readWAV(r, size)
+	if err != nil {
+		return nil, err
+	}
+	return &ProbeResult{
+		Format:     "wav",
+		Duration:   float64(info.dataSize) / float64(info.byteRate),
+		Bitrate:    info.byteRate * 8 / 1000,
+		SampleRate: info.sampleRate,
+		Channels:   info.channels,
+		Title:      info.title,
+		Comment:    info.comment,
+	}, nil
+}
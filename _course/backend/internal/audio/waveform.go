@@ -0,0 +1,152 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// ErrUnsupportedCodec is returned for audio that can be probed but not decoded.
+var ErrUnsupportedCodec = errors.New("audio codec is not supported for decoding")
+
+// envelopeBlock is how many PCM frames make up one envelope value; it matches an MP3 granule.
+const envelopeBlock = 576
+
+// Envelope is the loudness of decoded audio over time. Levels are relative: PCM
+// levels for WAV, spectral estimates for MP3 (see mp3Envelope).
+type Envelope struct {
+	Rate   float64   // values per second
+	Values []float32 // RMS level of consecutive blocks
+}
+
+// Duration is the length of the decoded audio in seconds.
+func (e *Envelope) Duration() float64 {
+	if e.Rate == 0 {
+		return 0
+	}
+	return float64(len(e.Values)) / e.Rate
+}
+
+// Peaks reduces the envelope to at most bins values, each the loudest block in its
+// bin, scaled so the loudest bin of the whole file is 255.
+func (e *Envelope) Peaks(bins int) []int {
+	if bins > len(e.Values) {
+		bins = len(e.Values)
+	}
+	raw := make([]float32, bins)
+	var top float32
+	for i := range raw {
+		from := i * len(e.Values) / bins
+		to := (i + 1) * len(e.Values) / bins
+		for _, v := range e.Values[from:to] {
+			if v > raw[i] {
+				raw[i] = v
+			}
+		}
+		if raw[i] > top {
+			top = raw[i]
+		}
+	}
+	out := make([]int, bins)
+	if top == 0 {
+		return out
+	}
+	for i, v := range raw {
+		out[i] = int(math.Round(float64(v / top * 255)))
+	}
+	return out
+}
+
+// DecodeEnvelope decodes MP3 or PCM WAV audio into a loudness envelope.
+// size is the total length of r in bytes.
+func DecodeEnvelope(r io.ReadSeeker, size int64) (*Envelope, error) {
+	head := make([]byte, 12)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	head = head[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[0:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")):
+		return wavEnvelope(r, size)
+	case len(head) >= 8 && bytes.Equal(head[4:8], []byte("ftyp")):
+		return nil, ErrUnsupportedCodec
+	case len(head) >= 3 && bytes.Equal(head[0:3], []byte("ID3")):
+		return mp3Envelope(r, size)
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return mp3Envelope(r, size)
+	}
+	return nil, ErrUnknownFormat
+}
+
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavEnvelope reads integer PCM (8 to 32 bit) or 32-bit float samples.
+func wavEnvelope(r io.ReadSeeker, size int64) (*Envelope, error) {
+	info, err := readWAV(r, size)
+	if err != nil {
+		return nil, err
+	}
+	bytesPerSample := info.bitsPerSample / 8
+	switch {
+	case info.channels == 0 || info.sampleRate == 0 || info.blockAlign != bytesPerSample*info.channels:
+		return nil, errBadWAV
+	case info.format == wavFormatFloat && info.bitsPerSample == 32:
+	case (info.format == wavFormatPCM || info.format == wavFormatExtensible) &&
+		bytesPerSample >= 1 && bytesPerSample <= 4 && info.bitsPerSample%8 == 0:
+	default:
+		return nil, ErrUnsupportedCodec
+	}
+	if _, err := r.Seek(info.dataOffset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	env := &Envelope{Rate: float64(info.sampleRate) / envelopeBlock}
+	br := bufio.NewReaderSize(io.LimitReader(r, info.dataSize), 64<<10)
+	block := make([]byte, envelopeBlock*info.blockAlign)
+	for {
+		n, err := io.ReadFull(br, block)
+		n -= n % info.blockAlign
+		if n > 0 {
+			var sum float64
+			for off := 0; off < n; off += bytesPerSample {
+				s := pcmSample(block[off:off+bytesPerSample], info.format == wavFormatFloat)
+				sum += s * s
+			}
+			env.Values = append(env.Values, float32(math.Sqrt(sum/float64(n/bytesPerSample))))
+		}
+		if err != nil {
+			break
+		}
+	}
+	return env, nil
+}
+
+// pcmSample converts one little-endian sample to [-1, 1]; 8-bit PCM is unsigned.
+func pcmSample(b []byte, float bool) float64 {
+	switch len(b) {
+	case 1:
+		return (float64(b[0]) - 128) / 128
+	case 2:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case 3:
+		v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+		return float64(v) / (1 << 23)
+	default:
+		if float {
+			return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		}
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	}
+}
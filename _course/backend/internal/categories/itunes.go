@@ -0,0 +1,208 @@
+// Package categories holds the podcast category tree (Apple Podcasts categories)
+// and maps free-form category strings from authors and feeds onto it.
+package categories
+
+import "strings"
+
+// Def is one category of the tree. Aliases are extra lower-case spellings that
+// should resolve to it besides its slug and names.
+type Def struct {
+	Slug     string
+	Name     string
+	NameRu   string
+	Aliases  []string
+	Children []Def
+}
+
+// Tree is the Apple Podcasts category list; order is display order.
+var Tree = []Def{
+	{Slug: "arts", Name: "Arts", NameRu: "Искусство", Aliases: []string{"искусства"}, Children: []Def{
+		{Slug: "books", Name: "Books", NameRu: "Книги", Aliases: []string{"литература", "literature"}},
+		{Slug: "design", Name: "Design", NameRu: "Дизайн"},
+		{Slug: "fashion-beauty", Name: "Fashion & Beauty", NameRu: "Мода и красота"},
+		{Slug: "food", Name: "Food", NameRu: "Еда", Aliases: []string{"кулинария"}},
+		{Slug: "performing-arts", Name: "Performing Arts", NameRu: "Исполнительское искусство", Aliases: []string{"театр"}},
+		{Slug: "visual-arts", Name: "Visual Arts", NameRu: "Изобразительное искусство"},
+	}},
+	{Slug: "business", Name: "Business", NameRu: "Бизнес", Children: []Def{
+		{Slug: "careers", Name: "Careers", NameRu: "Карьера"},
+		{Slug: "entrepreneurship", Name: "Entrepreneurship", NameRu: "Предпринимательство", Aliases: []string{"стартапы", "startups"}},
+		{Slug: "investing", Name: "Investing", NameRu: "Инвестиции", Aliases: []string{"финансы", "finance"}},
+		{Slug: "management", Name: "Management", NameRu: "Менеджмент", Aliases: []string{"управление"}},
+		{Slug: "marketing", Name: "Marketing", NameRu: "Маркетинг"},
+		{Slug: "non-profit", Name: "Non-Profit", NameRu: "Некоммерческие организации"},
+	}},
+	{Slug: "comedy", Name: "Comedy", NameRu: "Юмор", Aliases: []string{"комедия"}, Children: []Def{
+		{Slug: "comedy-interviews", Name: "Comedy Interviews", NameRu: "Юмористические интервью"},
+		{Slug: "improv", Name: "Improv", NameRu: "Импровизация"},
+		{Slug: "stand-up", Name: "Stand-Up", NameRu: "Стендап"},
+	}},
+	{Slug: "education", Name: "Education", NameRu: "Образование", Aliases: []string{"обучение"}, Children: []Def{
+		{Slug: "courses", Name: "Courses", NameRu: "Курсы"},
+		{Slug: "how-to", Name: "How To", NameRu: "Инструкции"},
+		{Slug: "language-learning", Name: "Language Learning", NameRu: "Изучение языков", Aliases: []string{"языки"}},
+		{Slug: "self-improvement", Name: "Self-Improvement", NameRu: "Саморазвитие"},
+	}},
+	{Slug: "fiction", Name: "Fiction", NameRu: "Художественные истории", Children: []Def{
+		{Slug: "comedy-fiction", Name: "Comedy Fiction", NameRu: "Юмористические истории"},
+		{Slug: "drama", Name: "Drama", NameRu: "Драма"},
+		{Slug: "science-fiction", Name: "Science Fiction", NameRu: "Научная фантастика", Aliases: []string{"фантастика"}},
+	}},
+	{Slug: "government", Name: "Government", NameRu: "Государство"},
+	{Slug: "history", Name: "History", NameRu: "История"},
+	{Slug: "health-fitness", Name: "Health & Fitness", NameRu: "Здоровье и фитнес", Aliases: []string{"здоровье", "health"}, Children: []Def{
+		{Slug: "alternative-health", Name: "Alternative Health", NameRu: "Альтернативная медицина"},
+		{Slug: "fitness", Name: "Fitness", NameRu: "Фитнес", Aliases: []string{"спорт и фитнес"}},
+		{Slug: "medicine", Name: "Medicine", NameRu: "Медицина"},
+		{Slug: "mental-health", Name: "Mental Health", NameRu: "Психическое здоровье", Aliases: []string{"психология", "psychology"}},
+		{Slug: "nutrition", Name: "Nutrition", NameRu: "Питание"},
+		{Slug: "sexuality", Name: "Sexuality", NameRu: "Сексуальность"},
+	}},
+	{Slug: "kids-family", Name: "Kids & Family", NameRu: "Дети и семья", Aliases: []string{"семья", "дети"}, Children: []Def{
+		{Slug: "education-for-kids", Name: "Education for Kids", NameRu: "Обучение для детей"},
+		{Slug: "parenting", Name: "Parenting", NameRu: "Воспитание детей", Aliases: []string{"родительство"}},
+		{Slug: "pets-animals", Name: "Pets & Animals", NameRu: "Домашние животные"},
+		{Slug: "stories-for-kids", Name: "Stories for Kids", NameRu: "Сказки для детей"},
+	}},
+	{Slug: "leisure", Name: "Leisure", NameRu: "Досуг", Aliases: []string{"развлечения", "entertainment", "games & hobbies"}, Children: []Def{
+		{Slug: "animation-manga", Name: "Animation & Manga", NameRu: "Анимация и манга", Aliases: []string{"аниме"}},
+		{Slug: "automotive", Name: "Automotive", NameRu: "Автомобили"},
+		{Slug: "aviation", Name: "Aviation", NameRu: "Авиация"},
+		{Slug: "crafts", Name: "Crafts", NameRu: "Рукоделие"},
+		{Slug: "games", Name: "Games", NameRu: "Игры"},
+		{Slug: "hobbies", Name: "Hobbies", NameRu: "Хобби"},
+		{Slug: "home-garden", Name: "Home & Garden", NameRu: "Дом и сад"},
+		{Slug: "video-games", Name: "Video Games", NameRu: "Видеоигры"},
+	}},
+	{Slug: "music", Name: "Music", NameRu: "Музыка", Children: []Def{
+		{Slug: "music-commentary", Name: "Music Commentary", NameRu: "Обзоры музыки"},
+		{Slug: "music-history", Name: "Music History", NameRu: "История музыки"},
+		{Slug: "music-interviews", Name: "Music Interviews", NameRu: "Интервью с музыкантами"},
+	}},
+	{Slug: "news", Name: "News", NameRu: "Новости", Aliases: []string{"news & politics"}, Children: []Def{
+		{Slug: "business-news", Name: "Business News", NameRu: "Деловые новости"},
+		{Slug: "daily-news", Name: "Daily News", NameRu: "Ежедневные новости"},
+		{Slug: "entertainment-news", Name: "Entertainment News", NameRu: "Новости шоу-бизнеса"},
+		{Slug: "news-commentary", Name: "News Commentary", NameRu: "Комментарии к новостям"},
+		{Slug: "politics", Name: "Politics", NameRu: "Политика"},
+		{Slug: "sports-news", Name: "Sports News", NameRu: "Спортивные новости"},
+		{Slug: "tech-news", Name: "Tech News", NameRu: "Новости технологий"},
+	}},
+	{Slug: "religion-spirituality", Name: "Religion & Spirituality", NameRu: "Религия и духовность", Children: []Def{
+		{Slug: "buddhism", Name: "Buddhism", NameRu: "Буддизм"},
+		{Slug: "christianity", Name: "Christianity", NameRu: "Христианство"},
+		{Slug: "hinduism", Name: "Hinduism", NameRu: "Индуизм"},
+		{Slug: "islam", Name: "Islam", NameRu: "Ислам"},
+		{Slug: "judaism", Name: "Judaism", NameRu: "Иудаизм"},
+		{Slug: "religion", Name: "Religion", NameRu: "Религия"},
+		{Slug: "spirituality", Name: "Spirituality", NameRu: "Духовность"},
+	}},
+	{Slug: "science", Name: "Science", NameRu: "Наука", Aliases: []string{"science & medicine"}, Children: []Def{
+		{Slug: "astronomy", Name: "Astronomy", NameRu: "Астрономия"},
+		{Slug: "chemistry", Name: "Chemistry", NameRu: "Химия"},
+		{Slug: "earth-sciences", Name: "Earth Sciences", NameRu: "Науки о Земле"},
+		{Slug: "life-sciences", Name: "Life Sciences", NameRu: "Науки о жизни", Aliases: []string{"биология"}},
+		{Slug: "mathematics", Name: "Mathematics", NameRu: "Математика"},
+		{Slug: "natural-sciences", Name: "Natural Sciences", NameRu: "Естественные науки"},
+		{Slug: "nature", Name: "Nature", NameRu: "Природа"},
+		{Slug: "physics", Name: "Physics", NameRu: "Физика"},
+		{Slug: "social-sciences", Name: "Social Sciences", NameRu: "Социальные науки"},
+	}},
+	{Slug: "society-culture", Name: "Society & Culture", NameRu: "Общество и культура", Aliases: []string{"культура", "culture", "общество"}, Children: []Def{
+		{Slug: "documentary", Name: "Documentary", NameRu: "Документальные"},
+		{Slug: "personal-journals", Name: "Personal Journals", NameRu: "Личные дневники"},
+		{Slug: "philosophy", Name: "Philosophy", NameRu: "Философия"},
+		{Slug: "places-travel", Name: "Places & Travel", NameRu: "Путешествия"},
+		{Slug: "relationships", Name: "Relationships", NameRu: "Отношения"},
+	}},
+	{Slug: "sports", Name: "Sports", NameRu: "Спорт", Children: []Def{
+		{Slug: "baseball", Name: "Baseball", NameRu: "Бейсбол"},
+		{Slug: "basketball", Name: "Basketball", NameRu: "Баскетбол"},
+		{Slug: "cricket", Name: "Cricket", NameRu: "Крикет"},
+		{Slug: "fantasy-sports", Name: "Fantasy Sports", NameRu: "Фэнтези-спорт"},
+		{Slug: "football", Name: "Football", NameRu: "Американский футбол"},
+		{Slug: "golf", Name: "Golf", NameRu: "Гольф"},
+		{Slug: "hockey", Name: "Hockey", NameRu: "Хоккей"},
+		{Slug: "rugby", Name: "Rugby", NameRu: "Регби"},
+		{Slug: "running", Name: "Running", NameRu: "Бег"},
+		{Slug: "soccer", Name: "Soccer", NameRu: "Футбол"},
+		{Slug: "swimming", Name: "Swimming", NameRu: "Плавание"},
+		{Slug: "tennis", Name: "Tennis", NameRu: "Теннис"},
+		{Slug: "volleyball", Name: "Volleyball", NameRu: "Волейбол"},
+		{Slug: "wilderness", Name: "Wilderness", NameRu: "Дикая природа"},
+		{Slug: "wrestling", Name: "Wrestling", NameRu: "Борьба"},
+	}},
+	{Slug: "technology", Name: "Technology", NameRu: "Технологии", Aliases: []string{"tech", "технология", "it"}},
+	{Slug: "true-crime", Name: "True Crime", NameRu: "Тру-крайм", Aliases: []string{"криминал"}},
+	{Slug: "tv-film", Name: "TV & Film", NameRu: "Кино и сериалы", Aliases: []string{"кино", "tv and film"}, Children: []Def{
+		{Slug: "after-shows", Name: "After Shows", NameRu: "Обсуждения после эфира"},
+		{Slug: "film-history", Name: "Film History", NameRu: "История кино"},
+		{Slug: "film-interviews", Name: "Film Interviews", NameRu: "Интервью о кино"},
+		{Slug: "film-reviews", Name: "Film Reviews", NameRu: "Рецензии на фильмы"},
+		{Slug: "tv-reviews", Name: "TV Reviews", NameRu: "Обзоры сериалов"},
+	}},
+}
+
+var index = buildIndex()
+
+func buildIndex() map[string]string {
+	m := make(map[string]string)
+	var add func(defs []Def)
+	add = func(defs []Def) {
+		for _, d := range defs {
+			for _, s := range append([]string{d.Slug, d.Name, d.NameRu}, d.Aliases...) {
+				m[normalize(s)] = d.Slug
+			}
+			add(d.Children)
+		}
+	}
+	add(Tree)
+	return m
+}
+
+// normalize folds case, "&"/"and"/"и" and punctuation so "Health and Fitness",
+// "health & fitness" and "health-fitness" compare equal.
+func normalize(s string) string {
+	s = strings.ToLower(s)
+	s = strings.NewReplacer("&", " ", "-", " ", ",", " ", "/", " ").Replace(s)
+	words := strings.Fields(s)
+	out := words[:0]
+	for _, w := range words {
+		if w != "and" && w != "и" {
+			out = append(out, w)
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// Match returns the slug of the category name refers to, or "" if none does.
+// Feeds sometimes send "Parent > Child" or "Parent/Child"; the most specific known part wins.
+func Match(name string) string {
+	if slug, ok := index[normalize(name)]; ok {
+		return slug
+	}
+	parts := strings.FieldsFunc(name, func(r rune) bool { return r == '>' || r == '/' || r == ':' })
+	for i := len(parts) - 1; i >= 0; i-- {
+		if slug, ok := index[normalize(parts[i])]; ok {
+			return slug
+		}
+	}
+	return ""
+}
+
+// ITunes returns the Apple Podcasts category of slug as feeds spell it, with the
+// parent first for subcategories: ("Technology", "") or ("Health & Fitness",
+// "Mental Health"). ok is false for an unknown slug.
+func ITunes(slug string) (category, subcategory string, ok bool) {
+	for _, d := range Tree {
+		if d.Slug == slug {
+			return d.Name, "", true
+		}
+		for _, c := range d.Children {
+			if c.Slug == slug {
+				return d.Name, c.Name, true
+			}
+		}
+	}
+	return "", "", false
+}
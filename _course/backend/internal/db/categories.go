@@ -0,0 +1,53 @@
+package db
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"podcast-backend/internal/categories"
+	"podcast-backend/internal/models"
+)
+
+// migrateCategories upserts the category tree and links podcasts whose free-form
+// category has not been resolved yet.
+func migrateCategories(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var upsert func(defs []categories.Def, parentID *uint) error
+		upsert = func(defs []categories.Def, parentID *uint) error {
+			for i, d := range defs {
+				c := models.Category{Slug: d.Slug, Name: d.Name, NameRu: d.NameRu, ParentID: parentID, Position: i}
+				if err := tx.Clauses(clause.OnConflict{
+					Columns:   []clause.Column{{Name: "slug"}},
+					DoUpdates: clause.AssignmentColumns([]string{"name", "name_ru", "parent_id", "position"}),
+				}).Create(&c).Error; err != nil {
+					return err
+				}
+				if err := upsert(d.Children, &c.ID); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := upsert(categories.Tree, nil); err != nil {
+			return err
+		}
+
+		var names []string
+		if err := tx.Model(&models.Podcast{}).
+			Where("category_id IS NULL AND category IS NOT NULL AND category <> ''").
+			Distinct().Pluck("category", &names).Error; err != nil {
+			return err
+		}
+		for _, name := range names {
+			slug := categories.Match(name)
+			if slug == "" {
+				continue
+			}
+			if err := tx.Exec(`UPDATE podcasts SET category_id = (SELECT id FROM categories WHERE slug = ?)
+				WHERE category_id IS NULL AND category = ?`, slug, name).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// migrations is schema AutoMigrate cannot express. Every statement must be
+// idempotent: they all run on each start, after AutoMigrate.
+var migrations = []string{
+	// Full-text search. Each text is indexed with both the Russian and the English
+	// configuration; weights rank title over author over description.
+	`ALTER TABLE podcasts ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('russian', coalesce(author, '')), 'B') ||
+		setweight(to_tsvector('english', coalesce(author, '')), 'B') ||
+		setweight(to_tsvector('russian', coalesce(description, '')), 'C') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'C')
+	) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_podcasts_search ON podcasts USING GIN (search_vector)`,
+	`ALTER TABLE episodes ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+		setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+		setweight(to_tsvector('russian', coalesce(description, '')), 'C') ||
+		setweight(to_tsvector('english', coalesce(description, '')), 'C')
+	) STORED`,
+	`CREATE INDEX IF NOT EXISTS idx_episodes_search ON episodes USING GIN (search_vector)`,
+
+	// Autocomplete and typo suggestions.
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`CREATE INDEX IF NOT EXISTS idx_podcasts_title_trgm ON podcasts USING GIN (title gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_podcasts_author_trgm ON podcasts USING GIN (author gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_episodes_title_trgm ON episodes USING GIN (title gin_trgm_ops)`,
+	// search_terms is the vocabulary "did you mean" corrects against; it is refreshed by a
+	// job. Only what search can find goes in, so drafts never leak through a correction.
+	// The first version indexed every title and is replaced.
+	`DO $$ BEGIN
+		IF EXISTS (SELECT 1 FROM pg_matviews WHERE matviewname = 'search_terms' AND definition NOT LIKE '%visibility%') THEN
+			DROP MATERIALIZED VIEW search_terms;
+		END IF;
+	END $$`,
+	`CREATE MATERIALIZED VIEW IF NOT EXISTS search_terms AS
+		SELECT word, ndoc FROM ts_stat($q$
+			SELECT to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(author, ''))
+				FROM podcasts WHERE visibility = 'public'
+			UNION ALL
+			SELECT to_tsvector('simple', coalesce(e.title, ''))
+				FROM episodes e JOIN podcasts p ON p.id = e.podcast_id
+				WHERE e.status = 'published' AND p.visibility = 'public'
+		$q$)`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_search_terms_word ON search_terms (word)`,
+	`CREATE INDEX IF NOT EXISTS idx_search_terms_trgm ON search_terms USING GIN (word gin_trgm_ops)`,
+
+	// Charts scan recent engagement across all users.
+	`CREATE INDEX IF NOT EXISTS idx_history_entries_created ON history_entries (created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_episode_likes_created ON episode_likes (created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_favorites_created ON favorites (created_at)`,
+	`CREATE INDEX IF NOT EXISTS idx_library_items_created ON library_items (created_at)`,
+
+	// The outbox dispatcher only looks at pending events.
+	`CREATE INDEX IF NOT EXISTS idx_outbox_events_pending ON outbox_events (next_attempt_at) WHERE dispatched_at IS NULL`,
+
+	// Episodes from before scheduled publishing, and feed items without a date, count
+	// as published when they were created.
+	`UPDATE episodes SET publish_at = created_at WHERE status = 'published' AND publish_at IS NULL`,
+}
+
+// Migrate applies the raw SQL migrations and the data migrations, after AutoMigrate.
+func Migrate(db *gorm.DB) error {
+	for i, stmt := range migrations {
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("migration %d: %w", i, err)
+		}
+	}
+	if err := migrateCategories(db); err != nil {
+		return fmt.Errorf("categories: %w", err)
+	}
+	return nil
+}
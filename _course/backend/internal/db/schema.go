@@ -0,0 +1,17 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/models"
+)
+
+// AutoMigrate creates and updates the tables of all models. Migrate runs after it.
+func AutoMigrate(db *gorm.DB) error {
+	if err := db.SetupJoinTable(&models.Episode{}, "Tags", &models.EpisodeTag{}); err != nil {
+		return fmt.Errorf("join table: %w", err)
+	}
+	return db.AutoMigrate(&models.User{}, &models.Category{}, &models.Podcast{}, &models.Tag{}, &models.Episode{}, &models.EpisodeLike{}, &models.Favorite{}, &models.LibraryItem{}, &models.FeedSource{}, &models.Waveform{}, &models.PlaybackProgress{}, &models.PodcastVisit{}, &models.HistoryEntry{}, &models.EpisodeTag{}, &models.ChartEntry{}, &models.PodcastSimilarity{}, &models.Recommendation{}, &models.PlayEvent{}, &models.OutboxEvent{}, &models.Webhook{}, &models.WebhookDelivery{})
+}
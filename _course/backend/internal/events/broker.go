@@ -0,0 +1,62 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Broker carries events between instances of the backend: every instance publishes
+// its events and delivers what it receives, its own included, to local clients.
+type Broker interface {
+	Publish(ctx context.Context, m *Message) error
+	// Subscribe calls deliver for every published message until ctx is cancelled or
+	// the subscription fails.
+	Subscribe(ctx context.Context, deliver func(Message)) error
+}
+
+// RedisBroker fans events out over a Redis pub/sub channel.
+type RedisBroker struct {
+	redis   *redis.Client
+	channel string
+}
+
+func NewRedisBroker(client *redis.Client, channel string) *RedisBroker {
+	return &RedisBroker{redis: client, channel: channel}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, m *Message) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return b.redis.Publish(ctx, b.channel, data).Err()
+}
+
+func (b *RedisBroker) Subscribe(ctx context.Context, deliver func(Message)) error {
+	sub := b.redis.Subscribe(ctx, b.channel)
+	defer sub.Close()
+	// wait for the subscription, so failures are reported instead of retried silently
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var m Message
+			if err := json.Unmarshal([]byte(msg.Payload), &m); err != nil {
+				log.Printf("events: bad broker message: %v", err)
+				continue
+			}
+			deliver(m)
+		}
+	}
+}
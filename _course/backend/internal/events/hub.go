@@ -1,36 +1,141 @@
 package events
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"expvar"
 	"log"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"podcast-backend/internal/auth"
 )
 
+const (
+	// maxTopics bounds the subscriptions of one connection.
+	maxTopics = 100
+	// clientBuffer is how many events may wait for a slow client before it is
+	// disconnected; it then reconnects and catches up from the replay buffer.
+	clientBuffer = 64
+	// heartbeat keeps idle proxies from closing quiet streams.
+	heartbeat = 15 * time.Second
+	// retryMillis is the reconnection delay suggested to EventSource.
+	retryMillis  = 3000
+	storeTimeout = 2 * time.Second
+	// brokerRetry is the pause before subscribing again after the broker failed.
+	brokerRetry = 5 * time.Second
+)
+
+// metrics are published at /debug/vars: connected clients, delivered, dropped and
+// replayed events, resyncs and store failures.
+var metrics = expvar.NewMap("events")
+
+// topicPattern is what clients may subscribe to: a podcast or an episode by id.
+var topicPattern = regexp.MustCompile(`^(podcast|episode):[0-9]+$`)
+
 // Event represents a generic SSE event payload.
 type Event struct {
 	Type string      `json:"type"`
 	Data interface{} `json:"data"`
 }
 
+// delivery is an event framed for the wire.
+type delivery struct {
+	id    string
+	frame []byte
+}
+
+// client is one SSE connection. A client without topics receives every published event.
+type client struct {
+	ch     chan delivery
+	userID uint
+	topics map[string]struct{}
+	lagged chan struct{} // closed when an event had to be dropped
+	once   sync.Once
+}
+
+func (c *client) lag() {
+	c.once.Do(func() { close(c.lagged) })
+}
+
+// wants reports whether the client subscribed to any of topics.
+func (c *client) wants(topics []string) bool {
+	if len(c.topics) == 0 {
+		return true
+	}
+	for _, t := range topics {
+		if _, ok := c.topics[t]; ok {
+			return true
+		}
+	}
+	return false
+}
+
 type Hub struct {
-	mu       sync.Mutex
-	clients  map[chan []byte]struct{}
-	jwt      *auth.JWTService
+	mu      sync.Mutex // guards clients
+	clients map[*client]struct{}
+	jwt     *auth.JWTService
+	store   Store
+	broker  Broker
+	// instance tells this hub's messages apart when the broker hands them back.
+	instance string
+	// sendMu orders storing with publishing, so this instance's events go out
+	// in ID order.
+	sendMu sync.Mutex
 }
 
-func NewHub(jwt *auth.JWTService) *Hub {
+// NewHub returns a hub that keeps events in store for replay. With a broker, events
+// reach the clients of every instance once Run is started; without one (nil) they
+// are delivered in-process.
+func NewHub(jwt *auth.JWTService, store Store, broker Broker) *Hub {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
 	return &Hub{
-		clients: make(map[chan []byte]struct{}),
-		jwt:     jwt,
+		clients:  make(map[*client]struct{}),
+		jwt:      jwt,
+		store:    store,
+		broker:   broker,
+		instance: hex.EncodeToString(b),
 	}
 }
 
-// Handler handles SSE connections on /api/events?token=JWT
+// Run relays the events published by all instances to local clients until ctx is
+// cancelled. Without a broker it returns at once.
+func (h *Hub) Run(ctx context.Context) {
+	if h.broker == nil {
+		return
+	}
+	for ctx.Err() == nil {
+		err := h.broker.Subscribe(ctx, h.relay)
+		if ctx.Err() != nil {
+			return
+		}
+		metrics.Add("broker_errors", 1)
+		log.Printf("events: broker subscription: %v", err)
+		select {
+		case <-ctx.Done():
+		case <-time.After(brokerRetry):
+		}
+	}
+}
+
+// PodcastTopic and EpisodeTopic name the topics events about a show are published on.
+func PodcastTopic(id uint) string { return "podcast:" + strconv.FormatUint(uint64(id), 10) }
+func EpisodeTopic(id uint) string { return "episode:" + strconv.FormatUint(uint64(id), 10) }
+
+// Handler handles SSE connections on /api/events?token=JWT[&topics=podcast:12,episode:55].
+// Each event is sent with an ID and its type as the SSE event name. A reconnecting
+// client gets the events it missed after its Last-Event-ID header (or lastEventId
+// query parameter), preceded by a "resync" event when some are no longer stored.
 func (h *Hub) Handler(c *gin.Context) {
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
@@ -43,10 +148,31 @@ func (h *Hub) Handler(c *gin.Context) {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
 		return
 	}
-	if _, err := h.jwt.Parse(token); err != nil {
+	claims, err := h.jwt.Parse(token)
+	if err != nil {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		return
 	}
+	topics, ok := parseTopics(c.Query("topics"))
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid topics"})
+		return
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("lastEventId")
+	}
+
+	cl := &client{ch: make(chan delivery, clientBuffer), userID: claims.UserID, topics: topics, lagged: make(chan struct{})}
+	replay, complete := h.subscribe(cl, lastID)
+	metrics.Add("clients", 1)
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, cl)
+		h.mu.Unlock()
+		metrics.Add("clients", -1)
+	}()
 
 	c.Writer.Header().Set("Content-Type", "text/event-stream")
 	c.Writer.Header().Set("Cache-Control", "no-cache")
@@ -54,30 +180,43 @@ func (h *Hub) Handler(c *gin.Context) {
 	c.Writer.Header().Set("X-Accel-Buffering", "no")
 
 	// send initial comment to establish stream
-	_, _ = c.Writer.Write([]byte(": connected\n\n"))
+	_, _ = c.Writer.Write([]byte("retry: " + strconv.Itoa(retryMillis) + "\n: connected\n\n"))
+	if !complete {
+		metrics.Add("resyncs", 1)
+		payload, _ := json.Marshal(Event{Type: "resync"})
+		_, _ = c.Writer.Write(frame("", "resync", payload))
+	}
+	// events stored while subscribing can also arrive live; replayed is for skipping them
+	replayed := make(map[string]struct{}, len(replay))
+	for i := range replay {
+		replayed[replay[i].ID] = struct{}{}
+		if replay[i].matches(cl) {
+			metrics.Add("replayed", 1)
+			_, _ = c.Writer.Write(replay[i].frame())
+		}
+	}
 	flusher.Flush()
 
-	ch := make(chan []byte, 8)
-
-	h.mu.Lock()
-	h.clients[ch] = struct{}{}
-	h.mu.Unlock()
-
-	defer func() {
-		h.mu.Lock()
-		delete(h.clients, ch)
-		h.mu.Unlock()
-		close(ch)
-	}()
-
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
 	ctx := c.Request.Context()
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case msg := <-ch:
-			_, err := c.Writer.Write(append([]byte("data: "), append(msg, []byte("\n\n")...)...))
-			if err != nil {
+		case <-cl.lagged:
+			// the client will reconnect with its Last-Event-ID and get what it missed
+			return
+		case <-ticker.C:
+			if _, err := c.Writer.Write([]byte(": ping\n\n")); err != nil {
+				return
+			}
+			flusher.Flush()
+		case d := <-cl.ch:
+			if _, ok := replayed[d.id]; ok {
+				continue
+			}
+			if _, err := c.Writer.Write(d.frame); err != nil {
 				return
 			}
 			flusher.Flush()
@@ -85,22 +224,131 @@ func (h *Hub) Handler(c *gin.Context) {
 	}
 }
 
-// Broadcast sends an event to all connected clients.
+// subscribe registers the client and then returns the stored events after lastID,
+// so nothing published in between is missed; the overlap is skipped by Handler.
+func (h *Hub) subscribe(cl *client, lastID string) ([]Message, bool) {
+	h.mu.Lock()
+	h.clients[cl] = struct{}{}
+	h.mu.Unlock()
+	var replay []Message
+	complete := true
+	if lastID != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+		defer cancel()
+		var err error
+		if replay, complete, err = h.store.Since(ctx, lastID); err != nil {
+			metrics.Add("store_errors", 1)
+			log.Printf("events: replay after %s: %v", lastID, err)
+		}
+	}
+	return replay, complete
+}
+
+// parseTopics splits a comma-separated topic list; an empty list subscribes to everything.
+func parseTopics(raw string) (map[string]struct{}, bool) {
+	topics := make(map[string]struct{})
+	for _, t := range strings.Split(raw, ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if !topicPattern.MatchString(t) || len(topics) == maxTopics {
+			return nil, false
+		}
+		topics[t] = struct{}{}
+	}
+	return topics, true
+}
+
+// Broadcast sends an event to all connected clients, whatever they subscribed to.
 func (h *Hub) Broadcast(evtType string, data interface{}) {
+	h.send(evtType, data, nil, 0)
+}
+
+// Publish sends an event about topics to the clients subscribed to any of them and to
+// clients that did not pick topics.
+func (h *Hub) Publish(evtType string, data interface{}, topics ...string) {
+	h.send(evtType, data, topics, 0)
+}
+
+// SendToUser sends an event to every connection of one user, whatever they subscribed to.
+func (h *Hub) SendToUser(userID uint, evtType string, data interface{}) {
+	h.send(evtType, data, nil, userID)
+}
+
+func (h *Hub) send(evtType string, data interface{}, topics []string, userID uint) {
 	payload, err := json.Marshal(Event{Type: evtType, Data: data})
 	if err != nil {
 		log.Printf("events: marshal error: %v", err)
 		return
 	}
+	m := Message{Type: evtType, Payload: payload, Topics: topics, UserID: userID}
+
+	h.sendMu.Lock()
+	defer h.sendMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+	if err := h.store.Append(ctx, &m); err != nil {
+		// still delivered live, just without an ID to resume from
+		metrics.Add("store_errors", 1)
+		log.Printf("events: store %s: %v", evtType, err)
+	}
+	// Local clients get the event straight away, so they do not depend on this
+	// instance's subscription being up; relay skips it when the broker hands it back.
+	h.deliver(m)
+	if h.broker != nil {
+		m.Origin = h.instance
+		if err := h.broker.Publish(ctx, &m); err != nil {
+			// only other instances miss it
+			metrics.Add("broker_errors", 1)
+			log.Printf("events: publish %s: %v", evtType, err)
+		}
+	}
+}
+
+// relay delivers what the broker received from other instances.
+func (h *Hub) relay(m Message) {
+	if m.Origin == h.instance {
+		return
+	}
+	h.deliver(m)
+}
+
+// deliver queues an event for the matching local clients.
+func (h *Hub) deliver(m Message) {
+	d := delivery{id: m.ID, frame: m.frame()}
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	for ch := range h.clients {
+	for cl := range h.clients {
+		if !m.matches(cl) {
+			continue
+		}
 		select {
-		case ch <- payload:
+		case cl.ch <- d:
+			metrics.Add("sent", 1)
 		default:
-			// drop if client is slow
+			metrics.Add("dropped", 1)
+			cl.lag()
 		}
 	}
 }
 
+func (m *Message) frame() []byte {
+	return frame(m.ID, m.Type, m.Payload)
+}
 
+// frame formats a named SSE event. The type also stays inside data, so payloads
+// parse the same as before events were named.
+func frame(id, evtType string, payload []byte) []byte {
+	var b bytes.Buffer
+	if id != "" {
+		b.WriteString("id: ")
+		b.WriteString(id)
+		b.WriteString("\n")
+	}
+	b.WriteString("event: ")
+	b.WriteString(evtType)
+	b.WriteString("\ndata: ")
+	b.Write(payload)
+	b.WriteString("\n\n")
+	return b.Bytes()
+}
@@ -0,0 +1,284 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"podcast-backend/internal/auth"
+)
+
+const testWait = 5 * time.Second
+
+// memBroker is an in-process Broker shared by the hubs of a test, standing in for
+// Redis pub/sub.
+type memBroker struct {
+	mu   sync.Mutex
+	subs map[int]func(Message)
+	next int
+}
+
+func newMemBroker() *memBroker { return &memBroker{subs: map[int]func(Message){}} }
+
+func (b *memBroker) Publish(_ context.Context, m *Message) error {
+	// a copy through JSON, as over the wire
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	subs := make([]func(Message), 0, len(b.subs))
+	for _, deliver := range b.subs {
+		subs = append(subs, deliver)
+	}
+	b.mu.Unlock()
+	for _, deliver := range subs {
+		var got Message
+		_ = json.Unmarshal(data, &got)
+		deliver(got)
+	}
+	return nil
+}
+
+func (b *memBroker) Subscribe(ctx context.Context, deliver func(Message)) error {
+	b.mu.Lock()
+	id := b.next
+	b.next++
+	b.subs[id] = deliver
+	b.mu.Unlock()
+	<-ctx.Done()
+	b.mu.Lock()
+	delete(b.subs, id)
+	b.mu.Unlock()
+	return ctx.Err()
+}
+
+func (b *memBroker) subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// hookStore runs beforeSince once, in the middle of a client's subscription: what
+// it sends is both queued live and found by the replay.
+type hookStore struct {
+	Store
+	once        sync.Once
+	beforeSince func()
+}
+
+func (s *hookStore) Since(ctx context.Context, lastID string) ([]Message, bool, error) {
+	if s.beforeSince != nil {
+		s.once.Do(s.beforeSince)
+	}
+	return s.Store.Since(ctx, lastID)
+}
+
+type sseEvent struct {
+	id, name, data string
+}
+
+// connect opens an SSE stream on srv and returns its events once it is subscribed.
+func connect(t *testing.T, ctx context.Context, srv *httptest.Server, token, lastID string) <-chan sseEvent {
+	t.Helper()
+	q := url.Values{"token": {token}}
+	if lastID != "" {
+		q.Set("lastEventId", lastID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/events?"+q.Encode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("connect: %s", resp.Status)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	// the hub writes ": connected" after registering the client
+	for lines.Scan() && lines.Text() != ": connected" {
+	}
+	events := make(chan sseEvent, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		var ev sseEvent
+		for lines.Scan() {
+			switch line := lines.Text(); {
+			case line == "":
+				if ev.name != "" {
+					events <- ev
+				}
+				ev = sseEvent{}
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.name = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}()
+	return events
+}
+
+// next waits for the next event on a stream.
+func next(t *testing.T, events <-chan sseEvent) sseEvent {
+	t.Helper()
+	select {
+	case ev, ok := <-events:
+		if !ok {
+			t.Fatal("stream closed")
+		}
+		return ev
+	case <-time.After(testWait):
+		t.Fatal("no event")
+	}
+	return sseEvent{}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(testWait)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// serve starts an instance's SSE endpoint. It is closed after the test's streams
+// are cancelled: Close waits for them.
+func serve(t *testing.T, h *Hub) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/events", h.Handler)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// testTwoHubs runs two instances sharing a store and a broker: what one publishes
+// reaches the clients of the other, and a client resuming with Last-Event-ID gets an
+// event that is both replayed and delivered live exactly once.
+func testTwoHubs(t *testing.T, storeA, storeB Store, brokerA, brokerB Broker, subscribed func() bool) {
+	jwt := auth.NewJWTService("test-secret", time.Hour)
+	token, err := jwt.Generate(1, "listener@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hookB := &hookStore{Store: storeB}
+	a := NewHub(jwt, storeA, brokerA)
+	b := NewHub(jwt, hookB, brokerB)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go a.Run(ctx)
+	go b.Run(ctx)
+	waitFor(t, "broker subscriptions", subscribed)
+
+	srvA, srvB := serve(t, a), serve(t, b)
+
+	onA := connect(t, ctx, srvA, token, "")
+	onB := connect(t, ctx, srvB, token, "")
+	a.Publish("first", map[string]int{"n": 1}, PodcastTopic(1))
+	first := next(t, onB)
+	if first.name != "first" || first.id == "" {
+		t.Fatalf("client on B got %+v, want first with an id", first)
+	}
+	if got := next(t, onA); got.id != first.id {
+		t.Fatalf("client on A got %+v, want %s", got, first.id)
+	}
+
+	hookB.beforeSince = func() { a.Publish("second", map[string]int{"n": 2}) }
+	resumed := connect(t, ctx, srvB, token, first.id)
+	a.Publish("third", map[string]int{"n": 3})
+	var names []string
+	for len(names) == 0 || names[len(names)-1] != "third" {
+		names = append(names, next(t, resumed).name)
+	}
+	if strings.Join(names, ",") != "second,third" {
+		t.Errorf("resumed client got %v, want [second third]", names)
+	}
+}
+
+func TestTwoHubs(t *testing.T) {
+	store := NewMemoryStore(100)
+	broker := newMemBroker()
+	testTwoHubs(t, store, store, broker, broker, func() bool { return broker.subscribers() == 2 })
+}
+
+// An instance whose own subscription is down still serves its local clients.
+func TestHubDeliversLocallyWithoutSubscription(t *testing.T) {
+	jwt := auth.NewJWTService("test-secret", time.Hour)
+	token, _ := jwt.Generate(1, "listener@example.com")
+	store := NewMemoryStore(100)
+	broker := newMemBroker()
+	a := NewHub(jwt, store, broker) // Run not started: no subscription
+	b := NewHub(jwt, store, broker)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+	waitFor(t, "broker subscription", func() bool { return broker.subscribers() == 1 })
+
+	srvA, srvB := serve(t, a), serve(t, b)
+	onA := connect(t, ctx, srvA, token, "")
+	onB := connect(t, ctx, srvB, token, "")
+
+	a.Publish("hello", nil)
+	if got := next(t, onA); got.name != "hello" {
+		t.Errorf("client on A got %+v", got)
+	}
+	if got := next(t, onB); got.name != "hello" {
+		t.Errorf("client on B got %+v", got)
+	}
+	// and B's own events do not come back to B twice
+	b.Publish("again", nil)
+	if got := next(t, onB); got.name != "again" {
+		t.Errorf("client on B got %+v", got)
+	}
+	select {
+	case ev := <-onB:
+		t.Errorf("client on B got %+v twice", ev)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestTwoHubsRedis runs the same scenario over Redis, at TEST_REDIS_ADDR.
+func TestTwoHubsRedis(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("redis at %s: %v", addr, err)
+	}
+	suffix := strconv.FormatInt(time.Now().UnixNano(), 36)
+	key, channel := "test:events:"+suffix, "test:fanout:"+suffix
+	defer client.Del(context.Background(), key)
+
+	subscribed := func() bool {
+		n, err := client.PubSubNumSub(context.Background(), channel).Result()
+		return err == nil && n[channel] == 2
+	}
+	testTwoHubs(t,
+		NewRedisStore(client, key, 100), NewRedisStore(client, key, 100),
+		NewRedisBroker(client, channel), NewRedisBroker(client, channel),
+		subscribed)
+}
@@ -0,0 +1,27 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"podcast-backend/internal/models"
+)
+
+// Consume sends an event from the outbox to SSE clients: private events to their
+// user's connections, others to the subscribers of their podcast and episode.
+func (h *Hub) Consume(_ context.Context, ev *models.OutboxEvent) error {
+	data := json.RawMessage(ev.Payload)
+	if ev.UserID != 0 {
+		h.SendToUser(ev.UserID, ev.Type, data)
+		return nil
+	}
+	var topics []string
+	if ev.PodcastID != 0 {
+		topics = append(topics, PodcastTopic(ev.PodcastID))
+	}
+	if ev.EpisodeID != 0 {
+		topics = append(topics, EpisodeTopic(ev.EpisodeID))
+	}
+	h.Publish(ev.Type, data, topics...)
+	return nil
+}
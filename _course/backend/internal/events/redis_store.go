@@ -0,0 +1,97 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps events in a capped Redis stream, so every instance shares one
+// sequence of IDs and a client can resume on any of them.
+type RedisStore struct {
+	redis *redis.Client
+	key   string
+	size  int64
+}
+
+func NewRedisStore(client *redis.Client, key string, size int) *RedisStore {
+	return &RedisStore{redis: client, key: key, size: int64(size)}
+}
+
+func (s *RedisStore) Append(ctx context.Context, m *Message) error {
+	id, err := s.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.key,
+		MaxLen: s.size,
+		Approx: true,
+		Values: map[string]interface{}{
+			"type":   m.Type,
+			"data":   []byte(m.Payload),
+			"topics": strings.Join(m.Topics, ","),
+			"user":   m.UserID,
+		},
+	}).Result()
+	if err != nil {
+		return err
+	}
+	m.ID = id
+	return nil
+}
+
+func (s *RedisStore) Since(ctx context.Context, lastID string) ([]Message, bool, error) {
+	last, ok := parseStreamID(lastID)
+	if !ok {
+		return nil, false, nil
+	}
+	oldest, err := s.redis.XRangeN(ctx, s.key, "-", "+", 1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	// complete when the stream still holds lastID or anything older
+	complete := true
+	if len(oldest) > 0 {
+		first, _ := parseStreamID(oldest[0].ID)
+		complete = !streamIDLess(last, first)
+	}
+	entries, err := s.redis.XRangeN(ctx, s.key, "("+lastID, "+", s.size).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	out := make([]Message, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, streamMessage(e))
+	}
+	return out, complete, nil
+}
+
+func streamMessage(e redis.XMessage) Message {
+	m := Message{ID: e.ID}
+	m.Type, _ = e.Values["type"].(string)
+	if data, ok := e.Values["data"].(string); ok {
+		m.Payload = []byte(data)
+	}
+	if topics, _ := e.Values["topics"].(string); topics != "" {
+		m.Topics = strings.Split(topics, ",")
+	}
+	if user, ok := e.Values["user"].(string); ok {
+		id, _ := strconv.ParseUint(user, 10, 64)
+		m.UserID = uint(id)
+	}
+	return m
+}
+
+// parseStreamID splits a "<ms>-<seq>" stream entry ID.
+func parseStreamID(id string) ([2]uint64, bool) {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return [2]uint64{}, false
+	}
+	a, err1 := strconv.ParseUint(ms, 10, 64)
+	b, err2 := strconv.ParseUint(seq, 10, 64)
+	return [2]uint64{a, b}, err1 == nil && err2 == nil
+}
+
+func streamIDLess(a, b [2]uint64) bool {
+	return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+}
@@ -0,0 +1,82 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Message is an event as stored for replay and sent between instances. UserID
+// targets one user's connections; otherwise Topics (if any) select the subscribers.
+type Message struct {
+	ID      string          `json:"id"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"` // JSON of Event
+	Topics  []string        `json:"topics,omitempty"`
+	UserID  uint            `json:"userId,omitempty"`
+	Origin  string          `json:"origin,omitempty"` // instance that sent it, see Hub.send
+}
+
+// matches reports whether the message is meant for the client.
+func (m *Message) matches(c *client) bool {
+	if m.UserID != 0 {
+		return c.userID == m.UserID
+	}
+	return len(m.Topics) == 0 || c.wants(m.Topics)
+}
+
+// Store assigns event IDs and keeps the latest events so reconnecting clients can
+// catch up from their Last-Event-ID.
+type Store interface {
+	// Append sets m.ID; IDs increase with every call.
+	Append(ctx context.Context, m *Message) error
+	// Since returns the stored events after lastID, oldest first. complete is false
+	// when some of them are gone or lastID is unknown, and the client should resync.
+	Since(ctx context.Context, lastID string) (msgs []Message, complete bool, err error)
+}
+
+// MemoryStore keeps the last events of this process in a ring buffer.
+type MemoryStore struct {
+	mu   sync.Mutex
+	buf  []Message
+	next int    // ring position of the next write
+	size int    // number of stored events
+	seq  uint64 // ID of the last stored event
+}
+
+// NewMemoryStore keeps up to size events. IDs start from the boot time in
+// microseconds, so after a restart they are above anything clients saw before.
+func NewMemoryStore(size int) *MemoryStore {
+	return &MemoryStore{buf: make([]Message, max(size, 1)), seq: uint64(time.Now().UnixMicro())}
+}
+
+func (s *MemoryStore) Append(_ context.Context, m *Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	m.ID = strconv.FormatUint(s.seq, 10)
+	s.buf[s.next] = *m
+	s.next = (s.next + 1) % len(s.buf)
+	if s.size < len(s.buf) {
+		s.size++
+	}
+	return nil
+}
+
+func (s *MemoryStore) Since(_ context.Context, lastID string) ([]Message, bool, error) {
+	last, err := strconv.ParseUint(lastID, 10, 64)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil || last > s.seq {
+		return nil, false, nil // an ID from another store or process
+	}
+	missed := int(min(s.seq-last, uint64(s.size)))
+	complete := s.seq-last <= uint64(s.size)
+	out := make([]Message, 0, missed)
+	for i := s.size - missed; i < s.size; i++ {
+		out = append(out, s.buf[(s.next-s.size+i+len(s.buf))%len(s.buf)])
+	}
+	return out, complete, nil
+}
@@ -0,0 +1,160 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"podcast-backend/internal/netguard"
+)
+
+const testETag = `"v1"`
+
+// feedServer serves the testdata fixtures with an ETag and answers 304 when it matches.
+// /broken responds 500.
+func feedServer(t *testing.T) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/broken" {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+		data, err := os.ReadFile("testdata" + r.URL.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", testETag)
+		w.Header().Set("Last-Modified", "Tue, 09 Jan 2024 08:00:00 GMT")
+		if r.Header.Get("If-None-Match") == testETag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestFetchRSS(t *testing.T) {
+	srv, _ := feedServer(t)
+	p, err := (&Fetcher{Client: srv.Client()}).Fetch(context.Background(), srv.URL+"/rss.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Test Cast" || p.Author != "Jane Doe" || p.Description != "A podcast for tests." {
+		t.Errorf("channel = %q by %q: %q", p.Title, p.Author, p.Description)
+	}
+	if p.Image != "https://example.com/cover.jpg" || p.Category != "Technology" || p.SelfURL != "https://example.com/feed.xml" {
+		t.Errorf("image %q, category %q, self %q", p.Image, p.Category, p.SelfURL)
+	}
+	if p.Language != "en-us" {
+		t.Errorf("language %q", p.Language)
+	}
+	if len(p.Items) != 2 {
+		t.Fatalf("got %d items, want 2", len(p.Items))
+	}
+	second := p.Items[0]
+	if second.GUID != "ep-2" || second.Title != "Second episode" || second.Duration != 3723 {
+		t.Errorf("first item = %+v", second)
+	}
+	if second.EnclosureURL != "https://example.com/ep2.mp3" || !second.PublishedAt.Equal(time.Date(2024, 1, 9, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("first item enclosure %q, published %v", second.EnclosureURL, second.PublishedAt)
+	}
+	first := p.Items[1]
+	// no guid: the enclosure stands in for it
+	if first.GUID != "https://example.com/ep1.mp3" || first.Title != "First episode" || first.Duration != 2730 {
+		t.Errorf("second item = %+v", first)
+	}
+	if first.Description != "<p>Where it starts.</p>" {
+		t.Errorf("second item description %q", first.Description)
+	}
+
+	pod := p.Podcast("")
+	if pod.FeedURL == nil || *pod.FeedURL != "https://example.com/feed.xml" {
+		t.Errorf("podcast feed url = %v, want the self link", pod.FeedURL)
+	}
+	if pod.Language != "en-us" {
+		t.Errorf("podcast language = %q", pod.Language)
+	}
+	if eps := p.Episodes(); len(eps) != 2 || eps[0].Date != "2024-01-09" || eps[0].AudioURL != second.EnclosureURL {
+		t.Errorf("episodes = %+v", eps)
+	}
+}
+
+func TestFetchAtom(t *testing.T) {
+	srv, _ := feedServer(t)
+	p, err := (&Fetcher{Client: srv.Client()}).Fetch(context.Background(), srv.URL+"/atom.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Title != "Atom Cast" || p.Author != "John Roe" || p.Description != "An Atom podcast for tests." {
+		t.Errorf("feed = %q by %q: %q", p.Title, p.Author, p.Description)
+	}
+	if p.Image != "https://example.org/logo.png" || p.Category != "News" || p.Language != "de" {
+		t.Errorf("image %q, category %q, language %q", p.Image, p.Category, p.Language)
+	}
+	if p.SelfURL != "https://example.org/atom.xml" || p.Link != "https://example.org/" {
+		t.Errorf("self %q, link %q", p.SelfURL, p.Link)
+	}
+	if len(p.Items) != 1 {
+		t.Fatalf("got %d entries, want 1", len(p.Items))
+	}
+	it := p.Items[0]
+	if it.GUID != "urn:uuid:1" || it.Title != "Only episode" || it.Description != "The one and only." || it.Duration != 600 {
+		t.Errorf("entry = %+v", it)
+	}
+	if it.EnclosureURL != "https://example.org/only.m4a" || !it.PublishedAt.Equal(time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("entry enclosure %q, published %v", it.EnclosureURL, it.PublishedAt)
+	}
+}
+
+func TestFetchConditional(t *testing.T) {
+	srv, requests := feedServer(t)
+	f := &Fetcher{Client: srv.Client()}
+
+	res, err := f.FetchConditional(context.Background(), srv.URL+"/rss.xml", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NotModified || res.Feed == nil || res.ETag != testETag || res.LastModified == "" {
+		t.Fatalf("first fetch = %+v", res)
+	}
+
+	res, err = f.FetchConditional(context.Background(), srv.URL+"/rss.xml", res.ETag, res.LastModified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.NotModified || res.Feed != nil {
+		t.Errorf("second fetch = %+v, want not modified", res)
+	}
+	if *requests != 2 {
+		t.Errorf("server got %d requests, want 2", *requests)
+	}
+}
+
+func TestFetchErrorStatus(t *testing.T) {
+	srv, _ := feedServer(t)
+	if _, err := (&Fetcher{Client: srv.Client()}).Fetch(context.Background(), srv.URL+"/broken"); err == nil {
+		t.Error("500 response: no error")
+	}
+}
+
+// The default fetcher takes URLs from users and must not reach internal services.
+func TestNewFetcherRefusesLoopback(t *testing.T) {
+	srv, requests := feedServer(t)
+	_, err := NewFetcher().Fetch(context.Background(), srv.URL+"/rss.xml")
+	if !errors.Is(err, netguard.ErrPrivateAddress) {
+		t.Errorf("err = %v, want %v", err, netguard.ErrPrivateAddress)
+	}
+	if *requests != 0 {
+		t.Errorf("server got %d requests", *requests)
+	}
+}
@@ -0,0 +1,74 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"time"
+)
+
+type OPML struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Head    OPMLHead  `xml:"head"`
+	Body    []Outline `xml:"body>outline"`
+}
+
+type OPMLHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	Outlines []Outline `xml:"outline"`
+}
+
+// NewOPML builds a subscription list document.
+func NewOPML(title string, outlines []Outline) *OPML {
+	return &OPML{
+		Version: "2.0",
+		Head:    OPMLHead{Title: title, DateCreated: time.Now().UTC().Format(time.RFC1123Z)},
+		Body:    outlines,
+	}
+}
+
+// Marshal encodes the document with the XML declaration prepended.
+func (o *OPML) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// ParseOPML reads an OPML document and returns its feed outlines flattened,
+// so category folders used by most podcast apps are transparently skipped.
+func ParseOPML(r io.Reader) ([]Outline, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var doc OPML
+	if err := newDecoder(data).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var out []Outline
+	var walk func([]Outline)
+	walk = func(items []Outline) {
+		for _, o := range items {
+			children := o.Outlines
+			if o.XMLURL != "" || (len(children) == 0 && strings.TrimSpace(o.Text+o.Title) != "") {
+				o.Outlines = nil
+				out = append(out, o)
+			}
+			walk(children)
+		}
+	}
+	walk(doc.Body)
+	return out, nil
+}
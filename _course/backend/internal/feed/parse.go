@@ -0,0 +1,361 @@
+package feed
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+
+	"podcast-backend/internal/models"
+)
+
+// ErrUnsupportedFormat is returned when the document is neither RSS nor Atom.
+var ErrUnsupportedFormat = errors.New("unsupported feed format")
+
+// Parsed is a format-independent view of an external podcast feed.
+type Parsed struct {
+	Title       string
+	Author      string
+	Description string
+	Link        string
+	SelfURL     string
+	Image       string
+	Category    string
+	Language    string
+	Items       []ParsedItem
+}
+
+type ParsedItem struct {
+	GUID         string
+	Title        string
+	Description  string
+	PublishedAt  time.Time
+	Duration     int // seconds
+	EnclosureURL string
+}
+
+// Namespaced fields must precede their plain counterparts: encoding/xml assigns an
+// element to the first field whose tag matches, and a tag without namespace matches any.
+type rssDoc struct {
+	Channel struct {
+		AtomLinks []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"http://www.w3.org/2005/Atom link"`
+		ItunesAuthor string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd author"`
+		ItunesImage  struct {
+			Href string `xml:"href,attr"`
+		} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd image"`
+		ItunesCategory []struct {
+			Text string `xml:"text,attr"`
+		} `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd category"`
+		ItunesSummary string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+		Title         string `xml:"title"`
+		Link          string `xml:"link"`
+		Description   string `xml:"description"`
+		Language      string `xml:"language"`
+		Image         struct {
+			URL string `xml:"url"`
+		} `xml:"image"`
+		Category []string `xml:"category"`
+		Items    []struct {
+			ItunesTitle    string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd title"`
+			ItunesSummary  string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+			ItunesDuration string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+			Content        string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+			Title          string `xml:"title"`
+			Description    string `xml:"description"`
+			GUID           string `xml:"guid"`
+			PubDate        string `xml:"pubDate"`
+			Enclosure      struct {
+				URL string `xml:"url,attr"`
+			} `xml:"enclosure"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomDoc struct {
+	Lang     string     `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Title    string     `xml:"title"`
+	Subtitle string     `xml:"subtitle"`
+	Logo     string     `xml:"logo"`
+	Icon     string     `xml:"icon"`
+	Links    []atomLink `xml:"link"`
+	Author   struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Category []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+	Entries []struct {
+		Duration  string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+		ID        string     `xml:"id"`
+		Title     string     `xml:"title"`
+		Summary   string     `xml:"summary"`
+		Content   string     `xml:"content"`
+		Published string     `xml:"published"`
+		Updated   string     `xml:"updated"`
+		Links     []atomLink `xml:"link"`
+	} `xml:"entry"`
+}
+
+// Parse reads an RSS 2.0 or Atom document. Items without a guid get a stable
+// synthetic one so that repeated imports de-duplicate correctly.
+func Parse(r io.Reader) (*Parsed, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := rootElement(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed *Parsed
+	switch root {
+	case "rss":
+		parsed, err = parseRSS(data)
+	case "feed":
+		parsed, err = parseAtom(data)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range parsed.Items {
+		it := &parsed.Items[i]
+		if it.GUID == "" {
+			it.GUID = syntheticGUID(it)
+		}
+	}
+	return parsed, nil
+}
+
+func newDecoder(data []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	dec.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		enc, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported charset %q", label)
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}
+	return dec
+}
+
+func rootElement(data []byte) (string, error) {
+	dec := newDecoder(data)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				return "", ErrUnsupportedFormat
+			}
+			return "", err
+		}
+		if se, ok := tok.(xml.StartElement); ok {
+			return se.Name.Local, nil
+		}
+	}
+}
+
+func parseRSS(data []byte) (*Parsed, error) {
+	var doc rssDoc
+	if err := newDecoder(data).Decode(&doc); err != nil {
+		return nil, err
+	}
+	ch := doc.Channel
+	p := &Parsed{
+		Title:       strings.TrimSpace(ch.Title),
+		Author:      strings.TrimSpace(ch.ItunesAuthor),
+		Description: strings.TrimSpace(firstNonEmpty(ch.Description, ch.ItunesSummary)),
+		Link:        strings.TrimSpace(ch.Link),
+		Image:       strings.TrimSpace(firstNonEmpty(ch.ItunesImage.Href, ch.Image.URL)),
+		Language:    strings.TrimSpace(ch.Language),
+	}
+	for _, l := range ch.AtomLinks {
+		if l.Rel == "self" {
+			p.SelfURL = strings.TrimSpace(l.Href)
+		}
+	}
+	for _, c := range ch.ItunesCategory {
+		if c.Text != "" {
+			p.Category = c.Text
+			break
+		}
+	}
+	if p.Category == "" {
+		p.Category = strings.TrimSpace(firstNonEmpty(ch.Category...))
+	}
+
+	for _, it := range ch.Items {
+		p.Items = append(p.Items, ParsedItem{
+			GUID:         strings.TrimSpace(it.GUID),
+			Title:        strings.TrimSpace(firstNonEmpty(it.Title, it.ItunesTitle)),
+			Description:  strings.TrimSpace(firstNonEmpty(it.Description, it.ItunesSummary, it.Content)),
+			PublishedAt:  parseTime(it.PubDate),
+			Duration:     ParseDuration(it.ItunesDuration),
+			EnclosureURL: strings.TrimSpace(it.Enclosure.URL),
+		})
+	}
+	return p, nil
+}
+
+func parseAtom(data []byte) (*Parsed, error) {
+	var doc atomDoc
+	if err := newDecoder(data).Decode(&doc); err != nil {
+		return nil, err
+	}
+	p := &Parsed{
+		Title:       strings.TrimSpace(doc.Title),
+		Author:      strings.TrimSpace(doc.Author.Name),
+		Description: strings.TrimSpace(doc.Subtitle),
+		Image:       strings.TrimSpace(firstNonEmpty(doc.Logo, doc.Icon)),
+		Language:    strings.TrimSpace(doc.Lang),
+	}
+	for _, l := range doc.Links {
+		switch l.Rel {
+		case "self":
+			p.SelfURL = strings.TrimSpace(l.Href)
+		case "", "alternate":
+			p.Link = strings.TrimSpace(l.Href)
+		}
+	}
+	if len(doc.Category) > 0 {
+		p.Category = doc.Category[0].Term
+	}
+
+	for _, e := range doc.Entries {
+		item := ParsedItem{
+			GUID:        strings.TrimSpace(e.ID),
+			Title:       strings.TrimSpace(e.Title),
+			Description: strings.TrimSpace(firstNonEmpty(e.Summary, e.Content)),
+			PublishedAt: parseTime(firstNonEmpty(e.Published, e.Updated)),
+			Duration:    ParseDuration(e.Duration),
+		}
+		for _, l := range e.Links {
+			if l.Rel == "enclosure" {
+				item.EnclosureURL = strings.TrimSpace(l.Href)
+				break
+			}
+		}
+		p.Items = append(p.Items, item)
+	}
+	return p, nil
+}
+
+// ParseDuration accepts itunes:duration in "HH:MM:SS", "MM:SS" or plain seconds.
+func ParseDuration(raw string) int {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0
+	}
+	total := 0
+	for _, part := range strings.Split(raw, ":") {
+		n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || n < 0 {
+			return 0
+		}
+		total = total*60 + int(n)
+	}
+	return total
+}
+
+var timeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+func parseTime(raw string) time.Time {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func syntheticGUID(it *ParsedItem) string {
+	if it.EnclosureURL != "" {
+		return it.EnclosureURL
+	}
+	sum := sha1.Sum([]byte(it.Title + "|" + it.PublishedAt.UTC().Format(time.RFC3339)))
+	return "sha1:" + hex.EncodeToString(sum[:])
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// Podcast maps the channel metadata onto a new podcast; ownership is set by the caller.
+func (p *Parsed) Podcast(feedURL string) models.Podcast {
+	pod := models.Podcast{
+		Title:       p.Title,
+		Author:      p.Author,
+		Description: p.Description,
+		Language:    p.Language,
+	}
+	if p.Image != "" {
+		pod.Image = &p.Image
+	}
+	if p.Category != "" {
+		pod.Category = &p.Category
+	}
+	if feedURL = firstNonEmpty(feedURL, p.SelfURL); feedURL != "" {
+		pod.FeedURL = &feedURL
+	}
+	return pod
+}
+
+// Episodes maps feed items onto episodes, keeping the item guid for de-duplication.
+func (p *Parsed) Episodes() []models.Episode {
+	episodes := make([]models.Episode, 0, len(p.Items))
+	for _, it := range p.Items {
+		guid := it.GUID
+		ep := models.Episode{
+			GUID:        &guid,
+			Title:       it.Title,
+			Description: it.Description,
+			Duration:    it.Duration,
+			AudioURL:    it.EnclosureURL,
+			Status:      models.EpisodePublished,
+		}
+		if !it.PublishedAt.IsZero() {
+			ep.Date = it.PublishedAt.Format("2006-01-02")
+			publishAt := it.PublishedAt
+			ep.PublishAt = &publishAt
+		}
+		episodes = append(episodes, ep)
+	}
+	return episodes
+}
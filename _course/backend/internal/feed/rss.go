@@ -0,0 +1,252 @@
+package feed
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+	"time"
+
+	"podcast-backend/internal/categories"
+	"podcast-backend/internal/models"
+)
+
+const (
+	nsItunes  = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+	nsPodcast = "https://podcastindex.org/namespace/1.0"
+	nsAtom    = "http://www.w3.org/2005/Atom"
+	nsContent = "http://purl.org/rss/1.0/modules/content/"
+)
+
+// podcastNamespaceGUID is the UUIDv5 namespace defined by Podcasting 2.0 for <podcast:guid>.
+var podcastNamespaceGUID = [16]byte{0xea, 0xd4, 0xc2, 0x36, 0xbf, 0x58, 0x58, 0xc6, 0xa2, 0xc6, 0xa6, 0xb2, 0x8d, 0x12, 0x8c, 0xb6}
+
+type RSS struct {
+	XMLName      xml.Name `xml:"rss"`
+	Version      string   `xml:"version,attr"`
+	XmlnsItunes  string   `xml:"xmlns:itunes,attr"`
+	XmlnsPodcast string   `xml:"xmlns:podcast,attr"`
+	XmlnsAtom    string   `xml:"xmlns:atom,attr"`
+	XmlnsContent string   `xml:"xmlns:content,attr"`
+	Channel      Channel  `xml:"channel"`
+}
+
+type Channel struct {
+	Title          string          `xml:"title"`
+	Link           string          `xml:"link"`
+	AtomLink       AtomLink        `xml:"atom:link"`
+	Description    string          `xml:"description"`
+	Language       string          `xml:"language,omitempty"`
+	Generator      string          `xml:"generator"`
+	LastBuildDate  string          `xml:"lastBuildDate"`
+	Image          *Image          `xml:"image,omitempty"`
+	ItunesAuthor   string          `xml:"itunes:author"`
+	ItunesSummary  string          `xml:"itunes:summary"`
+	ItunesOwner    ItunesOwner     `xml:"itunes:owner"`
+	ItunesImage    *ItunesImage    `xml:"itunes:image,omitempty"`
+	ItunesCategory *ItunesCategory `xml:"itunes:category,omitempty"`
+	ItunesExplicit string          `xml:"itunes:explicit"`
+	ItunesType     string          `xml:"itunes:type"`
+	PodcastGUID    string          `xml:"podcast:guid"`
+	PodcastLocked  PodcastLocked   `xml:"podcast:locked"`
+	Items          []Item          `xml:"item"`
+}
+
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type Image struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type ItunesOwner struct {
+	Name  string `xml:"itunes:name"`
+	Email string `xml:"itunes:email"`
+}
+
+type ItunesImage struct {
+	Href string `xml:"href,attr"`
+}
+
+type ItunesCategory struct {
+	Text string          `xml:"text,attr"`
+	Sub  *ItunesCategory `xml:"itunes:category,omitempty"`
+}
+
+type PodcastLocked struct {
+	Owner string `xml:"owner,attr,omitempty"`
+	Value string `xml:",chardata"`
+}
+
+type Item struct {
+	Title             string     `xml:"title"`
+	Description       string     `xml:"description"`
+	Content           *CDATA     `xml:"content:encoded,omitempty"`
+	GUID              GUID       `xml:"guid"`
+	PubDate           string     `xml:"pubDate"`
+	Enclosure         *Enclosure `xml:"enclosure,omitempty"`
+	ItunesTitle       string     `xml:"itunes:title"`
+	ItunesDuration    string     `xml:"itunes:duration,omitempty"`
+	ItunesExplicit    string     `xml:"itunes:explicit"`
+	ItunesEpisodeType string     `xml:"itunes:episodeType"`
+}
+
+type CDATA struct {
+	Value string `xml:",cdata"`
+}
+
+type GUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type Enclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// Build renders a podcast and its episodes as an RSS 2.0 document in the podcast's language.
+// selfURL is the absolute URL the feed is served from, siteURL is the public page of the show.
+func Build(p *models.Podcast, episodes []models.Episode, selfURL, siteURL string, lastModified time.Time) *RSS {
+	ch := Channel{
+		Title:          p.Title,
+		Link:           siteURL,
+		AtomLink:       AtomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+		Description:    p.Description,
+		Language:       p.Language,
+		Generator:      "podcast-backend",
+		LastBuildDate:  lastModified.UTC().Format(time.RFC1123Z),
+		ItunesAuthor:   p.Author,
+		ItunesSummary:  p.Description,
+		ItunesOwner:    ItunesOwner{Name: p.Author, Email: p.AuthorEmail},
+		ItunesExplicit: "false",
+		ItunesType:     "episodic",
+		PodcastGUID:    PodcastGUID(p.ID),
+		PodcastLocked:  PodcastLocked{Owner: p.AuthorEmail, Value: "no"},
+	}
+	if p.Image != nil && *p.Image != "" {
+		ch.Image = &Image{URL: *p.Image, Title: p.Title, Link: siteURL}
+		ch.ItunesImage = &ItunesImage{Href: *p.Image}
+	}
+	// directories only accept their own categories: free-form ones are mapped onto the
+	// tree, and left out when they match nothing
+	if p.Category != nil {
+		if name, sub, ok := categories.ITunes(categories.Match(*p.Category)); ok {
+			ch.ItunesCategory = &ItunesCategory{Text: name}
+			if sub != "" {
+				ch.ItunesCategory.Sub = &ItunesCategory{Text: sub}
+			}
+		}
+	}
+
+	for _, ep := range episodes {
+		item := Item{
+			Title:             ep.Title,
+			Description:       ep.Description,
+			GUID:              GUID{IsPermaLink: "false", Value: episodeGUID(p, &ep)},
+			PubDate:           episodePubDate(&ep).Format(time.RFC1123Z),
+			ItunesTitle:       ep.Title,
+			ItunesExplicit:    "false",
+			ItunesEpisodeType: "full",
+		}
+		if ep.Description != "" {
+			item.Content = &CDATA{Value: ep.Description}
+		}
+		if ep.Duration > 0 {
+			item.ItunesDuration = FormatDuration(ep.Duration)
+		}
+		if ep.AudioURL != "" {
+			item.Enclosure = &Enclosure{URL: ep.AudioURL, Length: ep.AudioSize, Type: ep.AudioType}
+			if item.Enclosure.Type == "" {
+				item.Enclosure.Type = audioType(ep.AudioURL)
+			}
+		}
+		ch.Items = append(ch.Items, item)
+	}
+
+	return &RSS{
+		Version:      "2.0",
+		XmlnsItunes:  nsItunes,
+		XmlnsPodcast: nsPodcast,
+		XmlnsAtom:    nsAtom,
+		XmlnsContent: nsContent,
+		Channel:      ch,
+	}
+}
+
+// Marshal encodes the feed with the XML declaration prepended.
+func (r *RSS) Marshal() ([]byte, error) {
+	body, err := xml.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// FormatDuration renders seconds as HH:MM:SS as expected by itunes:duration.
+func FormatDuration(seconds int) string {
+	h := seconds / 3600
+	m := (seconds % 3600) / 60
+	s := seconds % 60
+	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+}
+
+// PodcastGUID derives the Podcasting 2.0 channel GUID of a podcast: a UUIDv5 of its id,
+// so it does not change with the domain or scheme the feed is served from.
+func PodcastGUID(podcastID uint) string {
+	h := sha1.New()
+	h.Write(podcastNamespaceGUID[:])
+	fmt.Fprintf(h, "podcast-%d", podcastID)
+	sum := h.Sum(nil)
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func episodeGUID(p *models.Podcast, ep *models.Episode) string {
+	if ep.GUID != nil && *ep.GUID != "" {
+		return *ep.GUID
+	}
+	return fmt.Sprintf("podcast-%d-episode-%d", p.ID, ep.ID)
+}
+
+// episodePubDate uses the author-provided date when it parses, then the publication
+// time, then creation time.
+func episodePubDate(ep *models.Episode) time.Time {
+	if ep.Date != "" {
+		for _, layout := range []string{"2006-01-02", time.RFC3339, time.RFC1123Z, time.RFC1123} {
+			if t, err := time.Parse(layout, ep.Date); err == nil {
+				return t
+			}
+		}
+	}
+	if ep.PublishAt != nil {
+		return *ep.PublishAt
+	}
+	return ep.CreatedAt
+}
+
+func audioType(audioURL string) string {
+	u := audioURL
+	if i := strings.IndexAny(u, "?#"); i >= 0 {
+		u = u[:i]
+	}
+	if t := mime.TypeByExtension(strings.ToLower(path.Ext(u))); strings.HasPrefix(t, "audio/") {
+		return t
+	}
+	switch strings.ToLower(path.Ext(u)) {
+	case ".m4a":
+		return "audio/mp4"
+	case ".ogg", ".oga":
+		return "audio/ogg"
+	}
+	return "audio/mpeg"
+}
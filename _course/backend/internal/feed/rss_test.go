@@ -0,0 +1,53 @@
+package feed
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"podcast-backend/internal/models"
+)
+
+// The channel GUID belongs to the podcast, not to the host the feed was requested on.
+func TestBuildPodcastGUID(t *testing.T) {
+	p := &models.Podcast{ID: 7, Title: "Show"}
+	a := Build(p, nil, "https://podcasts.example.com/api/podcasts/7/feed.xml", "https://podcasts.example.com/podcasts/7", time.Now())
+	b := Build(p, nil, "http://evil.example/api/podcasts/7/feed.xml", "http://evil.example/podcasts/7", time.Now())
+	if a.Channel.PodcastGUID != b.Channel.PodcastGUID {
+		t.Errorf("GUID changed with the host: %s, %s", a.Channel.PodcastGUID, b.Channel.PodcastGUID)
+	}
+	if g := PodcastGUID(8); g == a.Channel.PodcastGUID || len(g) != 36 || g[14] != '5' {
+		t.Errorf("PodcastGUID(8) = %s", g)
+	}
+}
+
+// Directories want the Apple category, with the subcategory nested under its parent.
+func TestBuildCategory(t *testing.T) {
+	for _, raw := range []string{"Mental Health", "психология"} {
+		ch := Build(&models.Podcast{ID: 1, Category: &raw}, nil, "", "", time.Now()).Channel
+		c := ch.ItunesCategory
+		if c == nil || c.Text != "Health & Fitness" || c.Sub == nil || c.Sub.Text != "Mental Health" {
+			t.Errorf("%q: itunes:category = %+v", raw, c)
+		}
+	}
+	top := "History"
+	if c := Build(&models.Podcast{ID: 1, Category: &top}, nil, "", "", time.Now()).Channel.ItunesCategory; c == nil || c.Text != "History" || c.Sub != nil {
+		t.Errorf("History: itunes:category = %+v", c)
+	}
+	unknown := "something else"
+	if c := Build(&models.Podcast{ID: 1, Category: &unknown}, nil, "", "", time.Now()).Channel.ItunesCategory; c != nil {
+		t.Errorf("unknown category mapped to %+v", c)
+	}
+
+	out, err := xml.Marshal(Build(&models.Podcast{ID: 1, Category: &top, Language: "en"}, nil, "", "", time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), `<itunes:category text="History"></itunes:category>`) || !strings.Contains(string(out), "<language>en</language>") {
+		t.Errorf("feed = %s", out)
+	}
+	if out, _ := xml.Marshal(Build(&models.Podcast{ID: 1}, nil, "", "", time.Now())); strings.Contains(string(out), "<language>") {
+		t.Errorf("language without one set: %s", out)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+)
+
+const (
+	maxPlayEventsPerRequest = 50
+	defaultAnalyticsDays    = 30
+	maxAnalyticsDays        = 366
+)
+
+// botAgent matches crawlers, monitors and HTTP libraries; their plays are not counted.
+var botAgent = regexp.MustCompile(`(?i)bot|crawl|spider|slurp|preview|monitor|headless|curl|wget|python-|go-http-client|okhttp|java/|libwww`)
+
+// AnalyticsHandler ingests play events and serves the author dashboards.
+type AnalyticsHandler struct {
+	repo *repository.PodcastRepository
+}
+
+func NewAnalyticsHandler(repo *repository.PodcastRepository) *AnalyticsHandler {
+	return &AnalyticsHandler{repo: repo}
+}
+
+// Register mounts the author routes; it expects authenticated routes.
+func (h *AnalyticsHandler) Register(r gin.IRoutes) {
+	r.GET("/api/me/analytics", h.overview)
+	r.GET("/api/me/analytics/podcasts/:id", h.podcast)
+}
+
+type playEventRequest struct {
+	EpisodeID uint    `json:"episodeId"`
+	Type      string  `json:"type"`     // start, progress or complete
+	Position  float64 `json:"position"` // seconds
+}
+
+// Ingest accepts {"events": [...]} from players, signed in or not, and answers 202
+// with the number of events that were counted.
+func (h *AnalyticsHandler) Ingest(c *gin.Context) {
+	var req struct {
+		Events []playEventRequest `json:"events"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if len(req.Events) == 0 || len(req.Events) > maxPlayEventsPerRequest {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "events must hold 1 to " + strconv.Itoa(maxPlayEventsPerRequest) + " items"})
+		return
+	}
+	events := make([]models.PlayEvent, 0, len(req.Events))
+	for _, ev := range req.Events {
+		switch ev.Type {
+		case models.PlayStart, models.PlayProgress, models.PlayComplete:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event type"})
+			return
+		}
+		events = append(events, models.PlayEvent{EpisodeID: ev.EpisodeID, Type: ev.Type, Position: int(ev.Position)})
+	}
+
+	ua := c.Request.UserAgent()
+	if ua == "" || botAgent.MatchString(ua) {
+		c.JSON(http.StatusAccepted, gin.H{"accepted": 0})
+		return
+	}
+	accepted, err := h.repo.RecordPlayEvents(c.Request.Context(), playClient(c, ua), events)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"accepted": accepted})
+}
+
+// playClient keys signed-in listeners by user and anonymous ones by a hash of IP and
+// user agent; raw addresses are never stored.
+func playClient(c *gin.Context, ua string) repository.PlayClient {
+	ip := c.ClientIP()
+	client := repository.PlayClient{ClientKey: shortHash(ip)}
+	if userID := c.GetUint("userID"); userID != 0 {
+		client.UserID = &userID
+		client.ListenerKey = "u:" + strconv.FormatUint(uint64(userID), 10)
+	} else {
+		client.ListenerKey = "a:" + shortHash(ip+"|"+ua)
+	}
+	return client
+}
+
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// overview lists totals for each of the caller's podcasts.
+// Query: from/to (YYYY-MM-DD, inclusive, or RFC 3339), tz (IANA name); default is the last 30 days.
+func (h *AnalyticsHandler) overview(c *gin.Context) {
+	rng, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+	stats, err := h.repo.AuthorAnalytics(c.Request.Context(), c.GetUint("userID"), rng)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// podcast returns totals, per-episode numbers and a daily series for one podcast.
+func (h *AnalyticsHandler) podcast(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	rng, ok := analyticsRange(c)
+	if !ok {
+		return
+	}
+	stats, err := h.repo.PodcastAnalytics(c.Request.Context(), id, c.GetUint("userID"), rng)
+	if err != nil {
+		if err.Error() == "forbidden" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if stats == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func analyticsRange(c *gin.Context) (repository.AnalyticsRange, bool) {
+	rng := repository.AnalyticsRange{TZ: "UTC"}
+	loc := time.UTC
+	if tz := c.Query("tz"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tz"})
+			return rng, false
+		}
+		loc, rng.TZ = l, tz
+	}
+	now := time.Now().In(loc)
+	rng.To = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	var err error
+	if v := c.Query("to"); v != "" {
+		if rng.To, err = parseHistoryTime(v, loc, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return rng, false
+		}
+	}
+	rng.From = rng.To.AddDate(0, 0, -defaultAnalyticsDays)
+	if v := c.Query("from"); v != "" {
+		if rng.From, err = parseHistoryTime(v, loc, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return rng, false
+		}
+	}
+	if !rng.From.Before(rng.To) || rng.To.Sub(rng.From) > maxAnalyticsDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "range must be 1 to " + strconv.Itoa(maxAnalyticsDays) + " days"})
+		return rng, false
+	}
+	return rng, true
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/audio"
+	"podcast-backend/internal/jobs"
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+	"podcast-backend/internal/storage"
+)
+
+// sniffLen is how much of an upload is inspected to detect its real type.
+const sniffLen = 3072
+
+type AudioHandler struct {
+	episodes  *repository.EpisodeRepository
+	store     storage.Storage
+	waveforms *jobs.WaveformGenerator
+	maxSize   int64
+}
+
+func NewAudioHandler(episodes *repository.EpisodeRepository, store storage.Storage, waveforms *jobs.WaveformGenerator, maxSize int64) *AudioHandler {
+	return &AudioHandler{episodes: episodes, store: store, waveforms: waveforms, maxSize: maxSize}
+}
+
+// Register mounts streaming routes. They are public for published episodes; r should
+// carry AuthOptional so authors can also fetch their drafts and scheduled episodes.
+func (h *AudioHandler) Register(r gin.IRoutes) {
+	r.GET("/api/episodes/:id/audio", h.stream)
+	r.HEAD("/api/episodes/:id/audio", h.stream)
+	r.GET("/api/episodes/:id/waveform", h.waveform)
+}
+
+// Upload accepts a multipart "file" with episode audio, stores it and points AudioURL at the stream endpoint.
+func (h *AudioHandler) Upload(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ep, err := h.episodes.GetForAuthor(ctx, id, userID)
+	if err != nil {
+		if err.Error() == "forbidden" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if ep == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	// multipart overhead is small compared to audio, allow 1MB on top of the limit
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+1<<20)
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if file.Size > h.maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "file is too large"})
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+		return
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, _ := io.ReadFull(f, head)
+	mt := mimetype.Detect(head[:n])
+	contentType := mt.String()
+	if !isAudio(contentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "not an audio file: " + contentType})
+		return
+	}
+
+	// probing is best effort: formats we can't parse are still accepted
+	var probe *audio.ProbeResult
+	if _, err := f.Seek(0, io.SeekStart); err == nil {
+		if probe, err = audio.Probe(f, file.Size); err != nil {
+			log.Printf("audio: probe episode %d: %v", ep.ID, err)
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	key := fmt.Sprintf("episodes/%d/%s%s", ep.ID, randomToken(), mt.Extension())
+	if err := h.store.Put(ctx, key, f, file.Size, contentType); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	oldKey := ep.AudioKey
+	ep.AudioKey = key
+	ep.AudioSize = file.Size
+	ep.AudioType = contentType
+	ep.AudioURL = fmt.Sprintf("%s/api/episodes/%d/audio", baseURL(c), ep.ID)
+	if probe != nil {
+		if probe.Seconds() > 0 {
+			ep.Duration = probe.Seconds()
+		}
+		if ep.Title == "" {
+			ep.Title = probe.Title
+		}
+		if ep.Description == "" {
+			ep.Description = probe.Comment
+		}
+	}
+	if err := h.episodes.SaveAudio(ctx, ep); err != nil {
+		_ = h.store.Delete(ctx, key)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if oldKey != "" && oldKey != key {
+		if err := h.store.Delete(ctx, oldKey); err != nil {
+			log.Printf("audio: delete old object %s: %v", oldKey, err)
+		}
+	}
+	h.queueWaveform(c, ep)
+	c.JSON(http.StatusOK, gin.H{"episode": ep, "probe": probe})
+}
+
+// stream serves uploaded audio with Range support so players can seek.
+func (h *AudioHandler) stream(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ep, public, err := h.episodes.GetForViewer(ctx, id, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if ep == nil || ep.AudioKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	obj, info, err := h.store.Open(ctx, ep.AudioKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer obj.Close()
+
+	contentType := ep.AudioType
+	if contentType == "" {
+		contentType = info.ContentType
+	}
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("Cache-Control", cacheControl(public, 86400))
+	http.ServeContent(c.Writer, c.Request, ep.AudioKey, info.LastModified, obj)
+}
+
+// waveform serves generated peaks, or reports the status while they are pending or failed.
+func (h *AudioHandler) waveform(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	ep, public, err := h.episodes.GetForViewer(ctx, id, c.GetUint("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if ep == nil || ep.AudioKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	w, err := h.episodes.Waveform(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if w == nil || w.AudioKey != ep.AudioKey {
+		// audio uploaded before waveforms were generated
+		if w = h.queueWaveform(c, ep); w == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue waveform"})
+			return
+		}
+	}
+
+	switch w.Status {
+	case models.WaveformPending:
+		c.Header("Retry-After", "5")
+		c.JSON(http.StatusAccepted, gin.H{"status": w.Status})
+		return
+	case models.WaveformFailed:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"status": w.Status, "error": w.Error})
+		return
+	}
+
+	obj, info, err := h.store.Open(ctx, w.Key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	defer obj.Close()
+	c.Header("Content-Type", "application/json")
+	c.Header("Cache-Control", cacheControl(public, 300))
+	http.ServeContent(c.Writer, c.Request, w.Key, info.LastModified, obj)
+}
+
+// queueWaveform schedules peak generation for the episode's current audio. Failures
+// are only logged: the waveform endpoint queues again on demand.
+func (h *AudioHandler) queueWaveform(c *gin.Context, ep *models.Episode) *models.Waveform {
+	w, err := h.episodes.QueueWaveform(c.Request.Context(), ep.ID, ep.AudioKey)
+	if err != nil {
+		log.Printf("audio: queue waveform for episode %d: %v", ep.ID, err)
+		return nil
+	}
+	h.waveforms.Trigger()
+	return w
+}
+
+// cacheControl lets shared caches keep what every listener may fetch; what only the
+// author sees so far stays in their browser.
+func cacheControl(public bool, maxAge int) string {
+	if public {
+		return fmt.Sprintf("public, max-age=%d", maxAge)
+	}
+	return fmt.Sprintf("private, max-age=%d", maxAge)
+}
+
+func isAudio(contentType string) bool {
+	return strings.HasPrefix(contentType, "audio/") && !strings.HasPrefix(contentType, "audio/midi")
+}
+
+func randomToken() string {
+	b := make([]byte, 8)
+	_, _ = io.ReadFull(rand.Reader, b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (h *PodcastHandler) categories(c *gin.Context) {
+	tree, err := h.repo.Categories(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tree)
+}
+
+// categoryPodcasts lists a category like /podcasts?category=<slug>, with a 404 for
+// unknown slugs instead of an empty page.
+func (h *PodcastHandler) categoryPodcasts(c *gin.Context) {
+	category, err := h.repo.CategoryBySlug(c.Request.Context(), c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if category == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	q, ok := podcastQuery(c)
+	if !ok {
+		return
+	}
+	q.Category = category.Slug
+	h.listPodcasts(c, q)
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+)
+
+const defaultChartPeriod = "week"
+
+func (h *PodcastHandler) episodeChart(c *gin.Context) {
+	h.chart(c, models.ChartEpisodes)
+}
+
+func (h *PodcastHandler) podcastChart(c *gin.Context) {
+	h.chart(c, models.ChartPodcasts)
+}
+
+// chart serves ?period=day|week|month (default week), ?category=<slug> and ?limit.
+func (h *PodcastHandler) chart(c *gin.Context, kind string) {
+	period := c.DefaultQuery("period", defaultChartPeriod)
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = repository.DefaultPageSize
+	}
+	items, err := h.repo.Chart(c.Request.Context(), kind, period, c.Query("category"), limit)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
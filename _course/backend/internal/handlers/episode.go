@@ -1,29 +1,31 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
-	"podcast-backend/internal/events"
+	"podcast-backend/internal/jobs"
 	"podcast-backend/internal/models"
 	"podcast-backend/internal/repository"
 )
 
 type EpisodeHandler struct {
 	repo *repository.EpisodeRepository
-	events *events.Hub
+	publisher *jobs.EpisodePublisher
 }
 
-func NewEpisodeHandler(repo *repository.EpisodeRepository, hub *events.Hub) *EpisodeHandler {
-	return &EpisodeHandler{repo: repo, events: hub}
+func NewEpisodeHandler(repo *repository.EpisodeRepository, publisher *jobs.EpisodePublisher) *EpisodeHandler {
+	return &EpisodeHandler{repo: repo, publisher: publisher}
 }
 
 // Register expects a router group already mounted at "/api"
 func (h *EpisodeHandler) Register(r *gin.RouterGroup) {
 	r.PUT("/episodes/:id", h.update)
 	r.DELETE("/episodes/:id", h.delete)
+	r.PUT("/episodes/:id/tags", h.setTags)
 	r.POST("/episodes/:id/like", h.toggleLike)
 	r.GET("/me/episode-likes", h.myLikes)
 }
@@ -47,6 +49,10 @@ func (h *EpisodeHandler) update(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 			return
 		}
+		if errors.Is(err, repository.ErrInvalidSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -55,9 +61,8 @@ func (h *EpisodeHandler) update(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, updated)
-	// notify clients that episode metadata changed
-	if h.events != nil {
-		h.events.Broadcast("episode_updated", updated)
+	if updated.Status == models.EpisodeScheduled && h.publisher != nil {
+		h.publisher.Trigger()
 	}
 }
 
@@ -82,9 +87,6 @@ func (h *EpisodeHandler) delete(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusNoContent, nil)
-	if h.events != nil {
-		h.events.Broadcast("episode_deleted", gin.H{"episodeId": id})
-	}
 }
 
 func (h *EpisodeHandler) toggleLike(c *gin.Context) {
@@ -97,13 +99,14 @@ func (h *EpisodeHandler) toggleLike(c *gin.Context) {
 	}
 	count, _, err := h.repo.ToggleLike(ctx, id, userID)
 	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"likes": count})
-	if h.events != nil {
-		h.events.Broadcast("episode_likes", gin.H{"episodeId": id, "likes": count})
-	}
 }
 
 func (h *EpisodeHandler) myLikes(c *gin.Context) {
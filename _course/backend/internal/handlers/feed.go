@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/feed"
+)
+
+// feed serves the podcast as an RSS 2.0 / iTunes feed on /api/podcasts/:id/feed.xml.
+func (h *PodcastHandler) feed(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	state, err := h.repo.FeedState(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if state == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	lastModified := state.UpdatedAt.UTC().Truncate(time.Second)
+	etag := fmt.Sprintf(`W/"%d-%d-%d"`, id, lastModified.Unix(), state.Episodes)
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	c.Header("Cache-Control", "public, max-age=300")
+	if notModified(c.Request, etag, lastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	podcast, err := h.repo.Get(ctx, id, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if podcast == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	episodes, err := h.repo.Episodes(ctx, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if podcast.Language == "" {
+		podcast.Language = h.feedLanguage
+	}
+	base := baseURL(c)
+	selfURL := fmt.Sprintf("%s/api/podcasts/%d/feed.xml", base, id)
+	siteURL := fmt.Sprintf("%s/podcasts/%d", base, id)
+	body, err := feed.Build(podcast, episodes, selfURL, siteURL, lastModified).Marshal()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", body)
+}
+
+// notModified evaluates If-None-Match first and falls back to If-Modified-Since (RFC 7232).
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		if t, err := http.ParseTime(ims); err == nil && !lastModified.After(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// baseURL is the public origin absolute links are built on: PUBLIC_URL when configured,
+// otherwise the origin the request was made to. X-Forwarded-* headers are ignored, as
+// any client can send them and feeds are cached publicly.
+func baseURL(c *gin.Context) string {
+	if base := c.GetString("publicURL"); base != "" {
+		return base
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// publicHost is the host part of baseURL.
+func publicHost(c *gin.Context) string {
+	if u, err := url.Parse(baseURL(c)); err == nil {
+		return u.Host
+	}
+	return c.Request.Host
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+type historyDay struct {
+	Date    string                `json:"date"` // YYYY-MM-DD in the requested time zone
+	Entries []models.HistoryEntry `json:"entries"`
+}
+
+// history lists the caller's listening history grouped by day.
+// Query: podcastId, from/to (YYYY-MM-DD, inclusive, or RFC 3339), tz (IANA name), limit, cursor.
+func (h *UserContentHandler) history(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+
+	loc := time.UTC
+	if tz := c.Query("tz"); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tz"})
+			return
+		}
+		loc = l
+	}
+	f := repository.HistoryFilter{Limit: defaultHistoryLimit}
+	var err error
+	if v := c.Query("podcastId"); v != "" {
+		if f.PodcastID, err = parseID(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid podcastId"})
+			return
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		if f.From, err = parseHistoryTime(v, loc, false); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if f.To, err = parseHistoryTime(v, loc, true); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+			return
+		}
+	}
+	if v := c.Query("cursor"); v != "" {
+		if f.Before, err = parseID(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		f.Limit = min(n, maxHistoryLimit)
+	}
+
+	limit := f.Limit
+	f.Limit++ // one extra row tells whether there is a next page
+	entries, err := h.content.History(ctx, userID, f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var next *uint
+	if len(entries) > limit {
+		entries = entries[:limit]
+		next = &entries[limit-1].ID
+	}
+
+	days := []historyDay{}
+	for _, e := range entries {
+		date := e.CreatedAt.In(loc).Format("2006-01-02")
+		if n := len(days); n == 0 || days[n-1].Date != date {
+			days = append(days, historyDay{Date: date})
+		}
+		days[len(days)-1].Entries = append(days[len(days)-1].Entries, e)
+	}
+	c.JSON(http.StatusOK, gin.H{"days": days, "nextCursor": next})
+}
+
+// parseHistoryTime accepts a calendar date in loc or an RFC 3339 timestamp. An end
+// date is inclusive, so it is moved to the start of the following day.
+func parseHistoryTime(v string, loc *time.Location, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", v, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+func (h *UserContentHandler) deleteHistoryEntry(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	found, err := h.content.DeleteHistoryEntry(ctx, userID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *UserContentHandler) clearHistory(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	n, err := h.content.ClearHistory(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": n})
+}
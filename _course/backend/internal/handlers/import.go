@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/feed"
+)
+
+type importRequest struct {
+	URL string `json:"url" form:"url"`
+}
+
+// Import creates a podcast for the caller from an external RSS/Atom feed,
+// either fetched by URL or uploaded as a multipart "file".
+func (h *PodcastHandler) Import(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	userEmail := c.GetString("userEmail")
+
+	var (
+		parsed  *feed.Parsed
+		feedURL string
+		err     error
+	)
+	if file, ferr := c.FormFile("file"); ferr == nil {
+		if file.Size > feed.MaxFeedSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": feed.ErrFeedTooLarge.Error()})
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+			return
+		}
+		defer f.Close()
+		parsed, err = feed.Parse(io.LimitReader(f, feed.MaxFeedSize))
+		if err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		var req importRequest
+		if err := c.ShouldBind(&req); err != nil || strings.TrimSpace(req.URL) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "url or file is required"})
+			return
+		}
+		feedURL = strings.TrimSpace(req.URL)
+		parsed, err = h.fetcher.Fetch(ctx, feedURL)
+		if err != nil {
+			status := http.StatusBadGateway
+			if errors.Is(err, feed.ErrUnsupportedFormat) {
+				status = http.StatusUnprocessableEntity
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if parsed.Title == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "feed has no title"})
+		return
+	}
+
+	podcast := parsed.Podcast(feedURL)
+	podcast.AuthorID = userID
+	podcast.AuthorEmail = userEmail
+	if podcast.Author == "" {
+		podcast.Author = userEmail
+	}
+
+	result, err := h.repo.Import(ctx, &podcast, parsed.Episodes())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, result)
+}
+
+// FeedStatus shows the author when an imported feed was last synced and when it runs next.
+func (h *PodcastHandler) FeedStatus(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	src, err := h.repo.FeedSource(ctx, id, userID)
+	if err != nil {
+		if err.Error() == "forbidden" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if src == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, src)
+}
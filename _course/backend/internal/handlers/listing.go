@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/repository"
+)
+
+// nextCursorHeader carries the cursor of the next page; list bodies stay plain arrays.
+const nextCursorHeader = "X-Next-Cursor"
+
+// didYouMeanHeader carries a corrected query when a search returning a plain array
+// found nothing.
+const didYouMeanHeader = "X-Did-You-Mean"
+
+// podcastQuery reads the listing parameters shared by /podcasts and /podcasts/search.
+// It writes a 400 response and returns false on invalid input.
+func podcastQuery(c *gin.Context) (repository.PodcastQuery, bool) {
+	q := repository.PodcastQuery{
+		Search:   strings.TrimSpace(c.Query("q")),
+		Category: c.Query("category"),
+		Author:   c.Query("author"),
+		Sort:     c.Query("sort"),
+		Cursor:   c.Query("cursor"),
+	}
+	if v := c.Query("authorId"); v != "" {
+		id, err := parseID(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid authorId"})
+			return q, false
+		}
+		q.AuthorID = id
+		q.Owner = id == c.GetUint("userID")
+	}
+	for _, inc := range strings.Split(c.Query("include"), ",") {
+		if inc == "episodes" {
+			q.IncludeEpisodes = true
+		}
+	}
+	var ok bool
+	q.Limit, ok = pageLimit(c)
+	return q, ok
+}
+
+// pageLimit parses ?limit; zero means the repository default.
+func pageLimit(c *gin.Context) (int, bool) {
+	v := c.Query("limit")
+	if v == "" {
+		return 0, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+		return 0, false
+	}
+	return min(n, repository.MaxPageSize), true
+}
+
+func setNextCursor(c *gin.Context, next string) {
+	if next != "" {
+		c.Header(nextCursorHeader, next)
+	}
+}
+
+func listError(c *gin.Context, err error) {
+	if errors.Is(err, repository.ErrInvalidCursor) || errors.Is(err, repository.ErrInvalidSort) ||
+		errors.Is(err, repository.ErrInvalidPeriod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/feed"
+	"podcast-backend/internal/models"
+)
+
+const maxOPMLSize = 5 << 20
+
+var ownFeedPath = regexp.MustCompile(`^/api/podcasts/(\d+)/feed\.xml$`)
+
+type opmlEntry struct {
+	Text      string `json:"text"`
+	XMLURL    string `json:"xmlUrl,omitempty"`
+	PodcastID uint   `json:"podcastId,omitempty"`
+}
+
+func (h *UserContentHandler) libraryOPML(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	items, err := h.content.Library(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeOPML(c, "Library", "library.opml", items)
+}
+
+func (h *UserContentHandler) favoritesOPML(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	items, err := h.content.Favorites(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	writeOPML(c, "Favorites", "favorites.opml", items)
+}
+
+// importOPML adds every outline that matches a known podcast to the caller's library.
+// The document is accepted as a multipart "file" or as the raw request body.
+func (h *UserContentHandler) importOPML(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+
+	var body io.Reader = c.Request.Body
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file"})
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+	outlines, err := feed.ParseOPML(io.LimitReader(body, maxOPMLSize))
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "invalid opml: " + err.Error()})
+		return
+	}
+
+	matched := []opmlEntry{}
+	unmatched := []opmlEntry{}
+	var ids []uint
+	seen := map[uint]bool{}
+	for _, o := range outlines {
+		text := o.Text
+		if text == "" {
+			text = o.Title
+		}
+		entry := opmlEntry{Text: text, XMLURL: o.XMLURL}
+
+		podcast, err := h.content.MatchPodcast(ctx, userID, ownPodcastID(c, o.XMLURL), o.XMLURL, strings.TrimSpace(text))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if podcast == nil {
+			unmatched = append(unmatched, entry)
+			continue
+		}
+		entry.PodcastID = podcast.ID
+		matched = append(matched, entry)
+		if !seen[podcast.ID] {
+			seen[podcast.ID] = true
+			ids = append(ids, podcast.ID)
+		}
+	}
+
+	added, err := h.content.AddToLibrary(ctx, userID, ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"added": added, "matched": matched, "unmatched": unmatched})
+}
+
+func writeOPML(c *gin.Context, title, filename string, podcasts []models.Podcast) {
+	base := baseURL(c)
+	outlines := make([]feed.Outline, 0, len(podcasts))
+	for _, p := range podcasts {
+		xmlURL := fmt.Sprintf("%s/api/podcasts/%d/feed.xml", base, p.ID)
+		if p.FeedURL != nil && *p.FeedURL != "" {
+			xmlURL = *p.FeedURL
+		}
+		outlines = append(outlines, feed.Outline{
+			Text:    p.Title,
+			Title:   p.Title,
+			Type:    "rss",
+			XMLURL:  xmlURL,
+			HTMLURL: fmt.Sprintf("%s/podcasts/%d", base, p.ID),
+		})
+	}
+	body, err := feed.NewOPML(title, outlines).Marshal()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "text/x-opml; charset=utf-8", body)
+}
+
+// ownPodcastID recognises feed urls served by this instance and returns the podcast id.
+func ownPodcastID(c *gin.Context, rawURL string) uint {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || !strings.EqualFold(u.Host, publicHost(c)) {
+		return 0
+	}
+	m := ownFeedPath.FindStringSubmatch(u.Path)
+	if m == nil {
+		return 0
+	}
+	id, err := strconv.ParseUint(m[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+)
+
+// podcastWithCounts is a library or favorites entry with the caller's unplayed counts.
+type podcastWithCounts struct {
+	models.Podcast
+	repository.PodcastCounts
+}
+
+func (h *UserContentHandler) withCounts(c *gin.Context, podcasts []models.Podcast) ([]podcastWithCounts, error) {
+	ids := make([]uint, len(podcasts))
+	for i, p := range podcasts {
+		ids[i] = p.ID
+	}
+	counts, err := h.content.PodcastCounts(c.Request.Context(), c.GetUint("userID"), ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]podcastWithCounts, len(podcasts))
+	for i, p := range podcasts {
+		out[i] = podcastWithCounts{Podcast: p, PodcastCounts: counts[p.ID]}
+	}
+	return out, nil
+}
+
+func (h *UserContentHandler) markPlayed(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	episodeID, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	found, err := h.content.MarkPlayed(ctx, userID, episodeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"played": true})
+}
+
+func (h *UserContentHandler) markUnplayed(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	episodeID, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.content.MarkUnplayed(ctx, userID, episodeID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"played": false})
+}
+
+func (h *UserContentHandler) markPodcastPlayed(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	podcastID, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	n, err := h.content.MarkPodcastPlayed(ctx, userID, podcastID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"marked": n})
+}
+
+// playedEpisodes returns ids of played episodes, optionally filtered by ?podcastId=.
+func (h *UserContentHandler) playedEpisodes(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	var podcastID uint
+	if v := c.Query("podcastId"); v != "" {
+		id, err := parseID(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid podcastId"})
+			return
+		}
+		podcastID = id
+	}
+	ids, err := h.content.PlayedEpisodeIDs(ctx, userID, podcastID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ids)
+}
+
+// visit is called when the user opens a podcast page and resets its "new" count.
+func (h *UserContentHandler) visit(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	podcastID, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.content.RecordVisit(ctx, userID, podcastID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
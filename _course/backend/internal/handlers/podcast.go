@@ -1,32 +1,50 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"podcast-backend/internal/feed"
+	"podcast-backend/internal/jobs"
 	"podcast-backend/internal/repository"
 	"podcast-backend/internal/models"
 )
 
 type PodcastHandler struct {
-	repo *repository.PodcastRepository
+	repo         *repository.PodcastRepository
+	fetcher      *feed.Fetcher
+	publisher    *jobs.EpisodePublisher
+	feedLanguage string // for podcasts without a language of their own
 }
 
-func NewPodcastHandler(repo *repository.PodcastRepository) *PodcastHandler {
-	return &PodcastHandler{repo: repo}
+func NewPodcastHandler(repo *repository.PodcastRepository, fetcher *feed.Fetcher, publisher *jobs.EpisodePublisher, feedLanguage string) *PodcastHandler {
+	return &PodcastHandler{repo: repo, fetcher: fetcher, publisher: publisher, feedLanguage: feedLanguage}
 }
 
-func (h *PodcastHandler) Register(r *gin.Engine) {
+// Register mounts the public routes. With optional auth in front, authors also see
+// their drafts and scheduled episodes in get and episodes.
+func (h *PodcastHandler) Register(r gin.IRouter) {
 	api := r.Group("/api")
 	{
 		api.GET("/health", h.health)
 		api.GET("/podcasts", h.list)
 		api.GET("/podcasts/search", h.search)
+		api.GET("/podcasts/suggest", h.suggest)
+		api.GET("/search", h.fullTextSearch)
+		api.GET("/categories", h.categories)
+		api.GET("/categories/:slug/podcasts", h.categoryPodcasts)
+		api.GET("/tags", h.tagCloud)
+		api.GET("/tags/:slug/episodes", h.tagEpisodes)
+		api.GET("/charts/episodes", h.episodeChart)
+		api.GET("/charts/podcasts", h.podcastChart)
 		api.GET("/podcasts/:id", h.get)
 		api.GET("/podcasts/:id/episodes", h.episodes)
+		api.GET("/podcasts/:id/similar", h.similar)
+		api.GET("/podcasts/:id/feed.xml", h.feed)
 	}
 }
 
@@ -35,28 +53,44 @@ func (h *PodcastHandler) health(c *gin.Context) {
 }
 
 func (h *PodcastHandler) list(c *gin.Context) {
-	ctx := c.Request.Context()
-	podcasts, err := h.repo.List(ctx)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	q, ok := podcastQuery(c)
+	if !ok {
 		return
 	}
-	c.JSON(http.StatusOK, podcasts)
+	h.listPodcasts(c, q)
 }
 
 func (h *PodcastHandler) search(c *gin.Context) {
-	ctx := c.Request.Context()
-	q := c.Query("q")
-	if q == "" {
+	q, ok := podcastQuery(c)
+	if !ok {
+		return
+	}
+	if q.Search == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
 		return
 	}
-	podcasts, err := h.repo.Search(ctx, q)
+	page, err := h.repo.List(c.Request.Context(), q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		listError(c, err)
+		return
+	}
+	setNextCursor(c, page.NextCursor)
+	if len(page.Items) == 0 && q.Cursor == "" {
+		if fix := h.didYouMean(c.Request.Context(), q.Search); fix != "" {
+			c.Header(didYouMeanHeader, fix)
+		}
+	}
+	c.JSON(http.StatusOK, page.Items)
+}
+
+func (h *PodcastHandler) listPodcasts(c *gin.Context, q repository.PodcastQuery) {
+	page, err := h.repo.List(c.Request.Context(), q)
+	if err != nil {
+		listError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, podcasts)
+	setNextCursor(c, page.NextCursor)
+	c.JSON(http.StatusOK, page.Items)
 }
 
 func (h *PodcastHandler) get(c *gin.Context) {
@@ -66,7 +100,7 @@ func (h *PodcastHandler) get(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	podcast, err := h.repo.Get(ctx, id)
+	podcast, err := h.repo.Get(ctx, id, c.GetUint("userID"))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -94,6 +128,10 @@ func (h *PodcastHandler) Create(c *gin.Context) {
 	req.AuthorID = userID
 	req.AuthorEmail = userEmail
 	if err := h.repo.Create(ctx, &req); err != nil {
+		if errors.Is(err, repository.ErrInvalidVisibility) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -119,6 +157,10 @@ func (h *PodcastHandler) Update(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 			return
 		}
+		if errors.Is(err, repository.ErrInvalidVisibility) || errors.Is(err, repository.ErrInvalidSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -155,12 +197,22 @@ func (h *PodcastHandler) episodes(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 		return
 	}
-	episodes, err := h.repo.Episodes(ctx, id)
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+	q := repository.EpisodeQuery{Sort: c.Query("sort"), Cursor: c.Query("cursor"), Limit: limit}
+	page, err := h.repo.ListEpisodes(ctx, id, c.GetUint("userID"), q)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		listError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, episodes)
+	if page == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	setNextCursor(c, page.NextCursor)
+	c.JSON(http.StatusOK, page.Items)
 }
 
 func (h *PodcastHandler) AddEpisode(c *gin.Context) {
@@ -190,10 +242,17 @@ func (h *PodcastHandler) AddEpisode(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
 			return
 		}
+		if errors.Is(err, repository.ErrInvalidSchedule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusCreated, created)
+	if created.Status == models.EpisodeScheduled && h.publisher != nil {
+		h.publisher.Trigger()
+	}
 }
 
 func parseID(raw string) (uint, error) {
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/repository"
+)
+
+const (
+	defaultContinueLimit = 20
+	maxContinueLimit     = 100
+)
+
+type ProgressHandler struct {
+	repo *repository.PodcastRepository
+}
+
+func NewProgressHandler(repo *repository.PodcastRepository) *ProgressHandler {
+	return &ProgressHandler{repo: repo}
+}
+
+// Register mounts authenticated playback routes.
+func (h *ProgressHandler) Register(r gin.IRoutes) {
+	r.GET("/api/episodes/:id/progress", h.get)
+	r.PUT("/api/episodes/:id/progress", h.save)
+	r.GET("/api/me/continue-listening", h.continueListening)
+}
+
+type progressRequest struct {
+	Position  *float64 `json:"position"`
+	Completed bool     `json:"completed"`
+}
+
+func (h *ProgressHandler) get(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	p, err := h.repo.Progress(ctx, userID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if p == nil {
+		c.JSON(http.StatusOK, gin.H{"episodeId": id, "position": 0, "completed": false})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+// save is called by players every few seconds, so it stays cheap: see PodcastRepository.SaveProgress.
+func (h *ProgressHandler) save(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req progressRequest
+	if err := c.BindJSON(&req); err != nil || req.Position == nil || *req.Position < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "position is required"})
+		return
+	}
+	p, err := h.repo.SaveProgress(ctx, userID, id, int(*req.Position), req.Completed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if p == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *ProgressHandler) continueListening(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	limit := defaultContinueLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = min(n, maxContinueLimit)
+	}
+	items, err := h.repo.ContinueListening(ctx, userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
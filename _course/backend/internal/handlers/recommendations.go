@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/repository"
+)
+
+const defaultRecommendations = 20
+
+// RecommendationHandler serves the signed-in user's recommendations.
+type RecommendationHandler struct {
+	repo *repository.PodcastRepository
+}
+
+func NewRecommendationHandler(repo *repository.PodcastRepository) *RecommendationHandler {
+	return &RecommendationHandler{repo: repo}
+}
+
+// Register expects authenticated routes.
+func (h *RecommendationHandler) Register(r gin.IRoutes) {
+	r.GET("/api/me/recommendations", h.recommendations)
+}
+
+func (h *RecommendationHandler) recommendations(c *gin.Context) {
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = defaultRecommendations
+	}
+	items, err := h.repo.Recommendations(c.Request.Context(), c.GetUint("userID"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+func (h *PodcastHandler) similar(c *gin.Context) {
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+	if limit == 0 {
+		limit = defaultRecommendations
+	}
+	items, err := h.repo.Similar(c.Request.Context(), id, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if items == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
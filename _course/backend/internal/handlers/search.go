@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/repository"
+)
+
+const (
+	suggestTimeout   = 300 * time.Millisecond
+	searchFacetLimit = 15
+)
+
+// fullTextSearch serves /api/search: podcasts and episodes ranked together, with
+// <mark>-highlighted title and snippet HTML (the source text is escaped), in
+// {results, facets, didYouMean}. When nothing matches, didYouMean carries a corrected
+// query. ?tag=<slug> narrows episodes to a tag, as offered by the tag facets of the
+// first page.
+func (h *PodcastHandler) fullTextSearch(c *gin.Context) {
+	q, ok := searchQuery(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	results, err := h.repo.FullTextSearch(ctx, q)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	resp := gin.H{"results": results}
+	if q.Offset == 0 {
+		// facets cover every match, so they are only sent with the first page
+		facets, err := h.repo.SearchTagFacets(ctx, q, searchFacetLimit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		resp["facets"] = gin.H{"tags": facets}
+	}
+	if len(results) == 0 && q.Offset == 0 {
+		if fix := h.didYouMean(ctx, q.Text); fix != "" {
+			resp["didYouMean"] = fix
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// searchQuery reads the full-text search parameters. It writes a 400 response and
+// returns false on invalid input.
+func searchQuery(c *gin.Context) (repository.SearchQuery, bool) {
+	q := repository.SearchQuery{Text: strings.TrimSpace(c.Query("q")), Type: c.Query("type"), Tag: c.Query("tag")}
+	if q.Text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return q, false
+	}
+	if q.Type != "" && q.Type != "podcast" && q.Type != "episode" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be podcast or episode"})
+		return q, false
+	}
+	if q.Tag != "" && q.Type == "podcast" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag only applies to episodes"})
+		return q, false
+	}
+	var ok bool
+	if q.Limit, ok = pageLimit(c); !ok {
+		return q, false
+	}
+	if v := c.Query("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+			return q, false
+		}
+		q.Offset = n
+	}
+	return q, true
+}
+
+// didYouMean returns a corrected query for a search that found nothing, or "". A
+// failed suggestion must not fail the search itself, so errors are only logged.
+func (h *PodcastHandler) didYouMean(ctx context.Context, text string) string {
+	fix, err := h.repo.DidYouMean(ctx, text)
+	if err != nil {
+		log.Printf("search: did you mean %q: %v", text, err)
+		return ""
+	}
+	return fix
+}
+
+// suggest serves autocomplete for the search box. It answers within suggestTimeout
+// or with an empty list: a slow suggestion is useless once the user typed on.
+func (h *PodcastHandler) suggest(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusOK, []repository.Suggestion{})
+		return
+	}
+	limit := repository.DefaultSuggestions
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = min(n, repository.MaxSuggestions)
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), suggestTimeout)
+	defer cancel()
+	suggestions, err := h.repo.Suggest(ctx, text, limit)
+	if err != nil {
+		if ctx.Err() == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("search: suggest %q timed out", text)
+		suggestions = []repository.Suggestion{}
+	}
+	c.JSON(http.StatusOK, suggestions)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"podcast-backend/internal/repository"
+)
+
+const (
+	defaultTagCloud = 50
+	maxTagCloud     = 200
+)
+
+// setTags replaces an episode's tags: PUT {"tags": ["Guest Name", "AI"]}.
+func (h *EpisodeHandler) setTags(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req struct {
+		Tags []string `json:"tags"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	if len(req.Tags) > repository.MaxEpisodeTags {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many tags"})
+		return
+	}
+	for _, t := range req.Tags {
+		if utf8.RuneCountInString(t) > repository.MaxTagLength || repository.TagSlug(t) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tag: " + t})
+			return
+		}
+	}
+	tags, err := h.repo.SetEpisodeTags(ctx, id, req.Tags, userID)
+	if err != nil {
+		if err.Error() == "forbidden" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tags == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+// tagCloud returns the most used tags, across the catalogue or for ?podcastId.
+func (h *PodcastHandler) tagCloud(c *gin.Context) {
+	var podcastID uint
+	if v := c.Query("podcastId"); v != "" {
+		id, err := parseID(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid podcastId"})
+			return
+		}
+		podcastID = id
+	}
+	limit := defaultTagCloud
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = min(n, maxTagCloud)
+	}
+	counts, err := h.repo.TagCounts(c.Request.Context(), podcastID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
+
+// tagEpisodes lists episodes with a tag, paginated like /podcasts/:id/episodes.
+func (h *PodcastHandler) tagEpisodes(c *gin.Context) {
+	ctx := c.Request.Context()
+	tag, err := h.repo.Tag(ctx, c.Param("slug"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tag == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	limit, ok := pageLimit(c)
+	if !ok {
+		return
+	}
+	q := repository.EpisodeQuery{Sort: c.Query("sort"), Cursor: c.Query("cursor"), Limit: limit}
+	page, err := h.repo.ListTagEpisodes(ctx, tag.ID, q)
+	if err != nil {
+		listError(c, err)
+		return
+	}
+	setNextCursor(c, page.NextCursor)
+	c.JSON(http.StatusOK, page.Items)
+}
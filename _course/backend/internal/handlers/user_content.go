@@ -22,12 +22,31 @@ func (h *UserContentHandler) Register(r gin.IRoutes) {
 
 	r.GET("/api/me/library", h.library)
 	r.POST("/api/podcasts/:id/library", h.toggleLibrary)
+
+	r.GET("/api/me/library.opml", h.libraryOPML)
+	r.GET("/api/me/favorites.opml", h.favoritesOPML)
+	r.POST("/api/me/library.opml", h.importOPML)
+
+	r.POST("/api/episodes/:id/played", h.markPlayed)
+	r.DELETE("/api/episodes/:id/played", h.markUnplayed)
+	r.POST("/api/podcasts/:id/played", h.markPodcastPlayed)
+	r.POST("/api/podcasts/:id/visit", h.visit)
+	r.GET("/api/me/played-episodes", h.playedEpisodes)
+
+	r.GET("/api/me/history", h.history)
+	r.DELETE("/api/me/history", h.clearHistory)
+	r.DELETE("/api/me/history/:id", h.deleteHistoryEntry)
 }
 
 func (h *UserContentHandler) favorites(c *gin.Context) {
 	ctx := c.Request.Context()
 	userID := c.GetUint("userID")
-	items, err := h.content.Favorites(ctx, userID)
+	podcasts, err := h.content.Favorites(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	items, err := h.withCounts(c, podcasts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -54,7 +73,12 @@ func (h *UserContentHandler) toggleFavorite(c *gin.Context) {
 func (h *UserContentHandler) library(c *gin.Context) {
 	ctx := c.Request.Context()
 	userID := c.GetUint("userID")
-	items, err := h.content.Library(ctx, userID)
+	podcasts, err := h.content.Library(ctx, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	items, err := h.withCounts(c, podcasts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
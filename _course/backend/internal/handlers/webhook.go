@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/jobs"
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+type WebhookHandler struct {
+	repo       *repository.WebhookRepository
+	dispatcher *jobs.WebhookDispatcher
+}
+
+func NewWebhookHandler(repo *repository.WebhookRepository, dispatcher *jobs.WebhookDispatcher) *WebhookHandler {
+	return &WebhookHandler{repo: repo, dispatcher: dispatcher}
+}
+
+// Register expects a router group already mounted at "/api" behind AuthRequired.
+func (h *WebhookHandler) Register(r *gin.RouterGroup) {
+	r.GET("/podcasts/:id/webhooks", h.list)
+	r.POST("/podcasts/:id/webhooks", h.create)
+	r.PUT("/webhooks/:id", h.update)
+	r.DELETE("/webhooks/:id", h.delete)
+	r.GET("/webhooks/:id/deliveries", h.deliveries)
+	r.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", h.redeliver)
+}
+
+// webhookRequest is the body of create and update. Without events the webhook gets
+// every event; without a secret one is generated on create and kept on update;
+// without active it is created active and keeps its state on update.
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+func (req *webhookRequest) webhook() *models.Webhook {
+	w := &models.Webhook{URL: req.URL, Secret: req.Secret, Events: req.Events, Active: true}
+	if req.Active != nil {
+		w.Active = *req.Active
+	}
+	return w
+}
+
+// webhookWithSecret is how a webhook is returned on create, the only time its
+// secret is shown.
+type webhookWithSecret struct {
+	*models.Webhook
+	Secret string `json:"secret"`
+}
+
+func (h *WebhookHandler) list(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	hooks, err := h.repo.Webhooks(ctx, id, userID)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	if hooks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (h *WebhookHandler) create(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req webhookRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	w, err := h.repo.CreateWebhook(ctx, id, userID, req.webhook())
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	if w == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusCreated, webhookWithSecret{Webhook: w, Secret: w.Secret})
+}
+
+func (h *WebhookHandler) update(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var req webhookRequest
+	if err := c.BindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	w, err := h.repo.UpdateWebhook(ctx, id, userID, req.webhook(), req.Active != nil)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	if w == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, w)
+}
+
+func (h *WebhookHandler) delete(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	if err := h.repo.DeleteWebhook(ctx, id, userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		webhookError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// deliveries returns the delivery log of a webhook, newest first.
+// Query: limit, cursor (the id of the last delivery seen); X-Next-Cursor is set when there are more.
+func (h *WebhookHandler) deliveries(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	var before uint
+	if v := c.Query("cursor"); v != "" {
+		if before, err = parseID(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+	}
+	limit := defaultDeliveryLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = min(n, maxDeliveryLimit)
+	}
+
+	// one extra row tells whether there is a next page
+	deliveries, err := h.repo.Deliveries(ctx, id, userID, before, limit+1)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	if deliveries == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+		c.Header(nextCursorHeader, strconv.FormatUint(uint64(deliveries[limit-1].ID), 10))
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// redeliver sends the payload of an earlier delivery again, as a new delivery.
+func (h *WebhookHandler) redeliver(c *gin.Context) {
+	ctx := c.Request.Context()
+	userID := c.GetUint("userID")
+	id, err := parseID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	deliveryID, err := parseID(c.Param("deliveryId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid delivery id"})
+		return
+	}
+	d, err := h.repo.Redeliver(ctx, id, deliveryID, userID)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+	if d == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if h.dispatcher != nil {
+		h.dispatcher.Trigger()
+	}
+	c.JSON(http.StatusAccepted, d)
+}
+
+func webhookError(c *gin.Context, err error) {
+	switch {
+	case err.Error() == "forbidden":
+		c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+	case errors.Is(err, repository.ErrInvalidWebhook):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"podcast-backend/internal/repository"
+)
+
+// ChartBuilder recomputes the trending charts on an interval.
+type ChartBuilder struct {
+	repo     *repository.PodcastRepository
+	interval time.Duration
+}
+
+func NewChartBuilder(repo *repository.PodcastRepository, interval time.Duration) *ChartBuilder {
+	return &ChartBuilder{repo: repo, interval: interval}
+}
+
+// Run builds the charts right away, so they are not empty after a deploy, and then
+// on every tick until ctx is cancelled.
+func (b *ChartBuilder) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		if err := b.repo.RebuildCharts(ctx); err != nil {
+			log.Printf("charts: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"podcast-backend/internal/feed"
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+)
+
+const (
+	feedBatchSize  = 20
+	maxFeedBackoff = 24 * time.Hour
+	// feedLease is how long claimed feeds are kept from other instances; a batch is
+	// fetched well within it (each feed gets a minute at most).
+	feedLease = 30 * time.Minute
+)
+
+// FeedRefresher periodically re-fetches imported feeds and applies new episodes and metadata.
+type FeedRefresher struct {
+	repo     *repository.PodcastRepository
+	fetcher  *feed.Fetcher
+	interval time.Duration
+	tick     time.Duration
+}
+
+func NewFeedRefresher(repo *repository.PodcastRepository, fetcher *feed.Fetcher, interval time.Duration) *FeedRefresher {
+	tick := time.Minute
+	if interval < tick {
+		tick = interval
+	}
+	return &FeedRefresher{repo: repo, fetcher: fetcher, interval: interval, tick: tick}
+}
+
+// Run polls due feeds until ctx is cancelled.
+func (f *FeedRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.tick)
+	defer ticker.Stop()
+	for {
+		f.runDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (f *FeedRefresher) runDue(ctx context.Context) {
+	sources, err := f.repo.ClaimFeedSources(ctx, time.Now(), feedLease, feedBatchSize)
+	if err != nil {
+		log.Printf("feed refresh: claim due feeds: %v", err)
+		return
+	}
+	for i := range sources {
+		if ctx.Err() != nil {
+			return
+		}
+		f.refresh(ctx, &sources[i])
+	}
+}
+
+func (f *FeedRefresher) refresh(ctx context.Context, src *models.FeedSource) {
+	now := time.Now()
+	src.LastFetchedAt = &now
+
+	if err := f.sync(ctx, src); err != nil {
+		src.LastError = err.Error()
+		src.Failures++
+		src.NextRunAt = now.Add(f.backoff(src.Failures))
+		log.Printf("feed refresh: podcast %d: %v", src.PodcastID, err)
+	} else {
+		src.LastError = ""
+		src.Failures = 0
+		src.NextRunAt = now.Add(f.interval)
+	}
+	if err := f.repo.SaveFeedSource(ctx, src); err != nil {
+		log.Printf("feed refresh: save status for podcast %d: %v", src.PodcastID, err)
+	}
+}
+
+func (f *FeedRefresher) sync(ctx context.Context, src *models.FeedSource) error {
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	res, err := f.fetcher.FetchConditional(fetchCtx, src.URL, src.ETag, src.LastModified)
+	if err != nil {
+		return err
+	}
+	if res.NotModified {
+		return nil
+	}
+	src.ETag = res.ETag
+	src.LastModified = res.LastModified
+
+	meta := res.Feed.Podcast(src.URL)
+	_, err = f.repo.SyncFeed(ctx, src.PodcastID, &meta, res.Feed.Episodes())
+	return err
+}
+
+// backoff doubles the interval per consecutive failure, capped at a day.
+func (f *FeedRefresher) backoff(failures int) time.Duration {
+	d := f.interval
+	for i := 1; i < failures && d < maxFeedBackoff; i++ {
+		d *= 2
+	}
+	if d > maxFeedBackoff {
+		d = maxFeedBackoff
+	}
+	return d
+}
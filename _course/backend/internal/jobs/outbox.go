@@ -0,0 +1,97 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+)
+
+const (
+	outboxBatchSize  = 100
+	outboxMaxBackoff = time.Hour
+	// outboxLease is how long claimed events are kept from other instances; a batch
+	// is delivered well within it.
+	outboxLease = 5 * time.Minute
+	// outboxRetention is how long dispatched events are kept for inspection.
+	outboxRetention = 7 * 24 * time.Hour
+	outboxPrune     = time.Hour
+)
+
+// OutboxConsumer receives the events recorded by repositories. An error leaves the
+// event pending and it is offered to every consumer again later, so consumers must
+// tolerate duplicates.
+type OutboxConsumer interface {
+	Consume(ctx context.Context, ev *models.OutboxEvent) error
+}
+
+// OutboxDispatcher delivers outbox events to its consumers at least once.
+type OutboxDispatcher struct {
+	repo      *repository.OutboxRepository
+	consumers []OutboxConsumer
+	interval  time.Duration
+}
+
+// NewOutboxDispatcher returns a dispatcher that polls for new events every interval.
+func NewOutboxDispatcher(repo *repository.OutboxRepository, interval time.Duration, consumers ...OutboxConsumer) *OutboxDispatcher {
+	return &OutboxDispatcher{repo: repo, consumers: consumers, interval: interval}
+}
+
+// Run dispatches events until ctx is cancelled.
+func (d *OutboxDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+	prune := time.NewTicker(outboxPrune)
+	defer prune.Stop()
+	for {
+		d.dispatch(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-prune.C:
+			if n, err := d.repo.Prune(ctx, time.Now().Add(-outboxRetention)); err != nil {
+				log.Printf("outbox: prune: %v", err)
+			} else if n > 0 {
+				log.Printf("outbox: pruned %d dispatched events", n)
+			}
+		}
+	}
+}
+
+// dispatch drains due events in batches of outboxBatchSize.
+func (d *OutboxDispatcher) dispatch(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := d.repo.Dispatch(ctx, outboxBatchSize, outboxLease, func(ev *models.OutboxEvent) error {
+			return d.deliver(ctx, ev)
+		}, outboxBackoff)
+		if err != nil {
+			log.Printf("outbox: %v", err)
+			return
+		}
+		if n < outboxBatchSize {
+			return
+		}
+	}
+}
+
+func (d *OutboxDispatcher) deliver(ctx context.Context, ev *models.OutboxEvent) error {
+	for _, c := range d.consumers {
+		if err := c.Consume(ctx, ev); err != nil {
+			log.Printf("outbox: event %d (%s): %v", ev.ID, ev.Type, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// outboxBackoff doubles the delay per failed attempt, from a second up to an hour.
+func outboxBackoff(attempts int) time.Duration {
+	d := time.Second
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, outboxMaxBackoff)
+}
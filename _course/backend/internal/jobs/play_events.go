@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"podcast-backend/internal/repository"
+)
+
+const playEventsBatch = 1000
+
+// PlayEventFlusher periodically writes play events buffered in Redis to Postgres.
+type PlayEventFlusher struct {
+	repo     *repository.PodcastRepository
+	interval time.Duration
+}
+
+func NewPlayEventFlusher(repo *repository.PodcastRepository, interval time.Duration) *PlayEventFlusher {
+	return &PlayEventFlusher{repo: repo, interval: interval}
+}
+
+// Run flushes until ctx is cancelled.
+func (f *PlayEventFlusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		f.flush(ctx)
+	}
+}
+
+// flush drains the buffer in batches of playEventsBatch.
+func (f *PlayEventFlusher) flush(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := f.repo.FlushPlayEvents(ctx, playEventsBatch)
+		if err != nil {
+			log.Printf("play events flush: %v", err)
+			return
+		}
+		if n < playEventsBatch {
+			return
+		}
+	}
+}
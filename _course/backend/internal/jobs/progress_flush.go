@@ -0,0 +1,50 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"podcast-backend/internal/repository"
+)
+
+const progressFlushBatch = 500
+
+// ProgressFlusher periodically persists playback positions buffered in Redis and the
+// listening history they make.
+type ProgressFlusher struct {
+	repo     *repository.PodcastRepository
+	interval time.Duration
+}
+
+func NewProgressFlusher(repo *repository.PodcastRepository, interval time.Duration) *ProgressFlusher {
+	return &ProgressFlusher{repo: repo, interval: interval}
+}
+
+// Run flushes until ctx is cancelled.
+func (f *ProgressFlusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		f.flush(ctx)
+	}
+}
+
+// flush drains the dirty set in batches so a burst of listeners is written in one tick.
+func (f *ProgressFlusher) flush(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := f.repo.FlushProgress(ctx, progressFlushBatch)
+		if err != nil {
+			log.Printf("progress flush: %v", err)
+			return
+		}
+		if n == 0 {
+			return
+		}
+	}
+}
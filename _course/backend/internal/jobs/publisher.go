@@ -0,0 +1,73 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"podcast-backend/internal/repository"
+)
+
+// publishRetry is how soon a failed check is repeated.
+const publishRetry = 10 * time.Second
+
+// EpisodePublisher publishes scheduled episodes when their time comes; the repository
+// records their episode_published events.
+type EpisodePublisher struct {
+	repo     *repository.PodcastRepository
+	interval time.Duration
+	wake     chan struct{}
+}
+
+// NewEpisodePublisher returns a publisher that sleeps until the next scheduled episode,
+// but never longer than interval, so schedules made on other instances are picked up.
+func NewEpisodePublisher(repo *repository.PodcastRepository, interval time.Duration) *EpisodePublisher {
+	return &EpisodePublisher{repo: repo, interval: interval, wake: make(chan struct{}, 1)}
+}
+
+// Trigger makes the publisher look at the schedule again, e.g. after an author
+// scheduled an episode earlier than anything it was waiting for.
+func (p *EpisodePublisher) Trigger() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes due episodes until ctx is cancelled.
+func (p *EpisodePublisher) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(p.publishDue(ctx))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		case <-p.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// publishDue publishes what is due and returns how long to sleep.
+func (p *EpisodePublisher) publishDue(ctx context.Context) time.Duration {
+	published, err := p.repo.PublishDue(ctx, time.Now())
+	if err != nil {
+		log.Printf("publisher: %v", err)
+		return publishRetry
+	}
+	if len(published) > 0 {
+		log.Printf("publisher: published %d scheduled episodes", len(published))
+	}
+
+	next, err := p.repo.NextPublishAt(ctx)
+	if err != nil {
+		log.Printf("publisher: next schedule: %v", err)
+		return publishRetry
+	}
+	wait := p.interval
+	if next != nil {
+		wait = min(wait, max(time.Until(*next), 0))
+	}
+	return wait
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"podcast-backend/internal/repository"
+)
+
+// RecommendationBuilder recomputes similar podcasts and user recommendations.
+type RecommendationBuilder struct {
+	repo     *repository.PodcastRepository
+	interval time.Duration
+}
+
+func NewRecommendationBuilder(repo *repository.PodcastRepository, interval time.Duration) *RecommendationBuilder {
+	return &RecommendationBuilder{repo: repo, interval: interval}
+}
+
+// Run rebuilds right away and then on every tick until ctx is cancelled.
+func (b *RecommendationBuilder) Run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		if err := b.repo.RebuildRecommendations(ctx); err != nil {
+			log.Printf("recommendations: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"podcast-backend/internal/repository"
+)
+
+// SearchTermsRefresher periodically rebuilds the vocabulary used for "did you mean".
+type SearchTermsRefresher struct {
+	repo     *repository.PodcastRepository
+	interval time.Duration
+}
+
+func NewSearchTermsRefresher(repo *repository.PodcastRepository, interval time.Duration) *SearchTermsRefresher {
+	return &SearchTermsRefresher{repo: repo, interval: interval}
+}
+
+// Run refreshes once at start, so a fresh database gets its vocabulary without waiting
+// an interval, then until ctx is cancelled.
+func (r *SearchTermsRefresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		if err := r.repo.RefreshSearchTerms(ctx); err != nil {
+			log.Printf("search terms: refresh: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"podcast-backend/internal/audio"
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+	"podcast-backend/internal/storage"
+)
+
+const (
+	waveformBatchSize = 10
+	waveformPoll      = 30 * time.Second
+)
+
+// WaveformResolutions are the bin counts stored for each episode: an overview for
+// lists, and detailed ones for the player at different widths.
+var WaveformResolutions = []int{100, 1000, 4000}
+
+// WaveformData is the JSON document stored next to the episode audio.
+type WaveformData struct {
+	Duration float64         `json:"duration"` // seconds of decoded audio
+	Levels   []WaveformLevel `json:"levels"`
+}
+
+// WaveformLevel holds peaks in the 0..255 range; Bins can be lower than requested for very short audio.
+type WaveformLevel struct {
+	Bins  int   `json:"bins"`
+	Peaks []int `json:"peaks"`
+}
+
+// WaveformGenerator decodes uploaded audio and stores peaks for queued episodes.
+type WaveformGenerator struct {
+	episodes *repository.EpisodeRepository
+	store    storage.Storage
+	wake     chan struct{}
+}
+
+func NewWaveformGenerator(episodes *repository.EpisodeRepository, store storage.Storage) *WaveformGenerator {
+	return &WaveformGenerator{episodes: episodes, store: store, wake: make(chan struct{}, 1)}
+}
+
+// Trigger asks the generator to look for pending work now instead of at the next poll.
+func (g *WaveformGenerator) Trigger() {
+	select {
+	case g.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes pending waveforms until ctx is cancelled.
+func (g *WaveformGenerator) Run(ctx context.Context) {
+	ticker := time.NewTicker(waveformPoll)
+	defer ticker.Stop()
+	for {
+		g.runPending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-g.wake:
+		}
+	}
+}
+
+func (g *WaveformGenerator) runPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		items, err := g.episodes.PendingWaveforms(ctx, waveformBatchSize)
+		if err != nil {
+			log.Printf("waveform: list pending: %v", err)
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		for i := range items {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := g.generate(ctx, &items[i]); err != nil {
+				log.Printf("waveform: save episode %d: %v", items[i].EpisodeID, err)
+				return
+			}
+		}
+	}
+}
+
+// generate builds peaks for one item and records the outcome; only a failure to
+// record it is returned, decoding errors end up on the row.
+func (g *WaveformGenerator) generate(ctx context.Context, w *models.Waveform) error {
+	oldKey := w.Key
+	key, err := g.build(ctx, w)
+	if ctx.Err() != nil {
+		return ctx.Err() // shutting down: leave the item pending
+	}
+	if err != nil {
+		log.Printf("waveform: episode %d: %v", w.EpisodeID, err)
+		w.Status = models.WaveformFailed
+		w.Error = err.Error()
+	} else {
+		w.Status = models.WaveformReady
+		w.Key = key
+		w.Error = ""
+	}
+
+	applied, err := g.episodes.FinishWaveform(ctx, w)
+	if err != nil {
+		return err
+	}
+	switch {
+	case !applied && key != "":
+		// the audio was replaced meanwhile; the row is pending again for the new file
+		g.delete(ctx, key)
+	case applied && key != "" && oldKey != "" && oldKey != key:
+		g.delete(ctx, oldKey)
+	}
+	return nil
+}
+
+func (g *WaveformGenerator) build(ctx context.Context, w *models.Waveform) (string, error) {
+	obj, info, err := g.store.Open(ctx, w.AudioKey)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Close()
+
+	env, err := audio.DecodeEnvelope(obj, info.Size)
+	if err != nil {
+		return "", err
+	}
+	if len(env.Values) == 0 {
+		return "", errors.New("no audio decoded")
+	}
+	data := WaveformData{Duration: env.Duration()}
+	for _, bins := range WaveformResolutions {
+		peaks := env.Peaks(bins)
+		data.Levels = append(data.Levels, WaveformLevel{Bins: len(peaks), Peaks: peaks})
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	key := waveformKey(w.AudioKey)
+	if err := g.store.Put(ctx, key, bytes.NewReader(body), int64(len(body)), "application/json"); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (g *WaveformGenerator) delete(ctx context.Context, key string) {
+	if err := g.store.Delete(ctx, key); err != nil {
+		log.Printf("waveform: delete %s: %v", key, err)
+	}
+}
+
+// waveformKey derives the peaks object from the audio key, so each upload gets its own file.
+func waveformKey(audioKey string) string {
+	return strings.TrimSuffix(audioKey, path.Ext(audioKey)) + ".waveform.json"
+}
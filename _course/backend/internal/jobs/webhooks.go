@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/repository"
+	"podcast-backend/internal/webhooks"
+)
+
+const (
+	webhookBatchSize = 50
+	webhookWorkers   = 8
+	webhookPoll      = 10 * time.Second
+	// webhookLease is how long a claimed delivery is left to its sender before it is
+	// due again; longer than a send with its timeouts.
+	webhookLease       = 2 * time.Minute
+	webhookMaxAttempts = 10
+	webhookRetryBase   = time.Minute
+	webhookMaxBackoff  = 6 * time.Hour
+	// webhookRetention is how long finished deliveries stay in the log.
+	webhookRetention = 30 * 24 * time.Hour
+	webhookPrune     = time.Hour
+)
+
+// WebhookStore is the part of repository.WebhookRepository the dispatcher needs.
+type WebhookStore interface {
+	EnqueueDeliveries(ctx context.Context, podcastID uint, event, key string, payload []byte) (int, error)
+	ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	FinishAttempt(ctx context.Context, d *models.WebhookDelivery) error
+	PruneDeliveries(ctx context.Context, before time.Time) (int, error)
+}
+
+var _ WebhookStore = (*repository.WebhookRepository)(nil)
+
+// WebhookDispatcher turns outbox events about episodes into webhook deliveries and
+// sends them, retrying failures with exponential backoff.
+type WebhookDispatcher struct {
+	repo   WebhookStore
+	sender *webhooks.Sender
+	wake   chan struct{}
+}
+
+func NewWebhookDispatcher(repo WebhookStore, sender *webhooks.Sender) *WebhookDispatcher {
+	return &WebhookDispatcher{repo: repo, sender: sender, wake: make(chan struct{}, 1)}
+}
+
+// Trigger asks the dispatcher to send due deliveries now instead of at the next poll.
+func (d *WebhookDispatcher) Trigger() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Consume queues deliveries for an outbox event. Only events listeners can see are
+// offered to webhooks, so a draft becomes episode_published when listeners get it (or
+// its podcast goes public) and episode_unpublished when they lose it; likes become a
+// likes_milestone when the count reaches one.
+func (d *WebhookDispatcher) Consume(ctx context.Context, ev *models.OutboxEvent) error {
+	if ev.UserID != 0 || ev.PodcastID == 0 {
+		return nil
+	}
+	event, key, data := ev.Type, "outbox:"+strconv.FormatUint(uint64(ev.ID), 10), json.RawMessage(ev.Payload)
+	switch ev.Type {
+	case models.WebhookEpisodeCreated, models.WebhookEpisodePublished, models.WebhookEpisodeUpdated,
+		models.WebhookEpisodeDeleted, models.WebhookEpisodeUnpublished:
+	case "episode_likes":
+		var likes struct {
+			EpisodeID uint `json:"episodeId"`
+			Likes     int  `json:"likes"`
+		}
+		if err := json.Unmarshal(data, &likes); err != nil || !likesMilestone(likes.Likes) {
+			return nil
+		}
+		// keyed by the milestone, so unliking and liking again does not repeat it
+		event = models.WebhookLikesMilestone
+		key = "likes:" + strconv.FormatUint(uint64(likes.EpisodeID), 10) + ":" + strconv.Itoa(likes.Likes)
+	default:
+		return nil
+	}
+
+	body, err := json.Marshal(webhooks.Payload{Event: event, PodcastID: ev.PodcastID, CreatedAt: ev.CreatedAt, Data: data})
+	if err != nil {
+		return err
+	}
+	n, err := d.repo.EnqueueDeliveries(ctx, ev.PodcastID, event, key, body)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		d.Trigger()
+	}
+	return nil
+}
+
+// likesMilestone reports whether a like count is worth telling the author about:
+// 10, 50, 100, 500, 1000 and so on.
+func likesMilestone(n int) bool {
+	for m := 10; m > 0 && m <= n; m *= 10 {
+		if n == m || n == 5*m {
+			return true
+		}
+	}
+	return false
+}
+
+// Run sends due deliveries until ctx is cancelled.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(webhookPoll)
+	defer ticker.Stop()
+	prune := time.NewTicker(webhookPrune)
+	defer prune.Stop()
+	for {
+		d.sendDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		case <-prune.C:
+			if n, err := d.repo.PruneDeliveries(ctx, time.Now().Add(-webhookRetention)); err != nil {
+				log.Printf("webhooks: prune: %v", err)
+			} else if n > 0 {
+				log.Printf("webhooks: pruned %d deliveries", n)
+			}
+		}
+	}
+}
+
+// sendDue sends due deliveries in batches of webhookBatchSize, webhookWorkers at a time.
+func (d *WebhookDispatcher) sendDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := d.repo.ClaimDeliveries(ctx, time.Now(), webhookLease, webhookBatchSize)
+		if err != nil {
+			log.Printf("webhooks: claim deliveries: %v", err)
+			return
+		}
+		sem := make(chan struct{}, webhookWorkers)
+		var wg sync.WaitGroup
+		for i := range due {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(del *models.WebhookDelivery) {
+				defer func() { <-sem; wg.Done() }()
+				d.send(ctx, del)
+			}(&due[i])
+		}
+		wg.Wait()
+		if len(due) < webhookBatchSize {
+			return
+		}
+	}
+}
+
+// send makes one attempt and records its outcome.
+func (d *WebhookDispatcher) send(ctx context.Context, del *models.WebhookDelivery) {
+	del.Attempts++
+	del.ResponseCode, del.ResponseBody, del.Error = 0, "", ""
+	if del.Webhook == nil || !del.Webhook.Active {
+		del.Status, del.Error = models.DeliveryFailed, "webhook is inactive"
+	} else {
+		res, err := d.sender.Send(ctx, del.Webhook.URL, del.Webhook.Secret, del.Event, del.ID, del.Payload)
+		if res != nil {
+			del.ResponseCode, del.ResponseBody = res.StatusCode, res.Body
+		}
+		now := time.Now()
+		switch {
+		case err == nil:
+			del.Status, del.DeliveredAt = models.DeliverySucceeded, &now
+		case del.Attempts >= webhookMaxAttempts:
+			del.Status, del.Error = models.DeliveryFailed, err.Error()
+		default:
+			del.Error = err.Error()
+			del.NextAttemptAt = now.Add(webhookBackoff(del.Attempts))
+		}
+	}
+	if err := d.repo.FinishAttempt(ctx, del); err != nil {
+		log.Printf("webhooks: delivery %d: %v", del.ID, err)
+	}
+}
+
+// webhookBackoff doubles the delay per failed attempt from webhookRetryBase, capped
+// at webhookMaxBackoff: with webhookMaxAttempts a receiver has about eight hours to recover.
+func webhookBackoff(attempts int) time.Duration {
+	d := webhookRetryBase
+	for i := 1; i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, webhookMaxBackoff)
+}
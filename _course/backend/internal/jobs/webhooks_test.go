@@ -0,0 +1,269 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/webhooks"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+// fakeWebhookStore hands out queued deliveries once and keeps every finished attempt.
+type fakeWebhookStore struct {
+	mu       sync.Mutex
+	due      []models.WebhookDelivery
+	finished []models.WebhookDelivery
+}
+
+func (s *fakeWebhookStore) EnqueueDeliveries(context.Context, uint, string, string, []byte) (int, error) {
+	return 0, nil
+}
+
+func (s *fakeWebhookStore) ClaimDeliveries(_ context.Context, _ time.Time, _ time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := min(limit, len(s.due))
+	due := s.due[:n]
+	s.due = s.due[n:]
+	return due, nil
+}
+
+func (s *fakeWebhookStore) FinishAttempt(_ context.Context, d *models.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = append(s.finished, *d)
+	return nil
+}
+
+func (s *fakeWebhookStore) PruneDeliveries(context.Context, time.Time) (int, error) {
+	return 0, nil
+}
+
+// received is one request as the receiver saw it.
+type received struct {
+	header http.Header
+	body   []byte
+	err    error // from webhooks.Verify
+}
+
+// receiver starts a webhook endpoint answering with status and records what it gets.
+func receiver(t *testing.T, status int) (*httptest.Server, func() []received) {
+	t.Helper()
+	var mu sync.Mutex
+	var got []received
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		err := webhooks.Verify(testSecret, r.Header, body, 5*time.Minute, time.Now())
+		mu.Lock()
+		got = append(got, received{header: r.Header.Clone(), body: body, err: err})
+		mu.Unlock()
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, http.StatusText(status))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []received {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]received(nil), got...)
+	}
+}
+
+func testDispatcher(srv *httptest.Server, store *fakeWebhookStore) *WebhookDispatcher {
+	return NewWebhookDispatcher(store, &webhooks.Sender{Client: srv.Client()})
+}
+
+func testDelivery(t *testing.T, id uint, url string) models.WebhookDelivery {
+	t.Helper()
+	payload, err := json.Marshal(webhooks.Payload{
+		Event:     models.WebhookEpisodePublished,
+		PodcastID: 3,
+		CreatedAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Data:      json.RawMessage(`{"id":42,"title":"Pilot"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.WebhookDelivery{
+		ID:      id,
+		Event:   models.WebhookEpisodePublished,
+		Payload: payload,
+		Status:  models.DeliveryPending,
+		Webhook: &models.Webhook{ID: 1, URL: url, Secret: testSecret, Active: true},
+	}
+}
+
+func TestWebhookDeliverySigned(t *testing.T) {
+	srv, got := receiver(t, http.StatusNoContent)
+	store := &fakeWebhookStore{}
+	del := testDelivery(t, 7, srv.URL)
+
+	testDispatcher(srv, store).send(context.Background(), &del)
+
+	reqs := got()
+	if len(reqs) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(reqs))
+	}
+	r := reqs[0]
+	if r.err != nil {
+		t.Errorf("signature: %v", r.err)
+	}
+	if string(r.body) != string(del.Payload) {
+		t.Errorf("body = %s, want %s", r.body, del.Payload)
+	}
+	if h := r.header.Get(webhooks.EventHeader); h != models.WebhookEpisodePublished {
+		t.Errorf("%s = %q", webhooks.EventHeader, h)
+	}
+	if h := r.header.Get(webhooks.DeliveryHeader); h != "7" {
+		t.Errorf("%s = %q, want 7", webhooks.DeliveryHeader, h)
+	}
+	// a tampered body or another secret must not verify
+	if err := webhooks.Verify(testSecret, r.header, append(r.body, ' '), 5*time.Minute, time.Now()); err == nil {
+		t.Error("tampered body verified")
+	}
+	if err := webhooks.Verify("another-secret-0123456789", r.header, r.body, 5*time.Minute, time.Now()); err == nil {
+		t.Error("wrong secret verified")
+	}
+
+	if len(store.finished) != 1 {
+		t.Fatalf("FinishAttempt called %d times, want 1", len(store.finished))
+	}
+	f := store.finished[0]
+	if f.Status != models.DeliverySucceeded || f.DeliveredAt == nil || f.Attempts != 1 || f.ResponseCode != http.StatusNoContent {
+		t.Errorf("finished = status %s, deliveredAt %v, attempts %d, code %d", f.Status, f.DeliveredAt, f.Attempts, f.ResponseCode)
+	}
+}
+
+func TestWebhookFailureBacksOff(t *testing.T) {
+	srv, got := receiver(t, http.StatusInternalServerError)
+	store := &fakeWebhookStore{}
+	d := testDispatcher(srv, store)
+	del := testDelivery(t, 8, srv.URL)
+
+	for attempt := 1; attempt <= 3; attempt++ {
+		before := time.Now()
+		d.send(context.Background(), &del)
+		after := time.Now()
+
+		if del.Status != models.DeliveryPending {
+			t.Fatalf("attempt %d: status = %s, want %s", attempt, del.Status, models.DeliveryPending)
+		}
+		if del.Attempts != attempt || del.ResponseCode != http.StatusInternalServerError || del.Error == "" {
+			t.Errorf("attempt %d: attempts %d, code %d, error %q", attempt, del.Attempts, del.ResponseCode, del.Error)
+		}
+		if del.ResponseBody != http.StatusText(http.StatusInternalServerError) {
+			t.Errorf("attempt %d: response body %q", attempt, del.ResponseBody)
+		}
+		wait := webhookBackoff(attempt)
+		if del.NextAttemptAt.Before(before.Add(wait)) || del.NextAttemptAt.After(after.Add(wait)) {
+			t.Errorf("attempt %d: next attempt in %s, want %s", attempt, del.NextAttemptAt.Sub(before), wait)
+		}
+	}
+	if n := len(got()); n != 3 {
+		t.Errorf("receiver got %d requests, want 3", n)
+	}
+}
+
+func TestWebhookFailsAfterMaxAttempts(t *testing.T) {
+	srv, _ := receiver(t, http.StatusBadGateway)
+	store := &fakeWebhookStore{}
+	del := testDelivery(t, 9, srv.URL)
+	del.Attempts = webhookMaxAttempts - 1
+
+	testDispatcher(srv, store).send(context.Background(), &del)
+
+	if del.Status != models.DeliveryFailed || del.Attempts != webhookMaxAttempts {
+		t.Errorf("status %s after %d attempts, want %s after %d", del.Status, del.Attempts, models.DeliveryFailed, webhookMaxAttempts)
+	}
+	if del.ResponseCode != http.StatusBadGateway || del.Error == "" || del.DeliveredAt != nil {
+		t.Errorf("code %d, error %q, deliveredAt %v", del.ResponseCode, del.Error, del.DeliveredAt)
+	}
+}
+
+func TestWebhookInactiveFails(t *testing.T) {
+	srv, got := receiver(t, http.StatusOK)
+	store := &fakeWebhookStore{}
+	del := testDelivery(t, 10, srv.URL)
+	del.Webhook.Active = false
+
+	testDispatcher(srv, store).send(context.Background(), &del)
+
+	if del.Status != models.DeliveryFailed {
+		t.Errorf("status = %s, want %s", del.Status, models.DeliveryFailed)
+	}
+	if n := len(got()); n != 0 {
+		t.Errorf("inactive webhook was called %d times", n)
+	}
+}
+
+// A redelivery is a new delivery with the payload of the original: sendDue sends it
+// byte for byte under its own delivery id, freshly signed.
+func TestWebhookRedelivery(t *testing.T) {
+	srv, got := receiver(t, http.StatusOK)
+	orig := testDelivery(t, 11, srv.URL)
+	orig.Status, orig.Attempts = models.DeliveryFailed, webhookMaxAttempts
+	redo := testDelivery(t, 12, srv.URL)
+	redo.Payload, redo.RedeliveryOf = orig.Payload, &orig.ID
+	store := &fakeWebhookStore{due: []models.WebhookDelivery{redo}}
+
+	testDispatcher(srv, store).sendDue(context.Background())
+
+	reqs := got()
+	if len(reqs) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(reqs))
+	}
+	if reqs[0].err != nil {
+		t.Errorf("signature: %v", reqs[0].err)
+	}
+	if string(reqs[0].body) != string(orig.Payload) {
+		t.Errorf("body = %s, want the original %s", reqs[0].body, orig.Payload)
+	}
+	if h := reqs[0].header.Get(webhooks.DeliveryHeader); h != strconv.Itoa(12) {
+		t.Errorf("%s = %q, want 12", webhooks.DeliveryHeader, h)
+	}
+	if len(store.finished) != 1 || store.finished[0].Status != models.DeliverySucceeded {
+		t.Errorf("finished = %+v", store.finished)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:                  time.Minute,
+		2:                  2 * time.Minute,
+		3:                  4 * time.Minute,
+		9:                  256 * time.Minute,
+		webhookMaxAttempts: webhookMaxBackoff,
+		50:                 webhookMaxBackoff,
+	}
+	for attempts, want := range cases {
+		if got := webhookBackoff(attempts); got != want {
+			t.Errorf("webhookBackoff(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestLikesMilestone(t *testing.T) {
+	var got []int
+	for n := 0; n <= 10000; n++ {
+		if likesMilestone(n) {
+			got = append(got, n)
+		}
+	}
+	want := []int{10, 50, 100, 500, 1000, 5000, 10000}
+	if len(got) != len(want) {
+		t.Fatalf("milestones = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("milestones = %v, want %v", got, want)
+		}
+	}
+}
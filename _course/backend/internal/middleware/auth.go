@@ -28,3 +28,26 @@ func AuthRequired(jwtService *auth.JWTService) gin.HandlerFunc {
 	}
 }
 
+// AuthOptional sets the user like AuthRequired when a valid token is sent and lets
+// anonymous requests (or ones with an invalid token) through without it.
+func AuthOptional(jwtService *auth.JWTService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		if strings.HasPrefix(header, "Bearer ") {
+			if claims, err := jwtService.Parse(strings.TrimPrefix(header, "Bearer ")); err == nil {
+				c.Set("userID", claims.UserID)
+				c.Set("userEmail", claims.Email)
+			}
+		}
+		c.Next()
+	}
+}
+
+// PublicURL makes base the origin handlers build absolute links on, instead of the one
+// the request was made to.
+func PublicURL(base string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("publicURL", base)
+		c.Next()
+	}
+}
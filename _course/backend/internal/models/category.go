@@ -0,0 +1,12 @@
+package models
+
+// Category is a node of the category tree; top-level categories have no parent.
+type Category struct {
+	ID       uint      `json:"id" gorm:"primaryKey"`
+	Slug     string    `json:"slug" gorm:"uniqueIndex;not null"`
+	Name     string    `json:"name"`
+	NameRu   string    `json:"nameRu"`
+	ParentID *uint     `json:"parentId" gorm:"index"`
+	Parent   *Category `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	Position int       `json:"position"`
+}
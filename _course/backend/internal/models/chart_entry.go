@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	ChartEpisodes = "episode"
+	ChartPodcasts = "podcast"
+)
+
+// ChartEntry is a precomputed chart position; the chart job replaces all entries of
+// a kind and period at once.
+type ChartEntry struct {
+	Kind       string    `json:"kind" gorm:"primaryKey"`
+	Period     string    `json:"period" gorm:"primaryKey"`
+	ItemID     uint      `json:"itemId" gorm:"primaryKey"`
+	CategoryID *uint     `json:"categoryId" gorm:"index"`
+	Score      float64   `json:"score"`
+	ComputedAt time.Time `json:"computedAt"`
+}
@@ -2,16 +2,29 @@ package models
 
 import "time"
 
+// Статусы публикации эпизода
+const (
+	EpisodeDraft     = "draft"
+	EpisodeScheduled = "scheduled"
+	EpisodePublished = "published"
+)
+
 type Episode struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	PodcastID   uint      `json:"podcastId" gorm:"index"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Date        string    `json:"date"`
-	Duration    int       `json:"duration"` // seconds
-	AudioURL    string    `json:"audioUrl"`
-	Likes       int       `json:"likes" gorm:"default:0"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	PodcastID   uint       `json:"podcastId" gorm:"index;uniqueIndex:idx_episodes_podcast_guid"`
+	GUID        *string    `json:"guid,omitempty" gorm:"uniqueIndex:idx_episodes_podcast_guid"` // guid элемента внешнего фида
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Date        string     `json:"date"`
+	Duration    int        `json:"duration"` // seconds
+	AudioURL    string     `json:"audioUrl"`
+	AudioKey    string     `json:"-"`         // ключ загруженного файла в хранилище
+	AudioSize   int64      `json:"audioSize"` // bytes
+	AudioType   string     `json:"audioType"`
+	Likes       int        `json:"likes" gorm:"default:0"`
+	Tags        []Tag      `json:"tags,omitempty" gorm:"many2many:episode_tags;"`
+	Status      string     `json:"status" gorm:"size:16;not null;default:published;index:idx_episodes_status_publish_at"`
+	PublishAt   *time.Time `json:"publishAt" gorm:"index:idx_episodes_status_publish_at"` // момент публикации, для scheduled — запланированный
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
 }
-
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// FeedSource tracks synchronisation state of a podcast imported from an external feed.
+type FeedSource struct {
+	PodcastID     uint       `json:"podcastId" gorm:"primaryKey"`
+	Podcast       *Podcast   `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	URL           string     `json:"url"`
+	ETag          string     `json:"etag"`
+	LastModified  string     `json:"lastModified"`
+	LastFetchedAt *time.Time `json:"lastFetchedAt"`
+	LastError     string     `json:"lastError"`
+	Failures      int        `json:"failures" gorm:"default:0"`
+	NextRunAt     time.Time  `json:"nextRunAt" gorm:"index"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
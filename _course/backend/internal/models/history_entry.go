@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+const (
+	HistoryStarted  = "started"
+	HistoryFinished = "finished"
+)
+
+// HistoryEntry records that a user started or finished an episode.
+type HistoryEntry struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"userId" gorm:"index:idx_history_user_created"`
+	EpisodeID uint      `json:"episodeId" gorm:"index"`
+	Episode   *Episode  `json:"episode,omitempty" gorm:"constraint:OnDelete:CASCADE;"`
+	PodcastID uint      `json:"podcastId" gorm:"index"`
+	Podcast   *Podcast  `json:"podcast,omitempty" gorm:"constraint:OnDelete:CASCADE;"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index:idx_history_user_created"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// OutboxEvent is a change recorded in the same transaction as the change itself.
+// The dispatcher hands it to consumers (SSE and others) at least once.
+type OutboxEvent struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Type          string     `json:"type" gorm:"size:64;not null"`
+	PodcastID     uint       `json:"podcastId,omitempty"` // подкаст, к которому относится событие
+	EpisodeID     uint       `json:"episodeId,omitempty"`
+	UserID        uint       `json:"userId,omitempty"` // если задан, событие видит только этот пользователь
+	Payload       string     `json:"-" gorm:"type:jsonb;not null"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	LastError     string     `json:"lastError,omitempty"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt"`
+	DispatchedAt  *time.Time `json:"dispatchedAt" gorm:"index"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
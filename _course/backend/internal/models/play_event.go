@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const (
+	PlayStart    = "start"
+	PlayProgress = "progress"
+	PlayComplete = "complete"
+)
+
+// PlayEvent is one accepted playback event. ListenerKey identifies the listener for
+// unique counts: the user id when signed in, otherwise a hash of IP and user agent.
+type PlayEvent struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	EpisodeID   uint      `json:"episodeId" gorm:"index"`
+	Episode     *Episode  `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	PodcastID   uint      `json:"podcastId" gorm:"index:idx_play_events_podcast_created"`
+	Podcast     *Podcast  `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	UserID      *uint     `json:"userId"`
+	ListenerKey string    `json:"listenerKey"`
+	Type        string    `json:"type"`
+	Position    int       `json:"position"` // seconds
+	CreatedAt   time.Time `json:"createdAt" gorm:"index:idx_play_events_podcast_created"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PlaybackProgress is where a user stopped in an episode.
+type PlaybackProgress struct {
+	UserID    uint      `json:"userId" gorm:"primaryKey"`
+	EpisodeID uint      `json:"episodeId" gorm:"primaryKey;index"`
+	Episode   *Episode  `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	Position  int       `json:"position"` // seconds
+	Completed bool      `json:"completed" gorm:"default:false"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"index"`
+}
@@ -2,6 +2,13 @@ package models
 
 import "time"
 
+// Видимость подкаста
+const (
+	PodcastDraft    = "draft"    // только автору
+	PodcastUnlisted = "unlisted" // по прямой ссылке и в фиде, но не в списках, поиске и чартах
+	PodcastPublic   = "public"
+)
+
 type Podcast struct {
 	ID          uint      `json:"id" gorm:"primaryKey"`
 	Title       string    `json:"title"`
@@ -11,6 +18,11 @@ type Podcast struct {
 	Description string    `json:"description"`
 	Image       *string   `json:"image"`
 	Category    *string   `json:"category"`
+	CategoryID  *uint     `json:"categoryId" gorm:"index"` // выводится из Category, см. categories.Match
+	CategoryRef *Category `json:"-" gorm:"foreignKey:CategoryID;constraint:OnDelete:SET NULL;"`
+	FeedURL     *string   `json:"feedUrl,omitempty" gorm:"index"` // исходный RSS/Atom фид для импортированных подкастов
+	Language    string    `json:"language"`                       // язык для <language> фида, напр. ru или en-US; пустой — FEED_LANGUAGE
+	Visibility  string    `json:"visibility" gorm:"size:16;not null;default:public;index"`
 	Episodes    []Episode `json:"episodes" gorm:"constraint:OnDelete:CASCADE;"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
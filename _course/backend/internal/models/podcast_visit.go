@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// PodcastVisit is when a user last opened a podcast; episodes added later count as new.
+type PodcastVisit struct {
+	UserID    uint      `json:"userId" gorm:"primaryKey"`
+	PodcastID uint      `json:"podcastId" gorm:"primaryKey;index"`
+	VisitedAt time.Time `json:"visitedAt"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// PodcastSimilarity links a podcast to one of its most similar podcasts, as computed
+// by the recommendation job from shared listeners.
+type PodcastSimilarity struct {
+	PodcastID  uint     `gorm:"primaryKey"`
+	Podcast    *Podcast `gorm:"constraint:OnDelete:CASCADE;"`
+	SimilarID  uint     `gorm:"primaryKey"`
+	Similar    *Podcast `gorm:"constraint:OnDelete:CASCADE;"`
+	Score      float64
+	ComputedAt time.Time
+}
+
+// Recommendation is a podcast suggested to a user by the recommendation job.
+type Recommendation struct {
+	UserID     uint     `gorm:"primaryKey"`
+	PodcastID  uint     `gorm:"primaryKey"`
+	Podcast    *Podcast `gorm:"constraint:OnDelete:CASCADE;"`
+	Score      float64
+	ComputedAt time.Time
+}
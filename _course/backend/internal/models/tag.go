@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Tag labels episodes with guests, topics and the like; Slug is the normalized Name.
+type Tag struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	Slug      string    `json:"slug" gorm:"uniqueIndex;not null"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"-"`
+}
+
+// EpisodeTag is the join table behind Episode.Tags.
+type EpisodeTag struct {
+	EpisodeID uint     `gorm:"primaryKey"`
+	Episode   *Episode `gorm:"constraint:OnDelete:CASCADE;"`
+	TagID     uint     `gorm:"primaryKey;index"`
+	Tag       *Tag     `gorm:"constraint:OnDelete:CASCADE;"`
+	CreatedAt time.Time
+}
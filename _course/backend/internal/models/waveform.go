@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	WaveformPending = "pending"
+	WaveformReady   = "ready"
+	WaveformFailed  = "failed"
+)
+
+// Waveform tracks peak generation for an episode's uploaded audio.
+type Waveform struct {
+	EpisodeID uint      `json:"episodeId" gorm:"primaryKey"`
+	Episode   *Episode  `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	Status    string    `json:"status" gorm:"index"`
+	AudioKey  string    `json:"-"` // аудиофайл, для которого строятся пики
+	Key       string    `json:"-"` // ключ JSON с пиками в хранилище
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// События, на которые автор может подписать вебхук
+const (
+	WebhookEpisodeCreated     = "episode_created"
+	WebhookEpisodePublished   = "episode_published"
+	WebhookEpisodeUpdated     = "episode_updated"
+	WebhookEpisodeDeleted     = "episode_deleted"
+	WebhookEpisodeUnpublished = "episode_unpublished" // снят с публикации или подкаст стал черновиком
+	WebhookLikesMilestone     = "likes_milestone"
+)
+
+var WebhookEvents = []string{
+	WebhookEpisodeCreated,
+	WebhookEpisodePublished,
+	WebhookEpisodeUpdated,
+	WebhookEpisodeDeleted,
+	WebhookEpisodeUnpublished,
+	WebhookLikesMilestone,
+}
+
+// Статусы доставки вебхука
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an author's subscription to events of their podcast: each event is
+// POSTed to URL and signed with Secret.
+type Webhook struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	PodcastID uint      `json:"podcastId" gorm:"index;not null"`
+	Podcast   *Podcast  `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	URL       string    `json:"url" gorm:"not null"`
+	Secret    string    `json:"-" gorm:"not null"`
+	Events    []string  `json:"events" gorm:"serializer:json"` // пустой список — все события
+	Active    bool      `json:"active" gorm:"not null"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// WebhookDelivery is one event sent to a webhook, with the outcome of its last attempt.
+type WebhookDelivery struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	WebhookID     uint            `json:"webhookId" gorm:"not null;uniqueIndex:idx_webhook_deliveries_key"`
+	Webhook       *Webhook        `json:"-" gorm:"constraint:OnDelete:CASCADE;"`
+	EventKey      string          `json:"-" gorm:"size:64;not null;uniqueIndex:idx_webhook_deliveries_key"` // не даёт поставить одно событие дважды
+	Event         string          `json:"event" gorm:"size:64;not null"`
+	Payload       json.RawMessage `json:"payload" gorm:"type:jsonb;serializer:json"` // тело запроса, при повторе отправляется как есть
+	RedeliveryOf  *uint           `json:"redeliveryOf,omitempty"`
+	Status        string          `json:"status" gorm:"size:16;not null;index:idx_webhook_deliveries_due"`
+	Attempts      int             `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time       `json:"nextAttemptAt" gorm:"index:idx_webhook_deliveries_due"`
+	ResponseCode  int             `json:"responseCode,omitempty"`
+	ResponseBody  string          `json:"responseBody,omitempty"` // начало ответа получателя
+	Error         string          `json:"error,omitempty"`
+	DeliveredAt   *time.Time      `json:"deliveredAt"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
@@ -0,0 +1,49 @@
+package netguard
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestPublic(t *testing.T) {
+	cases := map[string]bool{
+		"93.184.216.34":          true,
+		"2606:4700::1111":        true,
+		"127.0.0.1":              false,
+		"10.1.2.3":               false,
+		"172.20.0.1":             false,
+		"192.168.1.1":            false,
+		"100.64.0.1":             false,
+		"100.127.255.255":        false,
+		"169.254.169.254":        false,
+		"0.0.0.0":                false,
+		"255.255.255.255":        false,
+		"::1":                    false,
+		"::":                     false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+		"::ffff:93.184.216.34":   true,
+		"64:ff9b::a9fe:a9fe":     false,
+		"2002:7f00:1::":          false,
+		"fd00::1":                false,
+		"fe80::1%eth0":           false,
+		"ff02::1":                false,
+	}
+	for s, want := range cases {
+		if got := Public(netip.MustParseAddr(s)); got != want {
+			t.Errorf("Public(%s) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestControl(t *testing.T) {
+	if err := Control("tcp4", "127.0.0.1:80", nil); err != ErrPrivateAddress {
+		t.Errorf("loopback: got %v, want ErrPrivateAddress", err)
+	}
+	if err := Control("tcp6", "[::ffff:10.0.0.1]:443", nil); err != ErrPrivateAddress {
+		t.Errorf("mapped private: got %v, want ErrPrivateAddress", err)
+	}
+	if err := Control("tcp4", "93.184.216.34:443", nil); err != nil {
+		t.Errorf("public: got %v", err)
+	}
+}
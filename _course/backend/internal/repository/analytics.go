@@ -0,0 +1,379 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/models"
+)
+
+const (
+	// playEventsKey is a Redis list of accepted events waiting for FlushPlayEvents.
+	playEventsKey = "play:events"
+	// playRateLimit is how many events one client may send per minute; above it the
+	// client is treated as a bot and its events are dropped for the rest of the minute.
+	playRateLimit = 120
+	// episodePodcastTTL caches episode -> podcast lookups; episodes never move, and
+	// forgetEpisodeCache drops them when an episode stops being public.
+	episodePodcastTTL = time.Hour
+)
+
+// playDedupWindow is how long a repeated event of a type from the same client and
+// listener is ignored: reloading the player must not count a second play.
+var playDedupWindow = map[string]time.Duration{
+	models.PlayStart:    30 * time.Minute,
+	models.PlayProgress: 15 * time.Second,
+	models.PlayComplete: 30 * time.Minute,
+}
+
+// PlayClient identifies who sent play events. ListenerKey ends up on the events;
+// ClientKey (IP based) is only used to filter duplicates and floods.
+type PlayClient struct {
+	UserID      *uint
+	ListenerKey string
+	ClientKey   string
+}
+
+// RecordPlayEvents filters and stores play events and returns how many were accepted.
+// Events for episodes that are not published in a public podcast, duplicates within playDedupWindow and everything from
+// clients over playRateLimit are dropped. With Redis events are buffered for
+// FlushPlayEvents; without it they are written straight away.
+func (r *PodcastRepository) RecordPlayEvents(ctx context.Context, client PlayClient, events []models.PlayEvent) (int, error) {
+	if r.cacheEnable {
+		// the window starts with the key, which never outlives it even if a
+		// request dies between the two commands
+		rateKey := "play:rate:" + client.ClientKey + ":" + client.ListenerKey
+		var count *redis.IntCmd
+		_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetNX(ctx, rateKey, 0, time.Minute)
+			count = pipe.Incr(ctx, rateKey)
+			return nil
+		})
+		if err == nil && count.Val() > playRateLimit {
+			return 0, nil
+		}
+	}
+
+	now := time.Now()
+	accepted := make([]models.PlayEvent, 0, len(events))
+	for _, ev := range events {
+		window, ok := playDedupWindow[ev.Type]
+		if !ok {
+			continue
+		}
+		podcastID, err := r.episodePodcastID(ctx, ev.EpisodeID)
+		if err != nil {
+			return len(accepted), err
+		}
+		if podcastID == 0 {
+			continue
+		}
+		fresh, err := r.firstPlayEvent(ctx, client, ev, window)
+		if err != nil {
+			return len(accepted), err
+		}
+		if !fresh {
+			continue
+		}
+		accepted = append(accepted, models.PlayEvent{
+			EpisodeID:   ev.EpisodeID,
+			PodcastID:   podcastID,
+			UserID:      client.UserID,
+			ListenerKey: client.ListenerKey,
+			Type:        ev.Type,
+			Position:    max(ev.Position, 0),
+			CreatedAt:   now,
+		})
+	}
+	if len(accepted) == 0 {
+		return 0, nil
+	}
+	if err := r.storePlayEvents(ctx, accepted); err != nil {
+		return 0, err
+	}
+	return len(accepted), nil
+}
+
+// firstPlayEvent reports whether ev is the first of its type from the client for the
+// episode within window.
+func (r *PodcastRepository) firstPlayEvent(ctx context.Context, client PlayClient, ev models.PlayEvent, window time.Duration) (bool, error) {
+	if r.cacheEnable {
+		key := "play:seen:" + ev.Type + ":" + client.ClientKey + ":" + client.ListenerKey + ":" + strconv.FormatUint(uint64(ev.EpisodeID), 10)
+		ok, err := r.redis.SetNX(ctx, key, 1, window).Result()
+		if err == nil {
+			return ok, nil
+		}
+	}
+	// Without Redis the client key is not stored, so duplicates are judged per listener.
+	var n int64
+	err := r.db.WithContext(ctx).Model(&models.PlayEvent{}).
+		Where("episode_id = ? AND listener_key = ? AND type = ? AND created_at > ?",
+			ev.EpisodeID, client.ListenerKey, ev.Type, time.Now().Add(-window)).
+		Count(&n).Error
+	return n == 0, err
+}
+
+func (r *PodcastRepository) storePlayEvents(ctx context.Context, events []models.PlayEvent) error {
+	if r.cacheEnable {
+		values := make([]interface{}, 0, len(events))
+		for _, ev := range events {
+			b, err := json.Marshal(ev)
+			if err != nil {
+				return err
+			}
+			values = append(values, b)
+		}
+		if err := r.redis.RPush(ctx, playEventsKey, values...).Err(); err == nil {
+			return nil
+		}
+	}
+	return r.db.WithContext(ctx).CreateInBatches(&events, 500).Error
+}
+
+// FlushPlayEvents moves up to limit buffered events to Postgres and returns how many
+// were written. Events of episodes deleted meanwhile are discarded.
+func (r *PodcastRepository) FlushPlayEvents(ctx context.Context, limit int) (int, error) {
+	if !r.cacheEnable {
+		return 0, nil
+	}
+	raw, err := r.redis.LPopCount(ctx, playEventsKey, limit).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	events := make([]models.PlayEvent, 0, len(raw))
+	ids := make([]uint, 0, len(raw))
+	for _, data := range raw {
+		var ev models.PlayEvent
+		if json.Unmarshal([]byte(data), &ev) == nil {
+			events = append(events, ev)
+			ids = append(ids, ev.EpisodeID)
+		}
+	}
+	requeue := func() {
+		values := make([]interface{}, len(raw))
+		for i, data := range raw {
+			values[i] = data
+		}
+		r.redis.RPush(ctx, playEventsKey, values...)
+	}
+
+	var existing []uint
+	if err := r.db.WithContext(ctx).Model(&models.Episode{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		requeue()
+		return 0, err
+	}
+	known := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+	valid := events[:0]
+	for _, ev := range events {
+		if known[ev.EpisodeID] {
+			valid = append(valid, ev)
+		}
+	}
+	if len(valid) == 0 {
+		return 0, nil
+	}
+	if err := r.db.WithContext(ctx).CreateInBatches(&valid, 500).Error; err != nil {
+		requeue()
+		return 0, err
+	}
+	return len(valid), nil
+}
+
+// episodePodcastID returns the podcast of a published episode of a public podcast, the
+// only ones play events are counted for, and 0 for any other episode.
+func (r *PodcastRepository) episodePodcastID(ctx context.Context, episodeID uint) (uint, error) {
+	key := episodePodcastKey(episodeID)
+	if r.cacheEnable {
+		if id, err := r.redis.Get(ctx, key).Uint64(); err == nil {
+			return uint(id), nil
+		}
+	}
+	var ep models.Episode
+	if err := r.db.WithContext(ctx).Scopes(publishedEpisodes, publicPodcastEpisodes).Select("id", "podcast_id").First(&ep, episodeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if r.cacheEnable {
+		_ = r.redis.Set(ctx, key, ep.PodcastID, episodePodcastTTL).Err()
+	}
+	return ep.PodcastID, nil
+}
+
+func episodePodcastKey(episodeID uint) string {
+	return "episode:podcast:" + strconv.FormatUint(uint64(episodeID), 10)
+}
+
+// AnalyticsRange is a half-open time range; TZ names the zone of daily buckets.
+type AnalyticsRange struct {
+	From time.Time
+	To   time.Time
+	TZ   string
+}
+
+// PlayStats are the headline numbers. AvgCompletion is the mean share of an episode
+// a listener got through (0..1), nil when no episode with a known duration was played.
+type PlayStats struct {
+	Plays           int64    `json:"plays"`
+	UniqueListeners int64    `json:"uniqueListeners"`
+	AvgCompletion   *float64 `json:"avgCompletion"`
+}
+
+type EpisodeStats struct {
+	EpisodeID uint   `json:"episodeId"`
+	Title     string `json:"title"`
+	PlayStats
+}
+
+type PodcastStats struct {
+	PodcastID uint   `json:"podcastId"`
+	Title     string `json:"title"`
+	PlayStats
+}
+
+type DailyStats struct {
+	Date            string `json:"date"` // YYYY-MM-DD in the range time zone
+	Plays           int64  `json:"plays"`
+	UniqueListeners int64  `json:"uniqueListeners"`
+}
+
+// PodcastAnalytics is the dashboard of one podcast.
+type PodcastAnalytics struct {
+	PodcastStats
+	Episodes []EpisodeStats `json:"episodes"`
+	Daily    []DailyStats   `json:"daily"`
+}
+
+// sessionsCTE reduces events to one row per listener and episode. Completion is 1
+// once the listener completed the episode, otherwise the furthest position reached.
+const sessionsCTE = `sessions AS (
+	SELECT pe.podcast_id, pe.episode_id, pe.listener_key,
+		COUNT(*) FILTER (WHERE pe.type = 'start') AS plays,
+		CASE WHEN bool_or(pe.type = 'complete') THEN 1.0
+			ELSE LEAST(MAX(pe.position)::float8 / NULLIF(e.duration, 0), 1.0) END AS completion
+	FROM play_events pe JOIN episodes e ON e.id = pe.episode_id
+	WHERE pe.podcast_id IN @podcasts AND pe.created_at >= @from AND pe.created_at < @to
+	GROUP BY pe.podcast_id, pe.episode_id, pe.listener_key, e.duration
+)`
+
+// AuthorAnalytics returns totals for every podcast owned by userID.
+func (r *PodcastRepository) AuthorAnalytics(ctx context.Context, userID uint, rng AnalyticsRange) ([]PodcastStats, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).Model(&models.Podcast{}).Where("author_id = ?", userID).Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	stats := []PodcastStats{}
+	if len(ids) == 0 {
+		return stats, nil
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		WITH `+sessionsCTE+`
+		SELECT p.id AS podcast_id, p.title,
+			COALESCE(SUM(s.plays), 0) AS plays,
+			COUNT(DISTINCT s.listener_key) AS unique_listeners,
+			AVG(s.completion) AS avg_completion
+		FROM podcasts p LEFT JOIN sessions s ON s.podcast_id = p.id
+		WHERE p.id IN @podcasts
+		GROUP BY p.id, p.title
+		ORDER BY plays DESC, p.id DESC`, rangeArgs(ids, rng)).Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// PodcastAnalytics returns the dashboard of a podcast owned by userID. It returns nil, nil
+// for an unknown podcast and a "forbidden" error for someone else's.
+func (r *PodcastRepository) PodcastAnalytics(ctx context.Context, podcastID, userID uint, rng AnalyticsRange) (*PodcastAnalytics, error) {
+	var podcast models.Podcast
+	if err := r.db.WithContext(ctx).Select("id", "title", "author_id").First(&podcast, podcastID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if podcast.AuthorID != userID {
+		return nil, errors.New("forbidden")
+	}
+
+	db := r.db.WithContext(ctx)
+	args := rangeArgs([]uint{podcastID}, rng)
+	out := &PodcastAnalytics{
+		PodcastStats: PodcastStats{PodcastID: podcast.ID, Title: podcast.Title},
+		Episodes:     []EpisodeStats{},
+	}
+	if err := db.Raw(`
+		WITH `+sessionsCTE+`
+		SELECT COALESCE(SUM(plays), 0) AS plays,
+			COUNT(DISTINCT listener_key) AS unique_listeners,
+			AVG(completion) AS avg_completion
+		FROM sessions`, args).Scan(&out.PlayStats).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Raw(`
+		WITH `+sessionsCTE+`
+		SELECT e.id AS episode_id, e.title,
+			COALESCE(SUM(s.plays), 0) AS plays,
+			COUNT(s.listener_key) AS unique_listeners,
+			AVG(s.completion) AS avg_completion
+		FROM episodes e LEFT JOIN sessions s ON s.episode_id = e.id
+		WHERE e.podcast_id IN @podcasts
+		GROUP BY e.id, e.title
+		ORDER BY plays DESC, e.id DESC`, args).Scan(&out.Episodes).Error; err != nil {
+		return nil, err
+	}
+
+	var days []DailyStats
+	if err := db.Raw(`
+		SELECT to_char(created_at AT TIME ZONE @tz, 'YYYY-MM-DD') AS date,
+			COUNT(*) FILTER (WHERE type = 'start') AS plays,
+			COUNT(DISTINCT listener_key) AS unique_listeners
+		FROM play_events
+		WHERE podcast_id IN @podcasts AND created_at >= @from AND created_at < @to
+		GROUP BY 1
+		ORDER BY 1`, args).Scan(&days).Error; err != nil {
+		return nil, err
+	}
+	out.Daily = fillDays(days, rng)
+	return out, nil
+}
+
+func rangeArgs(podcastIDs []uint, rng AnalyticsRange) map[string]interface{} {
+	return map[string]interface{}{"podcasts": podcastIDs, "from": rng.From, "to": rng.To, "tz": rng.TZ}
+}
+
+// fillDays adds zero rows for the days of rng without plays.
+func fillDays(days []DailyStats, rng AnalyticsRange) []DailyStats {
+	loc, err := time.LoadLocation(rng.TZ)
+	if err != nil {
+		loc = time.UTC
+	}
+	byDate := make(map[string]DailyStats, len(days))
+	for _, d := range days {
+		byDate[d.Date] = d
+	}
+	out := []DailyStats{}
+	from := rng.From.In(loc)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); day.Before(rng.To); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		d, ok := byDate[date]
+		if !ok {
+			d = DailyStats{Date: date}
+		}
+		out = append(out, d)
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"podcast-backend/internal/models"
+)
+
+// Plays are only counted for episodes anyone may find: published ones of public podcasts.
+func TestRecordPlayEventsPublicEpisodesOnly(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	_, p := testPodcast(t, tx)
+	unlisted := &models.Podcast{Title: "Unlisted", AuthorID: p.AuthorID, Visibility: models.PodcastUnlisted}
+	if err := tx.Create(unlisted).Error; err != nil {
+		t.Fatal(err)
+	}
+	published := models.Episode{PodcastID: p.ID, Title: "Published", Status: models.EpisodePublished}
+	draft := models.Episode{PodcastID: p.ID, Title: "Draft", Status: models.EpisodeDraft}
+	hidden := models.Episode{PodcastID: unlisted.ID, Title: "Unlisted", Status: models.EpisodePublished}
+	for _, ep := range []*models.Episode{&published, &draft, &hidden} {
+		if err := tx.Create(ep).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	repo := NewPodcastRepository(tx, nil)
+	client := PlayClient{ListenerKey: "listener", ClientKey: "client"}
+	n, err := repo.RecordPlayEvents(ctx, client, []models.PlayEvent{
+		{EpisodeID: published.ID, Type: models.PlayStart},
+		{EpisodeID: draft.ID, Type: models.PlayStart},
+		{EpisodeID: hidden.ID, Type: models.PlayStart},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored []models.PlayEvent
+	if err := tx.Where("podcast_id IN ?", []uint{p.ID, unlisted.ID}).Find(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || len(stored) != 1 || stored[0].EpisodeID != published.ID || stored[0].PodcastID != p.ID {
+		t.Errorf("accepted %d, stored %+v; want only the published episode", n, stored)
+	}
+}
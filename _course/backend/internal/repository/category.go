@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/categories"
+	"podcast-backend/internal/models"
+)
+
+// CategoryNode is a category with the number of public podcasts filed under it or any
+// of its subcategories.
+type CategoryNode struct {
+	models.Category
+	PodcastCount int64          `json:"podcastCount"`
+	Children     []CategoryNode `json:"children,omitempty"`
+}
+
+// Categories returns the category tree in display order.
+func (r *PodcastRepository) Categories(ctx context.Context) ([]CategoryNode, error) {
+	db := r.db.WithContext(ctx)
+	var all []models.Category
+	if err := db.Order("position, id").Find(&all).Error; err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		CategoryID uint
+		N          int64
+	}
+	if err := db.Model(&models.Podcast{}).
+		Select("category_id, COUNT(*) AS n").
+		Where("category_id IS NOT NULL").
+		Scopes(publicPodcasts).
+		Group("category_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	byCategory := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		byCategory[c.CategoryID] = c.N
+	}
+
+	children := make(map[uint][]models.Category)
+	var roots []models.Category
+	for _, c := range all {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+	var build func(c models.Category) CategoryNode
+	build = func(c models.Category) CategoryNode {
+		node := CategoryNode{Category: c, PodcastCount: byCategory[c.ID]}
+		for _, child := range children[c.ID] {
+			n := build(child)
+			node.PodcastCount += n.PodcastCount
+			node.Children = append(node.Children, n)
+		}
+		return node
+	}
+	tree := make([]CategoryNode, 0, len(roots))
+	for _, c := range roots {
+		tree = append(tree, build(c))
+	}
+	return tree, nil
+}
+
+// CategoryBySlug returns nil, nil for an unknown slug.
+func (r *PodcastRepository) CategoryBySlug(ctx context.Context, slug string) (*models.Category, error) {
+	var c models.Category
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&c).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// assignCategory links p to the category its free-form Category string names,
+// or unlinks it when the string matches none.
+func assignCategory(tx *gorm.DB, p *models.Podcast) error {
+	p.CategoryID = nil
+	if p.Category == nil {
+		return nil
+	}
+	slug := categories.Match(*p.Category)
+	if slug == "" {
+		return nil
+	}
+	var ids []uint
+	if err := tx.Model(&models.Category{}).Where("slug = ?", slug).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		p.CategoryID = &ids[0]
+	}
+	return nil
+}
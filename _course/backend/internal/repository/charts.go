@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/models"
+)
+
+// ErrInvalidPeriod is returned for an unknown chart period.
+var ErrInvalidPeriod = errors.New("invalid period")
+
+// chartPeriod is a chart window; engagement loses half its weight every halfLife,
+// so the charts favour what is rising over what was big at the start of the window.
+type chartPeriod struct {
+	span     time.Duration
+	halfLife time.Duration
+}
+
+var chartPeriods = map[string]chartPeriod{
+	"day":   {span: 24 * time.Hour, halfLife: 6 * time.Hour},
+	"week":  {span: 7 * 24 * time.Hour, halfLife: 2 * 24 * time.Hour},
+	"month": {span: 30 * 24 * time.Hour, halfLife: 7 * 24 * time.Hour},
+}
+
+// Engagement weights. A play is a started history entry, which is already de-duplicated
+// per listener over a few hours.
+const (
+	chartWeightPlay     = 1.0
+	chartWeightFinish   = 2.0
+	chartWeightLike     = 3.0
+	chartWeightLibrary  = 4.0
+	chartWeightFavorite = 5.0
+
+	// chartSize is how many entries are kept per chart and category.
+	chartSize = 1000
+)
+
+// ChartItem is one chart position. Episode is nil in podcast charts.
+type ChartItem struct {
+	Rank    int             `json:"rank"`
+	Score   float64         `json:"score"`
+	Episode *models.Episode `json:"episode,omitempty"`
+	Podcast *models.Podcast `json:"podcast"`
+}
+
+// RebuildCharts recomputes every chart from recent engagement, in one transaction so
+// readers never see a partial chart. When another instance is rebuilding them it
+// returns without doing anything.
+func (r *PodcastRepository) RebuildCharts(ctx context.Context) error {
+	now := time.Now()
+	_, err := exclusively(ctx, r.db, chartsLockKey, func(tx *gorm.DB) error {
+		for name, period := range chartPeriods {
+			args := map[string]interface{}{
+				"period": name,
+				"now":    now,
+				"since":  now.Add(-period.span),
+				"half":   period.halfLife.Seconds(),
+				"top":    chartSize,
+			}
+			for kind, query := range map[string]string{models.ChartEpisodes: episodeChartSQL, models.ChartPodcasts: podcastChartSQL} {
+				if err := tx.Where("kind = ? AND period = ?", kind, name).Delete(&models.ChartEntry{}).Error; err != nil {
+					return fmt.Errorf("%s %s chart: %w", name, kind, err)
+				}
+				if err := tx.Exec(query, args).Error; err != nil {
+					return fmt.Errorf("%s %s chart: %w", name, kind, err)
+				}
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// decayed sums event weights, halving them every @half seconds of age.
+const decayed = `SUM(ev.weight * power(0.5, extract(epoch FROM CAST(@now AS timestamptz) - ev.created_at) / CAST(@half AS double precision)))`
+
+// Charts keep the top @top of every category, so a category filter on a small
+// category is not left with whatever made it into an overall top.
+var episodeChartSQL = fmt.Sprintf(`
+	INSERT INTO chart_entries (kind, period, item_id, category_id, score, computed_at)
+	SELECT '%s', @period, item_id, category_id, score, @now FROM (
+		SELECT ev.episode_id AS item_id, p.category_id, `+decayed+` AS score,
+			ROW_NUMBER() OVER (PARTITION BY p.category_id ORDER BY `+decayed+` DESC, ev.episode_id) AS n
+		FROM (
+			SELECT episode_id, created_at, CASE event WHEN '%s' THEN %g ELSE %g END AS weight
+				FROM history_entries WHERE created_at > @since
+			UNION ALL
+			SELECT episode_id, created_at, %g FROM episode_likes WHERE created_at > @since
+		) ev
+		JOIN episodes e ON e.id = ev.episode_id AND e.status = '%s'
+		JOIN podcasts p ON p.id = e.podcast_id AND p.visibility = '%s'
+		GROUP BY ev.episode_id, p.category_id
+	) ranked
+	WHERE n <= @top`,
+	models.ChartEpisodes, models.HistoryFinished, chartWeightFinish, chartWeightPlay, chartWeightLike, models.EpisodePublished, models.PodcastPublic)
+
+var podcastChartSQL = fmt.Sprintf(`
+	INSERT INTO chart_entries (kind, period, item_id, category_id, score, computed_at)
+	SELECT '%s', @period, item_id, category_id, score, @now FROM (
+		SELECT ev.podcast_id AS item_id, p.category_id, `+decayed+` AS score,
+			ROW_NUMBER() OVER (PARTITION BY p.category_id ORDER BY `+decayed+` DESC, ev.podcast_id) AS n
+		FROM (
+			SELECT podcast_id, created_at, CASE event WHEN '%s' THEN %g ELSE %g END AS weight
+				FROM history_entries WHERE created_at > @since
+			UNION ALL
+			SELECT e.podcast_id, l.created_at, %g FROM episode_likes l JOIN episodes e ON e.id = l.episode_id
+				WHERE l.created_at > @since
+			UNION ALL
+			SELECT podcast_id, created_at, %g FROM library_items WHERE created_at > @since
+			UNION ALL
+			SELECT podcast_id, created_at, %g FROM favorites WHERE created_at > @since
+		) ev
+		JOIN podcasts p ON p.id = ev.podcast_id AND p.visibility = '%s'
+		GROUP BY ev.podcast_id, p.category_id
+	) ranked
+	WHERE n <= @top`,
+	models.ChartPodcasts, models.HistoryFinished, chartWeightFinish, chartWeightPlay, chartWeightLike, chartWeightLibrary, chartWeightFavorite, models.PodcastPublic)
+
+// Chart returns the top of a chart, optionally restricted to a category slug
+// (subcategories included). Ranks are positions within the returned list.
+func (r *PodcastRepository) Chart(ctx context.Context, kind, period, category string, limit int) ([]ChartItem, error) {
+	if _, ok := chartPeriods[period]; !ok {
+		return nil, ErrInvalidPeriod
+	}
+	db := r.db.WithContext(ctx)
+	q := db.Where("kind = ? AND period = ?", kind, period)
+	if category != "" {
+		q = q.Where(`category_id IN (
+			SELECT c.id FROM categories c LEFT JOIN categories parent ON parent.id = c.parent_id
+			WHERE c.slug = ? OR parent.slug = ?)`, category, category)
+	}
+	var entries []models.ChartEntry
+	if err := q.Order("score DESC, item_id").Limit(limit).Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	items := []ChartItem{}
+	if len(entries) == 0 {
+		return items, nil
+	}
+	ids := make([]uint, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ItemID
+	}
+
+	episodes := make(map[uint]*models.Episode)
+	podcastIDs := ids
+	if kind == models.ChartEpisodes {
+		var list []models.Episode
+		if err := db.Scopes(publishedEpisodes).Where("id IN ?", ids).Find(&list).Error; err != nil {
+			return nil, err
+		}
+		podcastIDs = podcastIDs[:0:0]
+		for i := range list {
+			episodes[list[i].ID] = &list[i]
+			podcastIDs = append(podcastIDs, list[i].PodcastID)
+		}
+	}
+	var podcasts []models.Podcast
+	if err := db.Scopes(publicPodcasts).Where("id IN ?", podcastIDs).Find(&podcasts).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Podcast, len(podcasts))
+	for i := range podcasts {
+		byID[podcasts[i].ID] = &podcasts[i]
+	}
+
+	for _, e := range entries {
+		item := ChartItem{Score: e.Score}
+		if kind == models.ChartEpisodes {
+			if item.Episode = episodes[e.ItemID]; item.Episode == nil {
+				continue // deleted or unpublished since the chart was built
+			}
+			item.Podcast = byID[item.Episode.PodcastID]
+		} else {
+			item.Podcast = byID[e.ItemID]
+		}
+		if item.Podcast == nil {
+			continue // deleted or hidden
+		}
+		item.Rank = len(items) + 1
+		items = append(items, item)
+	}
+	return items, nil
+}
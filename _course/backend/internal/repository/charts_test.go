@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"podcast-backend/internal/models"
+)
+
+// Only public podcasts and published episodes take chart places.
+func TestRebuildCharts(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	user, public := testPodcast(t, tx)
+	unlisted := &models.Podcast{Title: "Unlisted", AuthorID: user.ID, AuthorEmail: user.Email, Visibility: models.PodcastUnlisted}
+	if err := tx.Create(unlisted).Error; err != nil {
+		t.Fatal(err)
+	}
+	var episodes []models.Episode
+	for _, p := range []*models.Podcast{public, unlisted} {
+		ep := models.Episode{PodcastID: p.ID, Title: p.Title + " pilot", Status: models.EpisodePublished}
+		if err := tx.Create(&ep).Error; err != nil {
+			t.Fatal(err)
+		}
+		episodes = append(episodes, ep)
+		if err := insertHistory(tx, user.ID, ep.ID, models.HistoryFinished); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewPodcastRepository(tx, nil)
+	if err := r.RebuildCharts(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []models.ChartEntry
+	if err := tx.Where("period = ? AND item_id IN ?", "week", []uint{public.ID, unlisted.ID, episodes[0].ID, episodes[1].ID}).
+		Order("kind").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("entries = %+v, want the public podcast and its episode", entries)
+	}
+	for _, e := range entries {
+		want := public.ID
+		if e.Kind == models.ChartEpisodes {
+			want = episodes[0].ID
+		}
+		if e.ItemID != want || e.Score <= 0 {
+			t.Errorf("%s chart: item %d with score %g, want %d", e.Kind, e.ItemID, e.Score, want)
+		}
+	}
+}
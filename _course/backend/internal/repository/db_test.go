@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"podcast-backend/internal/db"
+	"podcast-backend/internal/models"
+)
+
+var (
+	testDBOnce sync.Once
+	testDBConn *gorm.DB
+	testDBErr  error
+)
+
+// testDB returns a transaction on the database named by TEST_POSTGRES_DSN, migrated
+// like on start and rolled back when the test ends. Tests needing Postgres are
+// skipped without it, e.g. outside docker compose.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+	testDBOnce.Do(func() {
+		testDBConn, testDBErr = gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if testDBErr == nil {
+			testDBErr = db.AutoMigrate(testDBConn)
+		}
+		if testDBErr == nil {
+			testDBErr = db.Migrate(testDBConn)
+		}
+	})
+	if testDBErr != nil {
+		t.Fatalf("test database: %v", testDBErr)
+	}
+	tx := testDBConn.Begin()
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+// testPodcast creates an author with one public podcast.
+func testPodcast(t *testing.T, tx *gorm.DB) (*models.User, *models.Podcast) {
+	t.Helper()
+	user := &models.User{Name: "Author", Email: t.Name() + "@example.com", PasswordHash: "x"}
+	if err := tx.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	p := &models.Podcast{Title: "Test podcast", Author: "Author", AuthorID: user.ID, AuthorEmail: user.Email, Visibility: models.PodcastPublic}
+	if err := tx.Create(p).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user, p
+}
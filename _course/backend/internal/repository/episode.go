@@ -3,20 +3,27 @@ package repository
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 
 	"podcast-backend/internal/models"
 )
 
 type EpisodeRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	redis *redis.Client // для сброса кэша списков подкастов, может быть nil
 }
 
-func NewEpisodeRepository(db *gorm.DB) *EpisodeRepository {
-	return &EpisodeRepository{db: db}
+func NewEpisodeRepository(db *gorm.DB, redisClient *redis.Client) *EpisodeRepository {
+	return &EpisodeRepository{db: db, redis: redisClient}
 }
 
+// Update replaces the episode metadata. Status and publishAt are only changed when
+// the request carries one of them. An episode that listeners could not see before
+// is announced as episode_published, any other change as episode_updated; one taken
+// back from listeners also records episode_unpublished.
 func (r *EpisodeRepository) Update(ctx context.Context, episodeID uint, data *models.Episode, userID uint) (*models.Episode, error) {
 	var ep models.Episode
 	if err := r.db.WithContext(ctx).First(&ep, episodeID).Error; err != nil {
@@ -33,15 +40,44 @@ func (r *EpisodeRepository) Update(ctx context.Context, episodeID uint, data *mo
 		return nil, errors.New("forbidden")
 	}
 
+	wasPublished := ep.Status == models.EpisodePublished
+	if data.Status != "" || data.PublishAt != nil {
+		next := models.Episode{Status: data.Status, PublishAt: data.PublishAt}
+		if wasPublished && next.PublishAt == nil && (next.Status == "" || next.Status == models.EpisodePublished) {
+			next.PublishAt = ep.PublishAt // keep the original publication time
+		}
+		if err := applySchedule(&next, time.Now()); err != nil {
+			return nil, err
+		}
+		ep.Status, ep.PublishAt = next.Status, next.PublishAt
+	}
 	ep.Title = data.Title
 	ep.Description = data.Description
 	ep.Date = data.Date
 	ep.Duration = data.Duration
 	ep.AudioURL = data.AudioURL
 
-	if err := r.db.WithContext(ctx).Save(&ep).Error; err != nil {
+	evType := "episode_updated"
+	if !wasPublished && ep.Status == models.EpisodePublished {
+		evType = "episode_published"
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&ep).Error; err != nil {
+			return err
+		}
+		if err := recordEpisodeEvent(tx, evType, &ep, &pod, &ep); err != nil {
+			return err
+		}
+		if wasPublished && ep.Status != models.EpisodePublished && pod.Visibility != models.PodcastDraft {
+			return recordUnpublished(tx, &ep)
+		}
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
+	bumpCacheGen(ctx, r.redis)
+	forgetEpisodeCache(ctx, r.redis, ep.ID)
 	return &ep, nil
 }
 
@@ -57,36 +93,65 @@ func (r *EpisodeRepository) Delete(ctx context.Context, episodeID uint, userID u
 	if pod.AuthorID != userID {
 		return errors.New("forbidden")
 	}
-	return r.db.WithContext(ctx).Delete(&models.Episode{}, episodeID).Error
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Episode{}, episodeID).Error; err != nil {
+			return err
+		}
+		return recordEpisodeEvent(tx, "episode_deleted", &ep, &pod, map[string]interface{}{"episodeId": episodeID})
+	})
+	if err != nil {
+		return err
+	}
+	bumpCacheGen(ctx, r.redis)
+	forgetEpisodeCache(ctx, r.redis, episodeID)
+	return nil
 }
 
+// ToggleLike likes or unlikes an episode and records the new count as episode_likes.
+// It returns gorm.ErrRecordNotFound for unknown episodes.
 func (r *EpisodeRepository) ToggleLike(ctx context.Context, episodeID uint, userID uint) (int, bool, error) {
-	var like models.EpisodeLike
-	err := r.db.WithContext(ctx).Where("user_id = ? AND episode_id = ?", userID, episodeID).First(&like).Error
+	var ep models.Episode
+	if err := r.db.WithContext(ctx).First(&ep, episodeID).Error; err != nil {
+		return 0, false, err
+	}
+	var pod models.Podcast
+	if err := r.db.WithContext(ctx).First(&pod, ep.PodcastID).Error; err != nil {
+		return 0, false, err
+	}
+
+	var count int64
 	added := false
-	if err == nil {
-		if err := r.db.WithContext(ctx).Delete(&like).Error; err != nil {
-			return 0, false, err
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var like models.EpisodeLike
+		err := tx.Where("user_id = ? AND episode_id = ?", userID, episodeID).First(&like).Error
+		if err == nil {
+			if err := tx.Delete(&like).Error; err != nil {
+				return err
+			}
+		} else {
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+			like = models.EpisodeLike{UserID: userID, EpisodeID: episodeID}
+			if err := tx.Create(&like).Error; err != nil {
+				return err
+			}
+			added = true
 		}
-	} else {
-		if err != gorm.ErrRecordNotFound {
-			return 0, false, err
+
+		// recalc likes
+		if err := tx.Model(&models.EpisodeLike{}).Where("episode_id = ?", episodeID).Count(&count).Error; err != nil {
+			return err
 		}
-		like = models.EpisodeLike{UserID: userID, EpisodeID: episodeID}
-		if err := r.db.WithContext(ctx).Create(&like).Error; err != nil {
-			return 0, false, err
+		if err := tx.Model(&models.Episode{}).Where("id = ?", episodeID).Update("likes", count).Error; err != nil {
+			return err
 		}
-		added = true
-	}
-
-	// recalc likes
-	var count int64
-	if err := r.db.WithContext(ctx).Model(&models.EpisodeLike{}).Where("episode_id = ?", episodeID).Count(&count).Error; err != nil {
-		return 0, false, err
-	}
-	if err := r.db.WithContext(ctx).Model(&models.Episode{}).Where("id = ?", episodeID).Update("likes", count).Error; err != nil {
+		return recordEpisodeEvent(tx, "episode_likes", &ep, &pod, map[string]interface{}{"episodeId": episodeID, "likes": count})
+	})
+	if err != nil {
 		return 0, false, err
 	}
+	bumpCacheGen(ctx, r.redis)
 	return int(count), added, nil
 }
 
@@ -98,3 +163,69 @@ func (r *EpisodeRepository) LikedEpisodeIDs(ctx context.Context, userID uint) ([
 	return ids, nil
 }
 
+func (r *EpisodeRepository) Get(ctx context.Context, episodeID uint) (*models.Episode, error) {
+	var ep models.Episode
+	if err := r.db.WithContext(ctx).First(&ep, episodeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ep, nil
+}
+
+// GetForViewer loads an episode as viewerID (0 for anonymous) may see it: its author
+// always, anyone else only once it is published in a podcast that is not a draft.
+// public reports whether everyone may see it; nil, false, nil if the episode does not
+// exist or is hidden from the viewer.
+func (r *EpisodeRepository) GetForViewer(ctx context.Context, episodeID uint, viewerID uint) (ep *models.Episode, public bool, err error) {
+	if ep, err = r.Get(ctx, episodeID); err != nil || ep == nil {
+		return nil, false, err
+	}
+	var pod models.Podcast
+	if err := r.db.WithContext(ctx).First(&pod, ep.PodcastID).Error; err != nil {
+		return nil, false, err
+	}
+	public = ep.Status == models.EpisodePublished && pod.Visibility != models.PodcastDraft
+	if !public && (viewerID == 0 || viewerID != pod.AuthorID) {
+		return nil, false, nil
+	}
+	return ep, public, nil
+}
+
+// GetForAuthor loads an episode and checks that userID owns its podcast.
+func (r *EpisodeRepository) GetForAuthor(ctx context.Context, episodeID uint, userID uint) (*models.Episode, error) {
+	ep, err := r.Get(ctx, episodeID)
+	if err != nil || ep == nil {
+		return nil, err
+	}
+	var pod models.Podcast
+	if err := r.db.WithContext(ctx).First(&pod, ep.PodcastID).Error; err != nil {
+		return nil, err
+	}
+	if pod.AuthorID != userID {
+		return nil, errors.New("forbidden")
+	}
+	return ep, nil
+}
+
+// SaveAudio persists the uploaded file reference together with metadata derived from it.
+func (r *EpisodeRepository) SaveAudio(ctx context.Context, ep *models.Episode) error {
+	var pod models.Podcast
+	if err := r.db.WithContext(ctx).First(&pod, ep.PodcastID).Error; err != nil {
+		return err
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(ep).
+			Select("audio_key", "audio_size", "audio_type", "audio_url", "duration", "title", "description").
+			Updates(ep).Error; err != nil {
+			return err
+		}
+		return recordEpisodeEvent(tx, "episode_updated", ep, &pod, ep)
+	})
+	if err != nil {
+		return err
+	}
+	bumpCacheGen(ctx, r.redis)
+	return nil
+}
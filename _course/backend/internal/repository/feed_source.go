@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"podcast-backend/internal/models"
+)
+
+// FeedSource returns the sync status of an imported podcast for its author.
+func (r *PodcastRepository) FeedSource(ctx context.Context, podcastID uint, userID uint) (*models.FeedSource, error) {
+	var podcast models.Podcast
+	if err := r.db.WithContext(ctx).First(&podcast, podcastID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if podcast.AuthorID != userID {
+		return nil, errors.New("forbidden")
+	}
+	var src models.FeedSource
+	if err := r.db.WithContext(ctx).First(&src, "podcast_id = ?", podcastID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &src, nil
+}
+
+// ClaimFeedSources takes up to limit feeds whose next run is at or before now, oldest
+// first, and puts them off by lease, so no other instance polls them meanwhile and the
+// feeds of a crashed refresher are polled again once the lease runs out.
+func (r *PodcastRepository) ClaimFeedSources(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.FeedSource, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE feed_sources SET next_run_at = ?
+		WHERE podcast_id IN (
+			SELECT podcast_id FROM feed_sources
+			WHERE next_run_at <= ?
+			ORDER BY next_run_at, podcast_id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED)
+		RETURNING podcast_id`,
+		now.Add(lease), now, limit).Scan(&ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	var sources []models.FeedSource
+	if err := r.db.WithContext(ctx).Where("podcast_id IN ?", ids).Order("podcast_id").Find(&sources).Error; err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// SaveFeedSource persists poll status (validators, last error, next run). The url is
+// left alone: the author may have re-imported the podcast from another one meanwhile.
+func (r *PodcastRepository) SaveFeedSource(ctx context.Context, src *models.FeedSource) error {
+	return r.db.WithContext(ctx).Model(src).
+		Select("etag", "last_modified", "last_fetched_at", "last_error", "failures", "next_run_at", "updated_at").
+		Updates(src).Error
+}
+
+// SyncFeed applies a freshly fetched feed to an imported podcast: channel metadata and
+// changed episodes are updated in place, items with unseen guids are inserted. A feed
+// that changed nothing writes nothing, so feed validators and caches stay valid.
+// It returns the newly created episodes.
+func (r *PodcastRepository) SyncFeed(ctx context.Context, podcastID uint, meta *models.Podcast, episodes []models.Episode) ([]models.Episode, error) {
+	var created []models.Episode
+	var changed []*models.Episode
+	var podcastChanged bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var podcast models.Podcast
+		if err := tx.First(&podcast, podcastID).Error; err != nil {
+			return err
+		}
+		before := podcast
+		if meta.Title != "" {
+			podcast.Title = meta.Title
+		}
+		if meta.Author != "" {
+			podcast.Author = meta.Author
+		}
+		if meta.Language != "" {
+			podcast.Language = meta.Language
+		}
+		podcast.Description = meta.Description
+		podcast.Image = meta.Image
+		podcast.Category = meta.Category
+		podcastChanged = podcast.Title != before.Title || podcast.Author != before.Author ||
+			podcast.Description != before.Description || podcast.Language != before.Language ||
+			!sameString(podcast.Image, before.Image) || !sameString(podcast.Category, before.Category)
+		if podcastChanged {
+			if err := assignCategory(tx, &podcast); err != nil {
+				return err
+			}
+			if err := tx.Omit(clause.Associations).Save(&podcast).Error; err != nil {
+				return err
+			}
+			if err := recordPodcastEvent(tx, "podcast_updated", &podcast, &podcast); err != nil {
+				return err
+			}
+		}
+
+		var existing []models.Episode
+		if err := tx.Where("podcast_id = ? AND guid IS NOT NULL", podcastID).Find(&existing).Error; err != nil {
+			return err
+		}
+		created, changed = diffEpisodes(existing, episodes)
+		for _, ep := range changed {
+			if err := tx.Model(ep).
+				Select("title", "description", "date", "duration", "audio_url", "updated_at").
+				Updates(ep).Error; err != nil {
+				return err
+			}
+			if err := recordEpisodeEvent(tx, "episode_updated", ep, &podcast, ep); err != nil {
+				return err
+			}
+		}
+		for i := range created {
+			created[i].PodcastID = podcastID
+		}
+
+		if len(created) == 0 {
+			return nil
+		}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "podcast_id"}, {Name: "guid"}},
+			DoNothing: true,
+		}).CreateInBatches(&created, 100).Error; err != nil {
+			return err
+		}
+		for i := range created {
+			if created[i].ID == 0 {
+				continue // inserted concurrently by someone else
+			}
+			if err := recordEpisodeEvent(tx, "episode_created", &created[i], &podcast, &created[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if podcastChanged || len(changed) > 0 || len(created) > 0 {
+		r.invalidateCache(ctx)
+	}
+	return created, nil
+}
+
+// sameString reports whether two optional strings hold the same value.
+func sameString(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// diffEpisodes matches feed items to the stored episodes of a podcast by guid. Items
+// without a guid and repeated guids are ignored. It returns the items to create and
+// the stored episodes whose title, description, date, duration or audio changed,
+// already updated from their items.
+func diffEpisodes(existing []models.Episode, items []models.Episode) (created []models.Episode, changed []*models.Episode) {
+	byGUID := make(map[string]*models.Episode, len(existing))
+	for i := range existing {
+		if existing[i].GUID != nil {
+			byGUID[*existing[i].GUID] = &existing[i]
+		}
+	}
+	seen := make(map[string]bool, len(items))
+	for _, ep := range items {
+		if ep.GUID == nil || seen[*ep.GUID] {
+			continue
+		}
+		seen[*ep.GUID] = true
+
+		old, ok := byGUID[*ep.GUID]
+		if !ok {
+			created = append(created, ep)
+			continue
+		}
+		if old.Title == ep.Title && old.Description == ep.Description && old.Date == ep.Date &&
+			old.Duration == ep.Duration && old.AudioURL == ep.AudioURL {
+			continue
+		}
+		old.Title, old.Description, old.Date = ep.Title, ep.Description, ep.Date
+		old.Duration, old.AudioURL = ep.Duration, ep.AudioURL
+		changed = append(changed, old)
+	}
+	return created, changed
+}
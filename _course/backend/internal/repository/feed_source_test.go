@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"podcast-backend/internal/models"
+)
+
+func guid(s string) *string { return &s }
+
+func TestDiffEpisodes(t *testing.T) {
+	existing := []models.Episode{
+		{ID: 1, GUID: guid("a"), Title: "A", Duration: 60, AudioURL: "https://example.com/a.mp3"},
+		{ID: 2, GUID: guid("b"), Title: "B", Duration: 60, AudioURL: "https://example.com/b.mp3"},
+		{ID: 3, GUID: guid("gone"), Title: "Gone from the feed"},
+	}
+	items := []models.Episode{
+		{GUID: guid("a"), Title: "A", Duration: 60, AudioURL: "https://example.com/a.mp3"},        // unchanged
+		{GUID: guid("b"), Title: "B, fixed", Duration: 61, AudioURL: "https://example.com/b.mp3"}, // changed
+		{GUID: guid("c"), Title: "C"},       // new
+		{GUID: guid("c"), Title: "C again"}, // repeated guid
+		{Title: "No guid"},
+	}
+
+	created, changed := diffEpisodes(existing, items)
+
+	if len(created) != 1 || *created[0].GUID != "c" || created[0].Title != "C" {
+		t.Errorf("created = %+v, want item c once", created)
+	}
+	if len(changed) != 1 || changed[0].ID != 2 {
+		t.Fatalf("changed = %+v, want episode 2", changed)
+	}
+	if changed[0].Title != "B, fixed" || changed[0].Duration != 61 {
+		t.Errorf("episode 2 = %q, %ds; want the feed's values", changed[0].Title, changed[0].Duration)
+	}
+	if existing[2].Title != "Gone from the feed" {
+		t.Error("an episode missing from the feed was changed")
+	}
+}
+
+func TestSyncFeed(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	_, p := testPodcast(t, tx)
+	if err := tx.Create(&models.Episode{PodcastID: p.ID, GUID: guid("a"), Title: "A", Status: models.EpisodePublished}).Error; err != nil {
+		t.Fatal(err)
+	}
+	r := NewPodcastRepository(tx, nil)
+
+	feedMeta := func() *models.Podcast {
+		// fresh pointers every time, as parsing a feed gives
+		return &models.Podcast{Title: "Renamed", Description: "From the feed",
+			Image: guid("https://example.com/cover.jpg"), Category: guid("Technology")}
+	}
+	items := []models.Episode{
+		{GUID: guid("a"), Title: "A, edited", Status: models.EpisodePublished},
+		{GUID: guid("b"), Title: "B", Status: models.EpisodePublished},
+	}
+	created, err := r.SyncFeed(ctx, p.ID, feedMeta(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 1 || created[0].ID == 0 || created[0].PodcastID != p.ID {
+		t.Fatalf("created = %+v, want episode b", created)
+	}
+
+	var synced models.Podcast
+	if err := tx.Preload("Episodes").First(&synced, p.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if synced.Title != "Renamed" || synced.Description != "From the feed" || len(synced.Episodes) != 2 {
+		t.Errorf("podcast %q: %q with %d episodes", synced.Title, synced.Description, len(synced.Episodes))
+	}
+	var types []string
+	if err := tx.Model(&models.OutboxEvent{}).Where("podcast_id = ?", p.ID).Order("id").Pluck("type", &types).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 3 || types[0] != "podcast_updated" || types[1] != "episode_updated" || types[2] != "episode_created" {
+		t.Errorf("events = %v", types)
+	}
+
+	// the same feed again changes nothing, not even updated_at behind the feed validators
+	if created, err := r.SyncFeed(ctx, p.ID, feedMeta(), items); err != nil || len(created) != 0 {
+		t.Errorf("second sync created %d episodes (err %v)", len(created), err)
+	}
+	var again models.Podcast
+	if err := tx.First(&again, p.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !again.UpdatedAt.Equal(synced.UpdatedAt) {
+		t.Errorf("second sync moved updated_at from %v to %v", synced.UpdatedAt, again.UpdatedAt)
+	}
+	var events int64
+	if err := tx.Model(&models.OutboxEvent{}).Where("podcast_id = ?", p.ID).Count(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if events != 3 {
+		t.Errorf("second sync recorded %d events", events-3)
+	}
+}
+
+func TestClaimFeedSources(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	_, p := testPodcast(t, tx)
+	now := time.Now()
+	if err := tx.Model(&models.FeedSource{}).Where("next_run_at <= ?", now).Update("next_run_at", now.Add(time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+	src := models.FeedSource{PodcastID: p.ID, URL: "https://example.com/feed.xml", NextRunAt: now.Add(-time.Minute)}
+	if err := tx.Create(&src).Error; err != nil {
+		t.Fatal(err)
+	}
+	r := NewPodcastRepository(tx, nil)
+
+	claimed, err := r.ClaimFeedSources(ctx, now, time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 1 || claimed[0].PodcastID != p.ID || claimed[0].NextRunAt.Before(now) {
+		t.Fatalf("claimed %+v, want the feed leased", claimed)
+	}
+	if again, err := r.ClaimFeedSources(ctx, now, time.Minute, 10); err != nil || len(again) != 0 {
+		t.Fatalf("claimed %d leased feeds again (err %v)", len(again), err)
+	}
+
+	claimed[0].URL = "https://example.com/stale.xml"
+	claimed[0].ETag, claimed[0].NextRunAt = `"v2"`, now.Add(time.Hour)
+	if err := r.SaveFeedSource(ctx, &claimed[0]); err != nil {
+		t.Fatal(err)
+	}
+	var saved models.FeedSource
+	if err := tx.First(&saved, "podcast_id = ?", p.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if saved.ETag != `"v2"` || saved.URL != src.URL {
+		t.Errorf("saved etag %q, url %q; want the new etag and the original url", saved.ETag, saved.URL)
+	}
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/models"
+)
+
+// historyDedupWindow stops a player that saves its position from the start again,
+// e.g. after a reload, from adding another "started" entry.
+const historyDedupWindow = 6 * time.Hour
+
+// HistoryFilter narrows a history listing; zero values mean no restriction.
+type HistoryFilter struct {
+	PodcastID uint
+	From      time.Time // inclusive
+	To        time.Time // exclusive
+	Before    uint      // entry id cursor
+	Limit     int
+}
+
+// recordPlayback adds history entries for a stored position p, given the one stored
+// before it (nil if none): "started" when listening begins, from the start or again
+// after finishing, and "finished" when the episode gets completed. Positions saved
+// while listening add nothing. Buffered positions are compared when they are flushed,
+// so a save costs no extra lookup.
+func (r *PodcastRepository) recordPlayback(ctx context.Context, prev, p *models.PlaybackProgress) error {
+	listening := prev != nil && prev.Position > 0 && !prev.Completed
+	replayed := prev != nil && prev.Completed && p.Completed
+	if !listening && !replayed && (p.Position > 0 || p.Completed) {
+		if err := r.recordHistory(ctx, p.UserID, p.EpisodeID, models.HistoryStarted); err != nil {
+			return err
+		}
+	}
+	if p.Completed && (prev == nil || !prev.Completed) {
+		return r.recordHistory(ctx, p.UserID, p.EpisodeID, models.HistoryFinished)
+	}
+	return nil
+}
+
+func (r *PodcastRepository) recordHistory(ctx context.Context, userID, episodeID uint, event string) error {
+	var key string
+	if r.cacheEnable {
+		// a cheap check before the guarded insert; set once the entry is stored, so a
+		// failed insert is not skipped for the rest of the window
+		key = "history:" + event + ":" + strconv.FormatUint(uint64(userID), 10) + ":" + strconv.FormatUint(uint64(episodeID), 10)
+		if n, err := r.redis.Exists(ctx, key).Result(); err == nil && n > 0 {
+			return nil
+		}
+	}
+	if err := insertHistory(r.db.WithContext(ctx), userID, episodeID, event); err != nil {
+		return err
+	}
+	if key != "" {
+		_ = r.redis.Set(ctx, key, 1, historyDedupWindow).Err()
+	}
+	return nil
+}
+
+// insertHistory adds an entry unless the same one was added within historyDedupWindow;
+// the guard covers running without Redis and Redis restarts.
+func insertHistory(tx *gorm.DB, userID, episodeID uint, event string) error {
+	now := time.Now().UTC()
+	return tx.Exec(`
+		INSERT INTO history_entries (user_id, episode_id, podcast_id, event, created_at)
+		SELECT ?, e.id, e.podcast_id, ?, ? FROM episodes e
+		WHERE e.id = ? AND NOT EXISTS (
+			SELECT 1 FROM history_entries h
+			WHERE h.user_id = ? AND h.episode_id = e.id AND h.event = ? AND h.created_at > ?)`,
+		userID, event, now, episodeID, userID, event, now.Add(-historyDedupWindow)).Error
+}
+
+// History returns the user's entries newest first, with episodes and podcasts loaded.
+// Episodes and podcasts the user may no longer see are left out of their entries.
+func (r *UserContentRepository) History(ctx context.Context, userID uint, f HistoryFilter) ([]models.HistoryEntry, error) {
+	q := r.db.WithContext(ctx).Where("user_id = ?", userID)
+	if f.PodcastID != 0 {
+		q = q.Where("podcast_id = ?", f.PodcastID)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("created_at < ?", f.To)
+	}
+	if f.Before != 0 {
+		q = q.Where("id < ?", f.Before)
+	}
+	var entries []models.HistoryEntry
+	if err := q.Order("id desc").
+		Limit(f.Limit).
+		Preload("Episode", viewableEpisodes(userID)).
+		Preload("Podcast", viewablePodcasts(userID)).
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// DeleteHistoryEntry removes one of the user's entries and reports whether it existed.
+func (r *UserContentRepository) DeleteHistoryEntry(ctx context.Context, userID, entryID uint) (bool, error) {
+	var found bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ?", userID).Delete(&models.HistoryEntry{}, entryID)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		found = true
+		return recordEvent(tx, "history_deleted", 0, 0, userID, map[string]interface{}{"entryId": entryID})
+	})
+	return found, err
+}
+
+// ClearHistory removes all of the user's entries and returns how many were deleted.
+func (r *UserContentRepository) ClearHistory(ctx context.Context, userID uint) (int, error) {
+	var deleted int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("user_id = ?", userID).Delete(&models.HistoryEntry{})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		deleted = int(res.RowsAffected)
+		return recordEvent(tx, "history_cleared", 0, 0, userID, map[string]interface{}{"deleted": deleted})
+	})
+	return deleted, err
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+
+	"podcast-backend/internal/models"
+)
+
+func historyEvents(t *testing.T, r *UserContentRepository, userID uint) []string {
+	t.Helper()
+	entries, err := r.History(context.Background(), userID, HistoryFilter{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := make([]string, len(entries))
+	for i, e := range entries {
+		events[len(entries)-1-i] = e.Event // oldest first
+	}
+	return events
+}
+
+func TestRecordPlaybackTransitions(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	user, p := testPodcast(t, tx)
+	ep := models.Episode{PodcastID: p.ID, Title: "Pilot", Duration: 600, Status: models.EpisodePublished}
+	if err := tx.Create(&ep).Error; err != nil {
+		t.Fatal(err)
+	}
+	podcasts, content := NewPodcastRepository(tx, nil), NewUserContentRepository(tx)
+
+	var prev *models.PlaybackProgress
+	for _, pos := range []struct {
+		position  int
+		completed bool
+	}{{0, false}, {10, false}, {20, false}, {300, false}, {600, true}, {600, true}} {
+		p := &models.PlaybackProgress{UserID: user.ID, EpisodeID: ep.ID, Position: pos.position, Completed: pos.completed}
+		if err := podcasts.recordPlayback(ctx, prev, p); err != nil {
+			t.Fatal(err)
+		}
+		prev = p
+	}
+	got := historyEvents(t, content, user.ID)
+	if len(got) != 2 || got[0] != models.HistoryStarted || got[1] != models.HistoryFinished {
+		t.Fatalf("history = %v, want [started finished]", got)
+	}
+}
+
+// Without Redis positions are written directly and compared with the stored row.
+func TestSaveProgressRecordsHistory(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	user, p := testPodcast(t, tx)
+	ep := models.Episode{PodcastID: p.ID, Title: "Pilot", Duration: 600, Status: models.EpisodePublished}
+	if err := tx.Create(&ep).Error; err != nil {
+		t.Fatal(err)
+	}
+	podcasts, content := NewPodcastRepository(tx, nil), NewUserContentRepository(tx)
+
+	for _, position := range []int{0, 10, 300, 600, 600} {
+		if _, err := podcasts.SaveProgress(ctx, user.ID, ep.ID, position, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := historyEvents(t, content, user.ID)
+	if len(got) != 2 || got[0] != models.HistoryStarted || got[1] != models.HistoryFinished {
+		t.Fatalf("history = %v, want [started finished]", got)
+	}
+}
+
+// Buffered positions add history when they are flushed, at TEST_REDIS_ADDR.
+func TestFlushProgressRecordsHistory(t *testing.T) {
+	tx := testDB(t)
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR is not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+	ctx := context.Background()
+	user, p := testPodcast(t, tx)
+	ep := models.Episode{PodcastID: p.ID, Title: "Pilot", Duration: 600, Status: models.EpisodePublished}
+	if err := tx.Create(&ep).Error; err != nil {
+		t.Fatal(err)
+	}
+	podcasts, content := NewPodcastRepository(tx, client), NewUserContentRepository(tx)
+	t.Cleanup(func() {
+		client.Del(ctx, progressKey(user.ID), durationKey(ep.ID))
+		for _, event := range []string{models.HistoryStarted, models.HistoryFinished} {
+			client.Del(ctx, "history:"+event+":"+strconv.FormatUint(uint64(user.ID), 10)+":"+strconv.FormatUint(uint64(ep.ID), 10))
+		}
+	})
+
+	recorded := 0
+	for _, step := range []struct {
+		positions []int
+		want      []string
+	}{
+		{[]int{0, 10, 20}, []string{models.HistoryStarted}},
+		{[]int{300}, []string{models.HistoryStarted}},
+		{[]int{590}, []string{models.HistoryStarted, models.HistoryFinished}},
+	} {
+		for _, position := range step.positions {
+			if _, err := podcasts.SaveProgress(ctx, user.ID, ep.ID, position, false); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := historyEvents(t, content, user.ID); len(got) != recorded {
+			t.Fatalf("history = %v before the flush", got)
+		}
+		if _, err := podcasts.FlushProgress(ctx, 1000); err != nil {
+			t.Fatal(err)
+		}
+		got := historyEvents(t, content, user.ID)
+		if len(got) != len(step.want) || got[len(got)-1] != step.want[len(step.want)-1] {
+			t.Fatalf("after %v: history = %v, want %v", step.positions, got, step.want)
+		}
+		recorded = len(got)
+	}
+}
+
+func TestMarkPlayedRecordsFinished(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	user, p := testPodcast(t, tx)
+	ep := models.Episode{PodcastID: p.ID, Title: "Pilot", Duration: 600, Status: models.EpisodePublished}
+	if err := tx.Create(&ep).Error; err != nil {
+		t.Fatal(err)
+	}
+	r := NewUserContentRepository(tx)
+
+	for i := 0; i < 2; i++ {
+		found, err := r.MarkPlayed(ctx, user.ID, ep.ID)
+		if err != nil || !found {
+			t.Fatalf("MarkPlayed = %v, %v", found, err)
+		}
+	}
+	if got := historyEvents(t, r, user.ID); len(got) != 1 || got[0] != models.HistoryFinished {
+		t.Fatalf("history = %v, want [finished]", got)
+	}
+	if found, err := r.MarkPlayed(ctx, user.ID, ep.ID+1000); err != nil || found {
+		t.Errorf("unknown episode: found %v, err %v", found, err)
+	}
+}
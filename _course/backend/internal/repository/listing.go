@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/models"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+
+	// cacheGenKey is bumped on every catalogue change; list cache keys embed it so
+	// stale pages are never read again and simply expire.
+	cacheGenKey = "podcasts:gen"
+)
+
+// ErrInvalidCursor is returned for a malformed cursor or one issued for another sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrInvalidSort is returned for an unknown sort option.
+var ErrInvalidSort = errors.New("invalid sort")
+
+// sortSpec is an ORDER BY key; rows are always tie-broken by id in the same direction.
+type sortSpec struct {
+	expr    string
+	desc    bool
+	numeric bool
+}
+
+var podcastSorts = map[string]sortSpec{
+	"newest":    {expr: "podcasts.id", desc: true, numeric: true},
+	"title":     {expr: "LOWER(podcasts.title)"},
+	"likes":     {expr: "(SELECT COALESCE(SUM(e.likes), 0) FROM episodes e WHERE e.podcast_id = podcasts.id)", desc: true, numeric: true},
+	"favorites": {expr: "(SELECT COUNT(*) FROM favorites f WHERE f.podcast_id = podcasts.id)", desc: true, numeric: true},
+}
+
+var episodeSorts = map[string]sortSpec{
+	"newest": {expr: "episodes.id", desc: true, numeric: true},
+	"oldest": {expr: "episodes.id", numeric: true},
+	"title":  {expr: "LOWER(episodes.title)"},
+	"likes":  {expr: "episodes.likes", desc: true, numeric: true},
+}
+
+// PodcastQuery describes a page of the catalogue. Only public podcasts are listed
+// unless Owner is set, which an author gets when listing their own podcasts: it adds
+// drafts and unlisted ones, and unpublished episodes.
+type PodcastQuery struct {
+	Search          string `json:"q,omitempty"`
+	Category        string `json:"category,omitempty"` // slug
+	AuthorID        uint   `json:"authorId,omitempty"`
+	Author          string `json:"author,omitempty"`
+	Sort            string `json:"sort"`
+	Cursor          string `json:"cursor,omitempty"`
+	Limit           int    `json:"limit"`
+	IncludeEpisodes bool   `json:"include,omitempty"`
+	Owner           bool   `json:"owner,omitempty"`
+}
+
+// EpisodeQuery describes a page of one podcast's episodes.
+type EpisodeQuery struct {
+	Sort   string `json:"sort"`
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int    `json:"limit"`
+}
+
+// PodcastPage is one page of podcasts; NextCursor is empty on the last page.
+type PodcastPage struct {
+	Items      []models.Podcast `json:"items"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// EpisodePage is one page of episodes; NextCursor is empty on the last page.
+type EpisodePage struct {
+	Items      []models.Episode `json:"items"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+}
+
+// cursor is the sort key and id of the last row of a page.
+type cursor struct {
+	Sort string `json:"s"`
+	Key  string `json:"k"`
+	ID   uint   `json:"id"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s, sort string) (*cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if json.Unmarshal(b, &c) != nil || c.Sort != sort {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+type keyRow struct {
+	ID      uint
+	SortKey string
+}
+
+// keysetPage orders q by spec, continues after cur and returns up to limit ids with
+// the cursor for the next page.
+func keysetPage(q *gorm.DB, table string, sort string, spec sortSpec, cur *cursor, limit int) ([]uint, string, error) {
+	dir, cmp := "ASC", ">"
+	if spec.desc {
+		dir, cmp = "DESC", "<"
+	}
+	if cur != nil {
+		var key interface{} = cur.Key
+		if spec.numeric {
+			n, err := strconv.ParseInt(cur.Key, 10, 64)
+			if err != nil {
+				return nil, "", ErrInvalidCursor
+			}
+			key = n
+		}
+		q = q.Where(fmt.Sprintf("(%s %s ? OR (%s = ? AND %s.id %s ?))", spec.expr, cmp, spec.expr, table, cmp), key, key, cur.ID)
+	}
+	var rows []keyRow
+	if err := q.Select(fmt.Sprintf("%s.id AS id, %s AS sort_key", table, spec.expr)).
+		Order(fmt.Sprintf("%s %s, %s.id %s", spec.expr, dir, table, dir)).
+		Limit(limit + 1).
+		Scan(&rows).Error; err != nil {
+		return nil, "", err
+	}
+	next := ""
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		next = encodeCursor(cursor{Sort: sort, Key: last.SortKey, ID: last.ID})
+	}
+	ids := make([]uint, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	return ids, next, nil
+}
+
+func normalizePage(sort *string, limit *int, sorts map[string]sortSpec) (sortSpec, error) {
+	if *sort == "" {
+		*sort = "newest"
+	}
+	spec, ok := sorts[*sort]
+	if !ok {
+		return spec, ErrInvalidSort
+	}
+	if *limit <= 0 {
+		*limit = DefaultPageSize
+	}
+	if *limit > MaxPageSize {
+		*limit = MaxPageSize
+	}
+	return spec, nil
+}
+
+// List returns a page of podcasts. Episodes are only loaded with IncludeEpisodes.
+// Pages are cached under a key derived from the whole query.
+func (r *PodcastRepository) List(ctx context.Context, q PodcastQuery) (*PodcastPage, error) {
+	spec, err := normalizePage(&q.Sort, &q.Limit, podcastSorts)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := ""
+	if r.cacheEnable {
+		cacheKey = r.listCacheKey(ctx, "podcasts", q)
+		if data, err := r.redis.Get(ctx, cacheKey).Result(); err == nil {
+			var cached PodcastPage
+			if json.Unmarshal([]byte(data), &cached) == nil {
+				return &cached, nil
+			}
+		}
+	}
+
+	db := r.db.WithContext(ctx)
+	filtered := db.Table("podcasts")
+	if !q.Owner || q.AuthorID == 0 {
+		filtered = filtered.Scopes(publicPodcasts)
+	}
+	if q.Search != "" {
+		filtered = filtered.Where("podcasts.search_vector @@ "+tsQuery, q.Search, q.Search)
+	}
+	if q.Category != "" {
+		// a top-level category includes its subcategories
+		filtered = filtered.Where(`podcasts.category_id IN (
+			SELECT c.id FROM categories c LEFT JOIN categories parent ON parent.id = c.parent_id
+			WHERE c.slug = ? OR parent.slug = ?)`, q.Category, q.Category)
+	}
+	if q.AuthorID != 0 {
+		filtered = filtered.Where("podcasts.author_id = ?", q.AuthorID)
+	}
+	if q.Author != "" {
+		filtered = filtered.Where("LOWER(podcasts.author) = LOWER(?)", q.Author)
+	}
+	ids, next, err := keysetPage(filtered, "podcasts", q.Sort, spec, cur, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &PodcastPage{Items: []models.Podcast{}, NextCursor: next}
+	if len(ids) > 0 {
+		load := db.Where("id IN ?", ids)
+		if q.IncludeEpisodes {
+			load = load.Preload("Episodes", func(tx *gorm.DB) *gorm.DB {
+				if !q.Owner {
+					tx = tx.Scopes(publishedEpisodes)
+				}
+				return tx.Order("id desc")
+			})
+		}
+		var podcasts []models.Podcast
+		if err := load.Find(&podcasts).Error; err != nil {
+			return nil, err
+		}
+		page.Items = orderByIDs(podcasts, ids, func(p models.Podcast) uint { return p.ID })
+	}
+
+	if cacheKey != "" {
+		if b, err := json.Marshal(page); err == nil {
+			_ = r.redis.Set(ctx, cacheKey, b, r.cacheTTL).Err()
+		}
+	}
+	return page, nil
+}
+
+// ListEpisodes returns a page of a podcast's published episodes; its author (viewerID)
+// also gets drafts and scheduled ones. It returns nil, nil for an unknown podcast and
+// for someone else's draft.
+func (r *PodcastRepository) ListEpisodes(ctx context.Context, podcastID uint, viewerID uint, q EpisodeQuery) (*EpisodePage, error) {
+	var podcast models.Podcast
+	if err := r.db.WithContext(ctx).Select("id", "author_id", "visibility").Take(&podcast, podcastID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !visibleTo(&podcast, viewerID) {
+		return nil, nil
+	}
+	scopeKey := "podcast:" + strconv.FormatUint(uint64(podcastID), 10)
+	all := viewerID != 0 && viewerID == podcast.AuthorID
+	if all {
+		scopeKey += ":all"
+	}
+	return r.episodePage(ctx, scopeKey, func(db *gorm.DB) *gorm.DB {
+		db = db.Where("episodes.podcast_id = ?", podcastID)
+		if !all {
+			db = db.Scopes(publishedEpisodes)
+		}
+		return db
+	}, q)
+}
+
+// episodePage pages the episodes selected by scope; scopeKey identifies scope in cache keys.
+func (r *PodcastRepository) episodePage(ctx context.Context, scopeKey string, scope func(*gorm.DB) *gorm.DB, q EpisodeQuery) (*EpisodePage, error) {
+	spec, err := normalizePage(&q.Sort, &q.Limit, episodeSorts)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := decodeCursor(q.Cursor, q.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := ""
+	if r.cacheEnable {
+		cacheKey = r.listCacheKey(ctx, "episodes:"+scopeKey, q)
+		if data, err := r.redis.Get(ctx, cacheKey).Result(); err == nil {
+			var cached EpisodePage
+			if json.Unmarshal([]byte(data), &cached) == nil {
+				return &cached, nil
+			}
+		}
+	}
+
+	db := r.db.WithContext(ctx)
+	ids, next, err := keysetPage(scope(db.Table("episodes")), "episodes", q.Sort, spec, cur, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	page := &EpisodePage{Items: []models.Episode{}, NextCursor: next}
+	if len(ids) > 0 {
+		var episodes []models.Episode
+		if err := db.Preload("Tags").Where("id IN ?", ids).Find(&episodes).Error; err != nil {
+			return nil, err
+		}
+		page.Items = orderByIDs(episodes, ids, func(e models.Episode) uint { return e.ID })
+	}
+
+	if cacheKey != "" {
+		if b, err := json.Marshal(page); err == nil {
+			_ = r.redis.Set(ctx, cacheKey, b, r.cacheTTL).Err()
+		}
+	}
+	return page, nil
+}
+
+// listCacheKey is "<prefix>:<generation>:<hash of the query>".
+func (r *PodcastRepository) listCacheKey(ctx context.Context, prefix string, q interface{}) string {
+	gen, err := r.redis.Get(ctx, cacheGenKey).Result()
+	if err != nil {
+		gen = "0"
+	}
+	b, _ := json.Marshal(q)
+	sum := sha1.Sum(b)
+	return prefix + ":" + gen + ":" + hex.EncodeToString(sum[:])
+}
+
+// orderByIDs restores the order of ids after an IN query.
+func orderByIDs[T any](items []T, ids []uint, id func(T) uint) []T {
+	byID := make(map[uint]T, len(items))
+	for _, item := range items {
+		byID[id(item)] = item
+	}
+	out := make([]T, 0, len(ids))
+	for _, i := range ids {
+		if item, ok := byID[i]; ok {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,28 @@
+package repository
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+// Keys of the advisory locks taken by rebuild jobs, so that with several instances
+// each rebuild runs on one of them at a time.
+const (
+	chartsLockKey int64 = iota + 1
+	recommendationsLockKey
+)
+
+// exclusively runs fn in a transaction holding the advisory lock key. When another
+// session holds it, fn is skipped and exclusively returns false: that session is
+// doing the same work.
+func exclusively(ctx context.Context, db *gorm.DB, key int64, fn func(tx *gorm.DB) error) (bool, error) {
+	var locked bool
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Raw(`SELECT pg_try_advisory_xact_lock(?)`, key).Scan(&locked).Error; err != nil || !locked {
+			return err
+		}
+		return fn(tx)
+	})
+	return locked, err
+}
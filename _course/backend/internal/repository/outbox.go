@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/models"
+)
+
+// Changes to podcasts, episodes and a user's own content record an event in the
+// outbox within the transaction of the change, so an event exists exactly when the
+// change was committed; jobs.OutboxDispatcher delivers them. Play events, playback
+// positions and visits are high-volume signals rather than changes, and charts,
+// recommendations and search terms are derived by jobs; none of them record events.
+//
+// Events about drafts (unpublished episodes, draft podcasts) and about a user's
+// favorites, library, played marks and history are private to that user.
+
+// recordEvent stores an event in the transaction tx. A userID keeps it private.
+func recordEvent(tx *gorm.DB, evType string, podcastID, episodeID, userID uint, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxEvent{
+		Type:          evType,
+		PodcastID:     podcastID,
+		EpisodeID:     episodeID,
+		UserID:        userID,
+		Payload:       string(payload),
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// recordPodcastEvent stores an event about p; events about drafts go to the author only.
+func recordPodcastEvent(tx *gorm.DB, evType string, p *models.Podcast, data interface{}) error {
+	var userID uint
+	if p.Visibility == models.PodcastDraft {
+		userID = p.AuthorID
+	}
+	return recordEvent(tx, evType, p.ID, 0, userID, data)
+}
+
+// recordEpisodeEvent stores an event about ep of podcast p; unless listeners can
+// see the episode, the event goes to the author only.
+func recordEpisodeEvent(tx *gorm.DB, evType string, ep *models.Episode, p *models.Podcast, data interface{}) error {
+	var userID uint
+	if ep.Status != models.EpisodePublished || p.Visibility == models.PodcastDraft {
+		userID = p.AuthorID
+	}
+	return recordEvent(tx, evType, ep.PodcastID, ep.ID, userID, data)
+}
+
+// recordUnpublished tells listeners that ep, which they could see, is gone from
+// them. The event is public but carries only the id, not the hidden contents.
+func recordUnpublished(tx *gorm.DB, ep *models.Episode) error {
+	return recordEvent(tx, "episode_unpublished", ep.PodcastID, ep.ID, 0, map[string]interface{}{"episodeId": ep.ID})
+}
+
+// recordVisibilityChange is called when p stops or starts being a draft: its published
+// episodes vanish from listeners as episode_unpublished, or appear as episode_published.
+func recordVisibilityChange(tx *gorm.DB, p *models.Podcast) error {
+	var episodes []models.Episode
+	if err := tx.Scopes(publishedEpisodes).Where("podcast_id = ?", p.ID).Order("id").Find(&episodes).Error; err != nil {
+		return err
+	}
+	for i := range episodes {
+		ep := &episodes[i]
+		var err error
+		if p.Visibility == models.PodcastDraft {
+			err = recordUnpublished(tx, ep)
+		} else {
+			err = recordEpisodeEvent(tx, "episode_published", ep, p, ep)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Dispatch claims up to limit due events, oldest first, by putting them off by lease,
+// then hands each to deliver and records the outcome. Delivery happens outside any
+// transaction: with several instances no other one takes the claimed events while the
+// lease lasts, and the events of a crashed instance are delivered again once it runs
+// out. A failed event is retried after retry(attempts so far).
+// It returns how many events were handled.
+func (r *OutboxRepository) Dispatch(ctx context.Context, limit int, lease time.Duration, deliver func(*models.OutboxEvent) error, retry func(attempts int) time.Duration) (int, error) {
+	now := time.Now()
+	var ids []uint
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE outbox_events SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE dispatched_at IS NULL AND next_attempt_at <= ?
+			ORDER BY id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id`,
+		now.Add(lease), now, limit).Scan(&ids).Error
+	if err != nil || len(ids) == 0 {
+		return 0, err
+	}
+	var pending []models.OutboxEvent
+	if err := r.db.WithContext(ctx).Order("id").Find(&pending, ids).Error; err != nil {
+		return 0, err
+	}
+	for i := range pending {
+		ev := &pending[i]
+		updates := map[string]interface{}{"attempts": ev.Attempts + 1}
+		if err := deliver(ev); err != nil {
+			updates["last_error"] = err.Error()
+			updates["next_attempt_at"] = time.Now().Add(retry(ev.Attempts + 1))
+		} else {
+			updates["last_error"] = ""
+			updates["dispatched_at"] = time.Now()
+		}
+		if err := r.db.WithContext(ctx).Model(ev).Updates(updates).Error; err != nil {
+			return i, err
+		}
+	}
+	return len(pending), nil
+}
+
+// Prune deletes events dispatched before the given time and returns how many.
+func (r *OutboxRepository) Prune(ctx context.Context, before time.Time) (int, error) {
+	res := r.db.WithContext(ctx).Where("dispatched_at < ?", before).Delete(&models.OutboxEvent{})
+	return int(res.RowsAffected), res.Error
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"podcast-backend/internal/models"
+)
+
+func noRetry(int) time.Duration { return time.Hour }
+
+// Claimed events are leased: while one dispatcher delivers them another takes none,
+// and a failed event waits for its retry.
+func TestOutboxDispatchLease(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	_, p := testPodcast(t, tx)
+	if err := tx.Model(&models.OutboxEvent{}).Where("dispatched_at IS NULL").Update("dispatched_at", time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []string{"first", "second"} {
+		if err := recordEvent(tx, typ, p.ID, 0, 0, map[string]string{"type": typ}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := NewOutboxRepository(tx)
+
+	var delivered []string
+	n, err := r.Dispatch(ctx, 10, time.Minute, func(ev *models.OutboxEvent) error {
+		other, err := r.Dispatch(ctx, 10, time.Minute, func(*models.OutboxEvent) error { return nil }, noRetry)
+		if err != nil || other != 0 {
+			t.Errorf("a second dispatcher took %d leased events (err %v)", other, err)
+		}
+		delivered = append(delivered, ev.Type)
+		if ev.Type == "second" {
+			return errors.New("consumer down")
+		}
+		return nil
+	}, noRetry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 || len(delivered) != 2 || delivered[0] != "first" {
+		t.Fatalf("dispatched %d events: %v", n, delivered)
+	}
+
+	var events []models.OutboxEvent
+	if err := tx.Where("podcast_id = ?", p.ID).Order("id").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	first, second := events[len(events)-2], events[len(events)-1]
+	if first.DispatchedAt == nil || first.Attempts != 1 {
+		t.Errorf("first: dispatched %v after %d attempts", first.DispatchedAt, first.Attempts)
+	}
+	if second.DispatchedAt != nil || second.Attempts != 1 || second.LastError != "consumer down" || second.NextAttemptAt.Before(time.Now().Add(50*time.Minute)) {
+		t.Errorf("second: dispatched %v, attempts %d, error %q, next %v", second.DispatchedAt, second.Attempts, second.LastError, second.NextAttemptAt)
+	}
+}
+
+func TestPodcastDeleteRecordsEpisodes(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	user, p := testPodcast(t, tx)
+	now := time.Now()
+	eps := []models.Episode{
+		{PodcastID: p.ID, Title: "Published", Status: models.EpisodePublished, PublishAt: &now},
+		{PodcastID: p.ID, Title: "Draft", Status: models.EpisodeDraft},
+	}
+	if err := tx.Create(&eps).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewPodcastRepository(tx, nil).Delete(ctx, p.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	var events []models.OutboxEvent
+	if err := tx.Where("podcast_id = ? AND type = ?", p.ID, "episode_deleted").Order("episode_id").Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].EpisodeID != eps[0].ID || events[1].EpisodeID != eps[1].ID {
+		t.Fatalf("episode_deleted events = %+v", events)
+	}
+	// the draft episode was never public, nor is its deletion
+	if events[0].UserID != 0 || events[1].UserID != user.ID {
+		t.Errorf("event users = %d, %d; want 0, %d", events[0].UserID, events[1].UserID, user.ID)
+	}
+}
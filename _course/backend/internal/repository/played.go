@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"podcast-backend/internal/models"
+)
+
+// PodcastCounts summarises what a user has not heard yet in a podcast.
+type PodcastCounts struct {
+	PodcastID   uint `json:"-"`
+	Unplayed    int  `json:"unplayed"`
+	NewEpisodes int  `json:"newEpisodes"` // unplayed episodes published since the last visit
+}
+
+// Played state lives in playback_progresses.completed, so finishing an episode in the
+// player and marking it by hand are the same thing.
+
+// MarkPlayed marks an episode as played and reports whether the episode exists and the
+// user may see it. An episode not played before gets a "finished" history entry, as if
+// heard to the end.
+func (r *UserContentRepository) MarkPlayed(ctx context.Context, userID, episodeID uint) (bool, error) {
+	var found bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var played []bool
+		if err := tx.Model(&models.PlaybackProgress{}).
+			Where("user_id = ? AND episode_id = ?", userID, episodeID).
+			Pluck("completed", &played).Error; err != nil {
+			return err
+		}
+		visible := tx.Model(&models.Episode{}).Scopes(viewableEpisodes(userID)).
+			Where("episodes.id = ?", episodeID).Select("episodes.id, episodes.duration")
+		res := tx.Exec(`
+			INSERT INTO playback_progresses (user_id, episode_id, position, completed, updated_at)
+			SELECT ?, e.id, e.duration, true, ? FROM (?) e
+			ON CONFLICT (user_id, episode_id) DO UPDATE SET completed = true, updated_at = excluded.updated_at`,
+			userID, time.Now().UTC(), visible)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		found = true
+		if len(played) == 0 || !played[0] {
+			if err := insertHistory(tx, userID, episodeID, models.HistoryFinished); err != nil {
+				return err
+			}
+		}
+		return recordEvent(tx, "episode_played", 0, episodeID, userID, map[string]interface{}{"episodeId": episodeID, "played": true})
+	})
+	return found, err
+}
+
+// MarkPodcastPlayed marks every published episode of a podcast as played and returns how
+// many rows changed. Draft podcasts change nothing for anyone but their author.
+func (r *UserContentRepository) MarkPodcastPlayed(ctx context.Context, userID, podcastID uint) (int, error) {
+	var marked int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Exec(`
+			INSERT INTO playback_progresses (user_id, episode_id, position, completed, updated_at)
+			SELECT ?, id, duration, true, ? FROM episodes WHERE podcast_id = ? AND status = 'published'
+				AND podcast_id IN (SELECT id FROM podcasts WHERE visibility <> 'draft' OR author_id = ?)
+			ON CONFLICT (user_id, episode_id) DO UPDATE SET completed = true, updated_at = excluded.updated_at
+			WHERE playback_progresses.completed = false`,
+			userID, time.Now().UTC(), podcastID, userID)
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		marked = int(res.RowsAffected)
+		return recordEvent(tx, "podcast_played", podcastID, 0, userID, map[string]interface{}{"podcastId": podcastID, "marked": marked})
+	})
+	return marked, err
+}
+
+// MarkUnplayed clears the played mark and the saved position.
+func (r *UserContentRepository) MarkUnplayed(ctx context.Context, userID, episodeID uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.PlaybackProgress{}).
+			Where("user_id = ? AND episode_id = ?", userID, episodeID).
+			Updates(map[string]interface{}{"completed": false, "position": 0, "updated_at": time.Now().UTC()})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return recordEvent(tx, "episode_played", 0, episodeID, userID, map[string]interface{}{"episodeId": episodeID, "played": false})
+	})
+}
+
+// PlayedEpisodeIDs lists episodes the user has played, optionally within one podcast.
+func (r *UserContentRepository) PlayedEpisodeIDs(ctx context.Context, userID, podcastID uint) ([]uint, error) {
+	q := r.db.WithContext(ctx).Model(&models.PlaybackProgress{}).
+		Where("playback_progresses.user_id = ? AND playback_progresses.completed = ?", userID, true)
+	if podcastID != 0 {
+		q = q.Joins("JOIN episodes ON episodes.id = playback_progresses.episode_id").
+			Where("episodes.podcast_id = ?", podcastID)
+	}
+	var ids []uint
+	if err := q.Pluck("playback_progresses.episode_id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// RecordVisit remembers that the user opened a podcast now.
+func (r *UserContentRepository) RecordVisit(ctx context.Context, userID, podcastID uint) error {
+	visit := models.PodcastVisit{UserID: userID, PodcastID: podcastID, VisitedAt: time.Now().UTC()}
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "podcast_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"visited_at"}),
+	}).Create(&visit).Error
+}
+
+// PodcastCounts returns unplayed and new episode counts per podcast. Without a recorded
+// visit, "new" is measured from when the podcast was added to the library or favorites.
+// Episodes count from their publication, so one scheduled before the visit and published
+// after it is new.
+func (r *UserContentRepository) PodcastCounts(ctx context.Context, userID uint, podcastIDs []uint) (map[uint]PodcastCounts, error) {
+	out := make(map[uint]PodcastCounts, len(podcastIDs))
+	if len(podcastIDs) == 0 {
+		return out, nil
+	}
+	var rows []PodcastCounts
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT e.podcast_id AS podcast_id,
+			COUNT(*) FILTER (WHERE pp.completed IS NOT TRUE) AS unplayed,
+			COUNT(*) FILTER (WHERE pp.completed IS NOT TRUE
+				AND COALESCE(e.publish_at, e.created_at) > COALESCE(v.visited_at, li.created_at, f.created_at)) AS new_episodes
+		FROM episodes e
+		LEFT JOIN playback_progresses pp ON pp.episode_id = e.id AND pp.user_id = ?
+		LEFT JOIN podcast_visits v ON v.podcast_id = e.podcast_id AND v.user_id = ?
+		LEFT JOIN library_items li ON li.podcast_id = e.podcast_id AND li.user_id = ?
+		LEFT JOIN favorites f ON f.podcast_id = e.podcast_id AND f.user_id = ?
+		WHERE e.podcast_id IN ? AND e.status = 'published'
+		GROUP BY e.podcast_id`,
+		userID, userID, userID, userID, podcastIDs).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		out[row.PodcastID] = row
+	}
+	return out, nil
+}
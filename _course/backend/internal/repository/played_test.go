@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"podcast-backend/internal/models"
+)
+
+// An episode created before the last visit but published after it is new.
+func TestPodcastCountsNewSincePublication(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	user, p := testPodcast(t, tx)
+	now := time.Now().UTC()
+	visit := now.Add(-time.Hour)
+	before, after := visit.Add(-time.Hour), visit.Add(30*time.Minute)
+	for _, ep := range []models.Episode{
+		{PodcastID: p.ID, Title: "Old", Status: models.EpisodePublished, PublishAt: &before, CreatedAt: before},
+		{PodcastID: p.ID, Title: "Scheduled early", Status: models.EpisodePublished, PublishAt: &after, CreatedAt: before},
+		{PodcastID: p.ID, Title: "Imported", Status: models.EpisodePublished, CreatedAt: after},
+	} {
+		if err := tx.Create(&ep).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Create(&models.PodcastVisit{UserID: user.ID, PodcastID: p.ID, VisitedAt: visit}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	counts, err := NewUserContentRepository(tx).PodcastCounts(ctx, user.ID, []uint{p.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := counts[p.ID]; got.Unplayed != 3 || got.NewEpisodes != 2 {
+		t.Errorf("counts = %+v, want 3 unplayed, 2 new", got)
+	}
+}
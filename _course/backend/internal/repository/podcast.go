@@ -2,12 +2,13 @@ package repository
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"podcast-backend/internal/models"
 )
@@ -28,56 +29,44 @@ func NewPodcastRepository(db *gorm.DB, redisClient *redis.Client) *PodcastReposi
 	}
 }
 
-func (r *PodcastRepository) List(ctx context.Context) ([]models.Podcast, error) {
-	const cacheKey = "podcasts:all"
-
-	if r.cacheEnable {
-		if data, err := r.redis.Get(ctx, cacheKey).Result(); err == nil {
-			var cached []models.Podcast
-			if json.Unmarshal([]byte(data), &cached) == nil {
-				return cached, nil
-			}
-		}
-	}
-
-	var podcasts []models.Podcast
-	if err := r.db.Preload("Episodes").Order("id desc").Find(&podcasts).Error; err != nil {
-		return nil, err
-	}
-
-	if r.cacheEnable {
-		if b, err := json.Marshal(podcasts); err == nil {
-			_ = r.redis.Set(ctx, cacheKey, b, r.cacheTTL).Err()
-		}
-	}
-
-	return podcasts, nil
-}
-
-func (r *PodcastRepository) Get(ctx context.Context, id uint) (*models.Podcast, error) {
+// Get loads a podcast with its published episodes; its author (viewerID) also gets
+// drafts and scheduled ones. Pass 0 for anonymous viewers. Draft podcasts are
+// nil, nil for everyone but their author.
+func (r *PodcastRepository) Get(ctx context.Context, id uint, viewerID uint) (*models.Podcast, error) {
 	var podcast models.Podcast
-	if err := r.db.Preload("Episodes").First(&podcast, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&podcast, id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &podcast, nil
-}
-
-func (r *PodcastRepository) Search(ctx context.Context, query string) ([]models.Podcast, error) {
-	q := "%" + query + "%"
-	var podcasts []models.Podcast
-	if err := r.db.Preload("Episodes").
-		Where("title ILIKE ? OR author ILIKE ? OR description ILIKE ?", q, q, q).
-		Find(&podcasts).Error; err != nil {
+	if !visibleTo(&podcast, viewerID) {
+		return nil, nil
+	}
+	q := r.db.WithContext(ctx).Preload("Tags").Where("podcast_id = ?", id)
+	if viewerID == 0 || viewerID != podcast.AuthorID {
+		q = q.Scopes(publishedEpisodes)
+	}
+	if err := q.Order("id").Find(&podcast.Episodes).Error; err != nil {
 		return nil, err
 	}
-	return podcasts, nil
+	return &podcast, nil
 }
 
 func (r *PodcastRepository) Create(ctx context.Context, p *models.Podcast) error {
-	if err := r.db.WithContext(ctx).Create(p).Error; err != nil {
+	if err := applyVisibility(p); err != nil {
+		return err
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := assignCategory(tx, p); err != nil {
+			return err
+		}
+		if err := tx.Create(p).Error; err != nil {
+			return err
+		}
+		return recordPodcastEvent(tx, "podcast_created", p, p)
+	})
+	if err != nil {
 		return err
 	}
 	r.invalidateCache(ctx)
@@ -102,19 +91,67 @@ func (r *PodcastRepository) Update(ctx context.Context, id uint, data *models.Po
 	existing.Description = data.Description
 	existing.Image = data.Image
 	existing.Category = data.Category
+	if data.Language != "" {
+		existing.Language = data.Language
+	}
+	wasDraft := existing.Visibility == models.PodcastDraft
+	prevVisibility := existing.Visibility
+	if data.Visibility != "" {
+		existing.Visibility = data.Visibility
+		if err := applyVisibility(&existing); err != nil {
+			return nil, err
+		}
+	}
 
-	if err := r.db.WithContext(ctx).Save(&existing).Error; err != nil {
-		return nil, err
+	now := time.Now()
+	for i := range data.Episodes {
+		if err := applySchedule(&data.Episodes[i], now); err != nil {
+			return nil, fmt.Errorf("episode %d: %w", i, err)
+		}
 	}
 
-	if data.Episodes != nil && len(data.Episodes) > 0 {
-		for _, ep := range data.Episodes {
-			ep.PodcastID = existing.ID
-			r.db.WithContext(ctx).Create(&ep)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := assignCategory(tx, &existing); err != nil {
+			return err
+		}
+		if err := tx.Save(&existing).Error; err != nil {
+			return err
+		}
+		if err := recordPodcastEvent(tx, "podcast_updated", &existing, &existing); err != nil {
+			return err
+		}
+		if wasDraft != (existing.Visibility == models.PodcastDraft) {
+			if err := recordVisibilityChange(tx, &existing); err != nil {
+				return err
+			}
+		}
+
+		if data.Episodes != nil && len(data.Episodes) > 0 {
+			for _, ep := range data.Episodes {
+				ep.PodcastID = existing.ID
+				if err := tx.Create(&ep).Error; err != nil {
+					return err
+				}
+				if err := recordEpisodeEvent(tx, "episode_created", &ep, &existing, &ep); err != nil {
+					return err
+				}
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	r.invalidateCache(ctx)
+	if r.cacheEnable && existing.Visibility != prevVisibility && existing.Visibility != models.PodcastPublic {
+		// listeners may no longer count plays of its episodes, nor save positions in
+		// them once it is a draft; the cache TTLs bound the delay should this lookup fail
+		var ids []uint
+		if err := r.db.WithContext(ctx).Model(&models.Episode{}).Where("podcast_id = ?", existing.ID).Pluck("id", &ids).Error; err == nil {
+			forgetEpisodeCache(ctx, r.redis, ids...)
+		}
+	}
 
 	return &existing, nil
 }
@@ -127,7 +164,24 @@ func (r *PodcastRepository) Delete(ctx context.Context, id uint, userID uint) er
 	if podcast.AuthorID != userID {
 		return errors.New("forbidden")
 	}
-	if err := r.db.WithContext(ctx).Delete(&models.Podcast{}, id).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the episodes go with the podcast: consumers following episodes hear of each
+		var episodes []models.Episode
+		if err := tx.Where("podcast_id = ?", id).Order("id").Find(&episodes).Error; err != nil {
+			return err
+		}
+		for i := range episodes {
+			ep := &episodes[i]
+			if err := recordEpisodeEvent(tx, "episode_deleted", ep, &podcast, map[string]interface{}{"episodeId": ep.ID}); err != nil {
+				return err
+			}
+		}
+		if err := tx.Delete(&models.Podcast{}, id).Error; err != nil {
+			return err
+		}
+		return recordPodcastEvent(tx, "podcast_deleted", &podcast, map[string]interface{}{"podcastId": id})
+	})
+	if err != nil {
 		return err
 	}
 	r.invalidateCache(ctx)
@@ -146,17 +200,28 @@ func (r *PodcastRepository) AddEpisode(ctx context.Context, podcastID uint, ep *
 		return nil, errors.New("forbidden")
 	}
 
+	if err := applySchedule(ep, time.Now()); err != nil {
+		return nil, err
+	}
 	ep.PodcastID = podcastID
-	if err := r.db.WithContext(ctx).Create(ep).Error; err != nil {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ep).Error; err != nil {
+			return err
+		}
+		return recordEpisodeEvent(tx, "episode_created", ep, &podcast, ep)
+	})
+	if err != nil {
 		return nil, err
 	}
 	r.invalidateCache(ctx)
 	return ep, nil
 }
 
+// Episodes returns the published episodes of a podcast, newest first.
 func (r *PodcastRepository) Episodes(ctx context.Context, podcastID uint) ([]models.Episode, error) {
 	var episodes []models.Episode
 	if err := r.db.WithContext(ctx).
+		Scopes(publishedEpisodes).
 		Where("podcast_id = ?", podcastID).
 		Order("id desc").
 		Find(&episodes).Error; err != nil {
@@ -169,6 +234,135 @@ func (r *PodcastRepository) invalidateCache(ctx context.Context) {
 	if !r.cacheEnable {
 		return
 	}
-	_ = r.redis.Del(ctx, "podcasts:all").Err()
+	bumpCacheGen(ctx, r.redis)
+}
+
+// bumpCacheGen drops every cached podcast list by moving them to a new generation.
+// Episodes are part of the lists, so episode changes call it too.
+func bumpCacheGen(ctx context.Context, client *redis.Client) {
+	if client == nil {
+		return
+	}
+	_ = client.Incr(ctx, cacheGenKey).Err()
+}
+
+// FeedState is a cheap fingerprint of a podcast used for feed validators.
+type FeedState struct {
+	UpdatedAt time.Time
+	Episodes  int64
+}
+
+// FeedState returns the latest modification time across the podcast and its episodes
+// without loading them, so conditional feed requests can be answered with a single query.
+// Draft podcasts have no feed and get nil, nil.
+func (r *PodcastRepository) FeedState(ctx context.Context, podcastID uint) (*FeedState, error) {
+	var row struct {
+		PodcastUpdated time.Time
+		EpisodeUpdated *time.Time
+		Episodes       int64
+	}
+	err := r.db.WithContext(ctx).
+		Table("podcasts").
+		Select("podcasts.updated_at AS podcast_updated, MAX(episodes.updated_at) AS episode_updated, COUNT(episodes.id) AS episodes").
+		Joins("LEFT JOIN episodes ON episodes.podcast_id = podcasts.id AND episodes.status = ?", models.EpisodePublished).
+		Where("podcasts.id = ? AND podcasts.visibility <> ?", podcastID, models.PodcastDraft).
+		Group("podcasts.id").
+		Take(&row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	state := &FeedState{UpdatedAt: row.PodcastUpdated, Episodes: row.Episodes}
+	if row.EpisodeUpdated != nil && row.EpisodeUpdated.After(state.UpdatedAt) {
+		state.UpdatedAt = *row.EpisodeUpdated
+	}
+	return state, nil
 }
 
+// ImportResult describes the outcome of importing an external feed.
+type ImportResult struct {
+	Podcast  *models.Podcast `json:"podcast"`
+	Created  bool            `json:"created"`
+	Imported int             `json:"imported"`
+	Skipped  int             `json:"skipped"`
+}
+
+// Import creates (or refreshes) a podcast owned by p.AuthorID from an external feed and
+// bulk-inserts its episodes. Episodes are de-duplicated by guid, so re-running is idempotent.
+func (r *PodcastRepository) Import(ctx context.Context, p *models.Podcast, episodes []models.Episode) (*ImportResult, error) {
+	res := &ImportResult{}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.Podcast
+		q := tx.Where("author_id = ?", p.AuthorID)
+		if p.FeedURL != nil {
+			q = q.Where("feed_url = ?", *p.FeedURL)
+		} else {
+			q = q.Where("feed_url IS NULL AND title = ?", p.Title)
+		}
+		err := q.First(&existing).Error
+		switch {
+		case err == nil:
+			existing.Title = p.Title
+			existing.Author = p.Author
+			existing.Description = p.Description
+			existing.Image = p.Image
+			existing.Category = p.Category
+			if p.Language != "" {
+				existing.Language = p.Language
+			}
+			if err := assignCategory(tx, &existing); err != nil {
+				return err
+			}
+			if err := tx.Save(&existing).Error; err != nil {
+				return err
+			}
+			res.Podcast = &existing
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			p.Episodes = nil
+			if err := assignCategory(tx, p); err != nil {
+				return err
+			}
+			if err := tx.Create(p).Error; err != nil {
+				return err
+			}
+			res.Podcast = p
+			res.Created = true
+		default:
+			return err
+		}
+
+		if p.FeedURL != nil {
+			src := models.FeedSource{PodcastID: res.Podcast.ID, URL: *p.FeedURL, NextRunAt: time.Now()}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "podcast_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"url", "updated_at"}),
+			}).Create(&src).Error; err != nil {
+				return err
+			}
+		}
+
+		if len(episodes) > 0 {
+			for i := range episodes {
+				episodes[i].PodcastID = res.Podcast.ID
+			}
+			result := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "podcast_id"}, {Name: "guid"}},
+				DoNothing: true,
+			}).CreateInBatches(&episodes, 100)
+			if result.Error != nil {
+				return result.Error
+			}
+			res.Imported = int(result.RowsAffected)
+			res.Skipped = len(episodes) - res.Imported
+		}
+		// one event for the whole feed rather than one per back-catalogue episode
+		return recordPodcastEvent(tx, "podcast_imported", res.Podcast, res)
+	})
+	if err != nil {
+		return nil, err
+	}
+	r.invalidateCache(ctx)
+	return res, nil
+}
@@ -0,0 +1,407 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"podcast-backend/internal/models"
+)
+
+const (
+	// progressDirtyKey is the set of users whose buffered positions are not yet in Postgres.
+	progressDirtyKey = "progress:dirty"
+	durationCacheTTL = 5 * time.Minute
+	// maxCompletionMargin is how close to the end a position marks the episode as completed.
+	maxCompletionMargin = 30
+)
+
+func progressKey(userID uint) string {
+	return "progress:user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// ContinueItem is an episode the user started and has not finished.
+type ContinueItem struct {
+	Episode   models.Episode `json:"episode"`
+	Podcast   models.Podcast `json:"podcast"`
+	Position  int            `json:"position"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+}
+
+// SaveProgress records a playback position. With Redis, writes are buffered per user and
+// persisted in batches by FlushProgress; without it they go straight to Postgres. Either
+// way the position is compared with the stored one on its way to Postgres to record
+// history. It returns nil when the episode does not exist or userID may not see it.
+func (r *PodcastRepository) SaveProgress(ctx context.Context, userID, episodeID uint, position int, completed bool) (*models.PlaybackProgress, error) {
+	duration, ok, err := r.episodeDuration(ctx, userID, episodeID)
+	if err != nil || !ok {
+		return nil, err
+	}
+	if position < 0 {
+		position = 0
+	}
+	if duration > 0 {
+		if position > duration {
+			position = duration
+		}
+		margin := duration / 20
+		if margin > maxCompletionMargin {
+			margin = maxCompletionMargin
+		}
+		if position >= duration-margin {
+			completed = true
+		}
+	}
+	p := &models.PlaybackProgress{
+		UserID:    userID,
+		EpisodeID: episodeID,
+		Position:  position,
+		Completed: completed,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if r.cacheEnable {
+		if err := r.bufferProgress(ctx, []models.PlaybackProgress{*p}, false); err == nil {
+			return p, nil
+		}
+		// Redis unavailable: fall back to a direct write
+	}
+	var prev *models.PlaybackProgress
+	var stored models.PlaybackProgress
+	if err := r.db.WithContext(ctx).First(&stored, "user_id = ? AND episode_id = ?", userID, episodeID).Error; err == nil {
+		prev = &stored
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err := upsertProgress(r.db.WithContext(ctx), []models.PlaybackProgress{*p}); err != nil {
+		return nil, err
+	}
+	return p, r.recordPlayback(ctx, prev, p)
+}
+
+// Progress returns the latest known position of a user in an episode, or nil.
+// A buffered position wins unless the row was changed later, e.g. marked played.
+func (r *PodcastRepository) Progress(ctx context.Context, userID, episodeID uint) (*models.PlaybackProgress, error) {
+	var buffered *models.PlaybackProgress
+	if r.cacheEnable {
+		field := strconv.FormatUint(uint64(episodeID), 10)
+		if data, err := r.redis.HGet(ctx, progressKey(userID), field).Result(); err == nil {
+			var p models.PlaybackProgress
+			if json.Unmarshal([]byte(data), &p) == nil {
+				buffered = &p
+			}
+		}
+	}
+	var p models.PlaybackProgress
+	if err := r.db.WithContext(ctx).First(&p, "user_id = ? AND episode_id = ?", userID, episodeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return buffered, nil
+		}
+		return nil, err
+	}
+	if buffered != nil && !buffered.UpdatedAt.Before(p.UpdatedAt) {
+		return buffered, nil
+	}
+	return &p, nil
+}
+
+// ContinueListening returns started, unfinished episodes with their podcasts, most recently
+// played first. Episodes and podcasts the user may no longer see are left out.
+func (r *PodcastRepository) ContinueListening(ctx context.Context, userID uint, limit int) ([]ContinueItem, error) {
+	pending := r.pendingProgress(ctx, userID)
+
+	var rows []models.PlaybackProgress
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND completed = ? AND position > 0", userID, false).
+		Order("updated_at desc").
+		Limit(limit + len(pending)).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	// rows of buffered episodes are needed whatever their state: a row marked played
+	// after the position was buffered must win over it
+	if len(pending) > 0 {
+		ids := make([]uint, 0, len(pending))
+		for id := range pending {
+			ids = append(ids, id)
+		}
+		var extra []models.PlaybackProgress
+		if err := r.db.WithContext(ctx).Where("user_id = ? AND episode_id IN ?", userID, ids).Find(&extra).Error; err != nil {
+			return nil, err
+		}
+		rows = append(rows, extra...)
+	}
+	latest := make(map[uint]models.PlaybackProgress, len(rows)+len(pending))
+	for _, p := range rows {
+		latest[p.EpisodeID] = p
+	}
+	for id, p := range pending {
+		if cur, ok := latest[id]; !ok || !p.UpdatedAt.Before(cur.UpdatedAt) {
+			latest[id] = p
+		}
+	}
+	started := make([]models.PlaybackProgress, 0, len(latest))
+	for _, p := range latest {
+		if !p.Completed && p.Position > 0 {
+			started = append(started, p)
+		}
+	}
+	sort.Slice(started, func(i, j int) bool { return started[i].UpdatedAt.After(started[j].UpdatedAt) })
+	if len(started) > limit {
+		started = started[:limit]
+	}
+	if len(started) == 0 {
+		return []ContinueItem{}, nil
+	}
+
+	episodeIDs := make([]uint, len(started))
+	for i, p := range started {
+		episodeIDs[i] = p.EpisodeID
+	}
+	var episodes []models.Episode
+	if err := r.db.WithContext(ctx).Scopes(viewableEpisodes(userID)).Where("id IN ?", episodeIDs).Find(&episodes).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Episode, len(episodes))
+	podcastIDs := make([]uint, 0, len(episodes))
+	for _, ep := range episodes {
+		byID[ep.ID] = ep
+		podcastIDs = append(podcastIDs, ep.PodcastID)
+	}
+	var podcasts []models.Podcast
+	if err := r.db.WithContext(ctx).Scopes(viewablePodcasts(userID)).Where("id IN ?", podcastIDs).Find(&podcasts).Error; err != nil {
+		return nil, err
+	}
+	podcastByID := make(map[uint]models.Podcast, len(podcasts))
+	for _, p := range podcasts {
+		podcastByID[p.ID] = p
+	}
+
+	items := make([]ContinueItem, 0, len(started))
+	for _, p := range started {
+		ep, ok := byID[p.EpisodeID]
+		if !ok {
+			continue // deleted or hidden while its position was buffered
+		}
+		podcast, ok := podcastByID[ep.PodcastID]
+		if !ok {
+			continue
+		}
+		items = append(items, ContinueItem{
+			Episode:   ep,
+			Podcast:   podcast,
+			Position:  p.Position,
+			UpdatedAt: p.UpdatedAt,
+		})
+	}
+	return items, nil
+}
+
+// FlushProgress moves buffered positions of up to limit users from Redis to Postgres
+// and returns how many rows were written. It then records the history those positions
+// make against the rows they replaced; an error doing so leaves the positions written.
+func (r *PodcastRepository) FlushProgress(ctx context.Context, limit int) (int, error) {
+	if !r.cacheEnable {
+		return 0, nil
+	}
+	users, err := r.redis.SPopN(ctx, progressDirtyKey, int64(limit)).Result()
+	if err != nil || len(users) == 0 {
+		return 0, err
+	}
+
+	var items []models.PlaybackProgress
+	for i, u := range users {
+		userID, err := strconv.ParseUint(u, 10, 64)
+		if err != nil {
+			continue
+		}
+		key := progressKey(uint(userID))
+		var all *redis.MapStringStringCmd
+		// read and clear in one transaction so positions saved meanwhile are not lost
+		if _, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			all = pipe.HGetAll(ctx, key)
+			pipe.Del(ctx, key)
+			return nil
+		}); err != nil {
+			r.redis.SAdd(ctx, progressDirtyKey, users[i:])
+			_ = r.bufferProgress(ctx, items, true)
+			return 0, err
+		}
+		for _, data := range all.Val() {
+			var p models.PlaybackProgress
+			if json.Unmarshal([]byte(data), &p) == nil {
+				items = append(items, p)
+			}
+		}
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	// episodes deleted after the position was buffered would violate the foreign key
+	ids := make([]uint, 0, len(items))
+	userIDs := make([]uint, 0, len(users))
+	for _, p := range items {
+		ids = append(ids, p.EpisodeID)
+		userIDs = append(userIDs, p.UserID)
+	}
+	var existing []uint
+	if err := r.db.WithContext(ctx).Model(&models.Episode{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		_ = r.bufferProgress(ctx, items, true)
+		return 0, err
+	}
+	known := make(map[uint]bool, len(existing))
+	for _, id := range existing {
+		known[id] = true
+	}
+	valid := items[:0]
+	for _, p := range items {
+		if known[p.EpisodeID] {
+			valid = append(valid, p)
+		}
+	}
+	if len(valid) == 0 {
+		return 0, nil
+	}
+	var stored []models.PlaybackProgress
+	if err := r.db.WithContext(ctx).Where("user_id IN ? AND episode_id IN ?", userIDs, ids).Find(&stored).Error; err != nil {
+		_ = r.bufferProgress(ctx, valid, true)
+		return 0, err
+	}
+	if err := upsertProgress(r.db.WithContext(ctx), valid); err != nil {
+		_ = r.bufferProgress(ctx, valid, true)
+		return 0, err
+	}
+
+	type position struct{ userID, episodeID uint }
+	before := make(map[position]*models.PlaybackProgress, len(stored))
+	for i := range stored {
+		before[position{stored[i].UserID, stored[i].EpisodeID}] = &stored[i]
+	}
+	var historyErr error
+	for i := range valid {
+		p := &valid[i]
+		prev := before[position{p.UserID, p.EpisodeID}]
+		if prev != nil && p.UpdatedAt.Before(prev.UpdatedAt) {
+			continue // not written: the row changed later, e.g. marked played
+		}
+		if err := r.recordPlayback(ctx, prev, p); err != nil && historyErr == nil {
+			historyErr = fmt.Errorf("record history: %w", err)
+		}
+	}
+	return len(valid), historyErr
+}
+
+// bufferProgress stores positions in the per-user Redis hashes and marks the users dirty.
+// With keepNewer set, positions that were buffered again in the meantime are not overwritten.
+func (r *PodcastRepository) bufferProgress(ctx context.Context, items []models.PlaybackProgress, keepNewer bool) error {
+	if len(items) == 0 {
+		return nil
+	}
+	_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, p := range items {
+			b, err := json.Marshal(p)
+			if err != nil {
+				return err
+			}
+			field := strconv.FormatUint(uint64(p.EpisodeID), 10)
+			if keepNewer {
+				pipe.HSetNX(ctx, progressKey(p.UserID), field, b)
+			} else {
+				pipe.HSet(ctx, progressKey(p.UserID), field, b)
+			}
+			pipe.SAdd(ctx, progressDirtyKey, p.UserID)
+		}
+		return nil
+	})
+	return err
+}
+
+// pendingProgress returns a user's buffered positions keyed by episode id.
+func (r *PodcastRepository) pendingProgress(ctx context.Context, userID uint) map[uint]models.PlaybackProgress {
+	out := map[uint]models.PlaybackProgress{}
+	if !r.cacheEnable {
+		return out
+	}
+	all, err := r.redis.HGetAll(ctx, progressKey(userID)).Result()
+	if err != nil {
+		return out
+	}
+	for _, data := range all {
+		var p models.PlaybackProgress
+		if json.Unmarshal([]byte(data), &p) == nil {
+			out[p.EpisodeID] = p
+		}
+	}
+	return out
+}
+
+// episodeDuration looks up the duration of an episode userID may see, cached in Redis
+// so progress updates don't hit Postgres. Only episodes everyone may see are cached;
+// forgetEpisodeCache drops them when that changes. ok is false when the episode does
+// not exist or is hidden from the user.
+func (r *PodcastRepository) episodeDuration(ctx context.Context, userID, episodeID uint) (int, bool, error) {
+	key := durationKey(episodeID)
+	if r.cacheEnable {
+		if d, err := r.redis.Get(ctx, key).Int(); err == nil {
+			return d, true, nil
+		}
+	}
+	var rows []struct {
+		Duration int
+		Public   bool
+	}
+	if err := r.db.WithContext(ctx).Model(&models.Episode{}).
+		Scopes(viewableEpisodes(userID)).
+		Joins("JOIN podcasts ON podcasts.id = episodes.podcast_id").
+		Where("episodes.id = ?", episodeID).
+		Select("episodes.duration, episodes.status = ? AND podcasts.visibility <> ? AS public", models.EpisodePublished, models.PodcastDraft).
+		Limit(1).
+		Scan(&rows).Error; err != nil {
+		return 0, false, err
+	}
+	if len(rows) == 0 {
+		return 0, false, nil
+	}
+	if r.cacheEnable && rows[0].Public {
+		_ = r.redis.Set(ctx, key, rows[0].Duration, durationCacheTTL).Err()
+	}
+	return rows[0].Duration, true, nil
+}
+
+func durationKey(episodeID uint) string {
+	return "episode:duration:" + strconv.FormatUint(uint64(episodeID), 10)
+}
+
+// forgetEpisodeCache drops what is cached about episodes that were changed, hidden or
+// deleted: their durations and the podcasts play events are counted for.
+func forgetEpisodeCache(ctx context.Context, client *redis.Client, episodeIDs ...uint) {
+	if client == nil || len(episodeIDs) == 0 {
+		return
+	}
+	keys := make([]string, 0, 2*len(episodeIDs))
+	for _, id := range episodeIDs {
+		keys = append(keys, durationKey(id), episodePodcastKey(id))
+	}
+	_ = client.Del(ctx, keys...).Err()
+}
+
+// upsertProgress writes positions, never replacing a newer row with an older one.
+func upsertProgress(db *gorm.DB, items []models.PlaybackProgress) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "episode_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position", "completed", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Expr{SQL: "playback_progresses.updated_at <= excluded.updated_at"},
+		}},
+	}).Omit(clause.Associations).Create(&items).Error
+}
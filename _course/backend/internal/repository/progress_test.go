@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"podcast-backend/internal/models"
+)
+
+// Listeners can neither save positions in nor mark played episodes hidden from them,
+// and lose sight of ones hidden after they listened.
+func TestProgressHiddenEpisodes(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	author, p := testPodcast(t, tx)
+	listener := &models.User{Name: "Listener", Email: "listener-" + t.Name() + "@example.com", PasswordHash: "x"}
+	if err := tx.Create(listener).Error; err != nil {
+		t.Fatal(err)
+	}
+	draft := models.Episode{PodcastID: p.ID, Title: "Draft", Duration: 600, Status: models.EpisodeDraft}
+	started := models.Episode{PodcastID: p.ID, Title: "Started", Duration: 600, Status: models.EpisodePublished}
+	heard := models.Episode{PodcastID: p.ID, Title: "Heard", Duration: 600, Status: models.EpisodePublished}
+	for _, ep := range []*models.Episode{&draft, &started, &heard} {
+		if err := tx.Create(ep).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	podcasts, content := NewPodcastRepository(tx, nil), NewUserContentRepository(tx)
+
+	if saved, err := podcasts.SaveProgress(ctx, listener.ID, draft.ID, 10, false); err != nil || saved != nil {
+		t.Errorf("listener saved progress in a draft: %+v, %v", saved, err)
+	}
+	if found, err := content.MarkPlayed(ctx, listener.ID, draft.ID); err != nil || found {
+		t.Errorf("listener marked a draft played: %v, %v", found, err)
+	}
+	if saved, err := podcasts.SaveProgress(ctx, author.ID, draft.ID, 10, false); err != nil || saved == nil {
+		t.Errorf("author could not save progress in a draft: %v", err)
+	}
+
+	if _, err := podcasts.SaveProgress(ctx, listener.ID, started.ID, 10, false); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := content.MarkPlayed(ctx, listener.ID, heard.ID); err != nil || !found {
+		t.Fatalf("MarkPlayed = %v, %v", found, err)
+	}
+	if items, err := podcasts.ContinueListening(ctx, listener.ID, 10); err != nil || len(items) != 1 {
+		t.Fatalf("continue listening = %+v, %v; want the started episode", items, err)
+	}
+	if err := tx.Model(&models.Episode{}).Where("id IN ?", []uint{started.ID, heard.ID}).
+		Update("status", models.EpisodeDraft).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if items, err := podcasts.ContinueListening(ctx, listener.ID, 10); err != nil || len(items) != 0 {
+		t.Errorf("continue listening = %+v, %v; want nothing", items, err)
+	}
+	entries, err := content.History(ctx, listener.ID, HistoryFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 {
+		t.Fatal("no history")
+	}
+	for _, e := range entries {
+		if e.Episode != nil {
+			t.Errorf("entry %d shows the unpublished episode", e.ID)
+		}
+	}
+}
+
+// A podcast taken back to draft disappears from its listeners' history and continue
+// listening, but not from its author's.
+func TestProgressDraftPodcast(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	author, p := testPodcast(t, tx)
+	listener := &models.User{Name: "Listener", Email: "listener-" + t.Name() + "@example.com", PasswordHash: "x"}
+	if err := tx.Create(listener).Error; err != nil {
+		t.Fatal(err)
+	}
+	ep := models.Episode{PodcastID: p.ID, Title: "Pilot", Duration: 600, Status: models.EpisodePublished}
+	if err := tx.Create(&ep).Error; err != nil {
+		t.Fatal(err)
+	}
+	podcasts, content := NewPodcastRepository(tx, nil), NewUserContentRepository(tx)
+	for _, user := range []*models.User{author, listener} {
+		if _, err := podcasts.SaveProgress(ctx, user.ID, ep.ID, 10, false); err != nil {
+			t.Fatal(err)
+		}
+		if err := insertHistory(tx, user.ID, ep.ID, models.HistoryStarted); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tx.Model(p).Update("visibility", models.PodcastDraft).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, user := range []*models.User{author, listener} {
+		visible := user == author
+		items, err := podcasts.ContinueListening(ctx, user.ID, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (len(items) == 1) != visible {
+			t.Errorf("%s: continue listening = %+v", user.Name, items)
+		}
+		entries, err := content.History(ctx, user.ID, HistoryFilter{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || (entries[0].Podcast != nil) != visible {
+			t.Errorf("%s: history = %+v", user.Name, entries)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"podcast-backend/internal/models"
+)
+
+// ErrInvalidSchedule is returned for an unknown status or a publishAt that contradicts it.
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// publishedEpisodes restricts an episodes query to what listeners may see.
+func publishedEpisodes(db *gorm.DB) *gorm.DB {
+	return db.Where("episodes.status = ?", models.EpisodePublished)
+}
+
+// applySchedule validates the status and publishAt requested by an author. Without a
+// status the episode is published, or scheduled when publishAt lies in the future.
+// Published episodes always get a publishAt, now unless the author backdates them.
+func applySchedule(ep *models.Episode, now time.Time) error {
+	future := ep.PublishAt != nil && ep.PublishAt.After(now)
+	switch ep.Status {
+	case "":
+		ep.Status = models.EpisodePublished
+		if future {
+			ep.Status = models.EpisodeScheduled
+		}
+	case models.EpisodeDraft:
+	case models.EpisodeScheduled:
+		if !future {
+			return fmt.Errorf("%w: publishAt must be in the future", ErrInvalidSchedule)
+		}
+	case models.EpisodePublished:
+		if future {
+			return fmt.Errorf("%w: published episode with a future publishAt", ErrInvalidSchedule)
+		}
+	default:
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidSchedule, ep.Status)
+	}
+	if ep.Status == models.EpisodePublished && ep.PublishAt == nil {
+		ep.PublishAt = &now
+	}
+	return nil
+}
+
+// PublishDue publishes scheduled episodes whose time has come, records an
+// episode_published event for each and returns them. The update is a single
+// statement, so with several instances each episode is returned by exactly one of them.
+func (r *PodcastRepository) PublishDue(ctx context.Context, now time.Time) ([]models.Episode, error) {
+	var due []models.Episode
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&due).Clauses(clause.Returning{}).
+			Where("status = ? AND publish_at <= ?", models.EpisodeScheduled, now).
+			Updates(map[string]interface{}{"status": models.EpisodePublished, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		if len(due) == 0 {
+			return nil
+		}
+		ids := make([]uint, 0, len(due))
+		for _, ep := range due {
+			ids = append(ids, ep.PodcastID)
+		}
+		var podcasts []models.Podcast
+		if err := tx.Find(&podcasts, ids).Error; err != nil {
+			return err
+		}
+		byID := make(map[uint]*models.Podcast, len(podcasts))
+		for i := range podcasts {
+			byID[podcasts[i].ID] = &podcasts[i]
+		}
+		for i := range due {
+			if p := byID[due[i].PodcastID]; p != nil {
+				if err := recordEpisodeEvent(tx, "episode_published", &due[i], p, &due[i]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(due) > 0 {
+		r.invalidateCache(ctx)
+	}
+	return due, nil
+}
+
+// NextPublishAt returns when the earliest scheduled episode is due, or nil when none is.
+func (r *PodcastRepository) NextPublishAt(ctx context.Context) (*time.Time, error) {
+	var next *time.Time
+	err := r.db.WithContext(ctx).Model(&models.Episode{}).
+		Where("status = ?", models.EpisodeScheduled).
+		Select("MIN(publish_at)").Scan(&next).Error
+	if err != nil {
+		return nil, err
+	}
+	return next, nil
+}
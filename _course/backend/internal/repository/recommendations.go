@@ -0,0 +1,241 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/models"
+)
+
+const (
+	// similarPerPodcast and recommendationsPerUser bound what the job stores.
+	similarPerPodcast      = 20
+	recommendationsPerUser = 50
+	// similarityMinUsers drops pairs seen together by a single listener, which are mostly noise.
+	similarityMinUsers = 2
+)
+
+// interactionsCTE weighs how much each user cares about each podcast: a favorite
+// says more than a library subscription, which says more than liking an episode.
+const interactionsCTE = `interactions AS (
+	SELECT user_id, podcast_id, SUM(w) AS w FROM (
+		SELECT user_id, podcast_id, 3.0 AS w FROM favorites
+		UNION ALL
+		SELECT user_id, podcast_id, 2.0 FROM library_items
+		UNION ALL
+		SELECT DISTINCT l.user_id, e.podcast_id, 1.0 FROM episode_likes l JOIN episodes e ON e.id = l.episode_id
+	) x
+	GROUP BY user_id, podcast_id
+)`
+
+// Cosine similarity between the interaction vectors of two podcasts.
+const similaritiesSQL = `
+	WITH ` + interactionsCTE + `,
+	norms AS (
+		SELECT podcast_id, sqrt(SUM(w * w)) AS norm FROM interactions GROUP BY podcast_id
+	),
+	pairs AS (
+		SELECT a.podcast_id, b.podcast_id AS similar_id, SUM(a.w * b.w) AS dot, COUNT(*) AS users
+		FROM interactions a JOIN interactions b ON b.user_id = a.user_id AND b.podcast_id <> a.podcast_id
+		GROUP BY a.podcast_id, b.podcast_id
+	),
+	ranked AS (
+		SELECT p.podcast_id, p.similar_id, p.dot / (na.norm * nb.norm) AS score,
+			ROW_NUMBER() OVER (PARTITION BY p.podcast_id ORDER BY p.dot / (na.norm * nb.norm) DESC, p.similar_id) AS n
+		FROM pairs p
+		JOIN norms na ON na.podcast_id = p.podcast_id
+		JOIN norms nb ON nb.podcast_id = p.similar_id
+		WHERE p.users >= @min_users
+	)
+	INSERT INTO podcast_similarities (podcast_id, similar_id, score, computed_at)
+	SELECT podcast_id, similar_id, score, @now FROM ranked WHERE n <= @top`
+
+// A user's score for a podcast sums the similarity of that podcast to everything
+// the user interacted with, weighted by the interaction.
+const recommendationsSQL = `
+	WITH ` + interactionsCTE + `,
+	scored AS (
+		SELECT i.user_id, s.similar_id AS podcast_id, SUM(i.w * s.score) AS score
+		FROM interactions i JOIN podcast_similarities s ON s.podcast_id = i.podcast_id
+		WHERE NOT EXISTS (SELECT 1 FROM library_items o WHERE o.user_id = i.user_id AND o.podcast_id = s.similar_id)
+			AND NOT EXISTS (SELECT 1 FROM favorites o WHERE o.user_id = i.user_id AND o.podcast_id = s.similar_id)
+		GROUP BY i.user_id, s.similar_id
+	),
+	ranked AS (
+		SELECT user_id, podcast_id, score,
+			ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY score DESC, podcast_id) AS n
+		FROM scored
+	)
+	INSERT INTO recommendations (user_id, podcast_id, score, computed_at)
+	SELECT user_id, podcast_id, score, @now FROM ranked WHERE n <= @top`
+
+// RecommendedPodcast is a podcast with why it was suggested: "similar" for
+// collaborative filtering, "popular" for the category fallback.
+type RecommendedPodcast struct {
+	models.Podcast
+	// Episodes are never loaded here; this empty field shadows the embedded one so
+	// that JSON leaves them out instead of sending "episodes": null.
+	Episodes []models.Episode `json:"episodes,omitempty" gorm:"-"`
+	Score    float64          `json:"score"`
+	Reason   string           `json:"reason"`
+}
+
+// RebuildRecommendations recomputes podcast similarities and then every user's
+// recommendations in one transaction, so readers never see a partial table. When
+// another instance is rebuilding them it returns without doing anything.
+func (r *PodcastRepository) RebuildRecommendations(ctx context.Context) error {
+	now := time.Now()
+	_, err := exclusively(ctx, r.db, recommendationsLockKey, func(tx *gorm.DB) error {
+		if err := tx.Exec(`DELETE FROM podcast_similarities`).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(similaritiesSQL, map[string]interface{}{
+			"now": now, "top": similarPerPodcast, "min_users": similarityMinUsers,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(`DELETE FROM recommendations`).Error; err != nil {
+			return err
+		}
+		return tx.Exec(recommendationsSQL, map[string]interface{}{
+			"now": now, "top": recommendationsPerUser,
+		}).Error
+	})
+	return err
+}
+
+// Similar returns public podcasts similar to podcastID, topped up with popular podcasts
+// of its category when there is not enough listening data. It returns nil, nil for an
+// unknown or draft podcast.
+func (r *PodcastRepository) Similar(ctx context.Context, podcastID uint, limit int) ([]RecommendedPodcast, error) {
+	var podcast models.Podcast
+	if err := r.db.WithContext(ctx).Select("id", "category_id", "visibility").Take(&podcast, podcastID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if podcast.Visibility == models.PodcastDraft {
+		return nil, nil
+	}
+
+	out := []RecommendedPodcast{}
+	err := r.db.WithContext(ctx).
+		Table("podcasts").
+		Select("podcasts.*, s.score, 'similar' AS reason").
+		Joins("JOIN podcast_similarities s ON s.similar_id = podcasts.id").
+		Where("s.podcast_id = ?", podcastID).
+		Scopes(publicPodcasts).
+		Order("s.score DESC, podcasts.id").
+		Limit(limit).
+		Scan(&out).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(out) >= limit {
+		return out, nil
+	}
+
+	exclude := []uint{podcastID}
+	for _, p := range out {
+		exclude = append(exclude, p.ID)
+	}
+	var categories []uint
+	if podcast.CategoryID != nil {
+		categories = []uint{*podcast.CategoryID}
+	}
+	more, err := r.popular(ctx, 0, categories, exclude, limit-len(out))
+	if err != nil {
+		return nil, err
+	}
+	return append(out, more...), nil
+}
+
+// Recommendations returns the user's precomputed recommendations. Users the job knows
+// too little about get popular podcasts from the categories they follow, and then
+// from the whole catalogue. Podcasts already in the library or favorites are skipped.
+func (r *PodcastRepository) Recommendations(ctx context.Context, userID uint, limit int) ([]RecommendedPodcast, error) {
+	db := r.db.WithContext(ctx)
+	out := []RecommendedPodcast{}
+	err := db.Table("podcasts").
+		Select("podcasts.*, rec.score, 'similar' AS reason").
+		Joins("JOIN recommendations rec ON rec.podcast_id = podcasts.id").
+		Where("rec.user_id = ?", userID).
+		Scopes(publicPodcasts).
+		Where("NOT EXISTS (SELECT 1 FROM library_items li WHERE li.user_id = rec.user_id AND li.podcast_id = podcasts.id)").
+		Where("NOT EXISTS (SELECT 1 FROM favorites f WHERE f.user_id = rec.user_id AND f.podcast_id = podcasts.id)").
+		Order("rec.score DESC, podcasts.id").
+		Limit(limit).
+		Scan(&out).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(out) >= limit {
+		return out, nil
+	}
+
+	exclude := make([]uint, 0, len(out))
+	for _, p := range out {
+		exclude = append(exclude, p.ID)
+	}
+	var categories []uint
+	if err := db.Raw(`
+		SELECT DISTINCT p.category_id FROM podcasts p
+		WHERE p.category_id IS NOT NULL AND p.id IN (
+			SELECT podcast_id FROM library_items WHERE user_id = @user
+			UNION SELECT podcast_id FROM favorites WHERE user_id = @user
+			UNION SELECT e.podcast_id FROM episode_likes l JOIN episodes e ON e.id = l.episode_id WHERE l.user_id = @user
+		)`, map[string]interface{}{"user": userID}).Scan(&categories).Error; err != nil {
+		return nil, err
+	}
+	if len(categories) > 0 {
+		more, err := r.popular(ctx, userID, categories, exclude, limit-len(out))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, more...)
+		for _, p := range more {
+			exclude = append(exclude, p.ID)
+		}
+	}
+	if len(out) < limit {
+		more, err := r.popular(ctx, userID, nil, exclude, limit-len(out))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, more...)
+	}
+	return out, nil
+}
+
+// popular ranks podcasts by the monthly chart and then by favorites. categories (if
+// any) restrict the result, exclude lists podcast ids to skip and a non-zero userID
+// skips what that user already follows.
+func (r *PodcastRepository) popular(ctx context.Context, userID uint, categories []uint, exclude []uint, limit int) ([]RecommendedPodcast, error) {
+	q := r.db.WithContext(ctx).
+		Table("podcasts").
+		Select("podcasts.*, COALESCE(ce.score, 0) AS score, 'popular' AS reason").
+		Joins("LEFT JOIN chart_entries ce ON ce.kind = ? AND ce.period = ? AND ce.item_id = podcasts.id", models.ChartPodcasts, "month").
+		Scopes(publicPodcasts)
+	if len(categories) > 0 {
+		q = q.Where("podcasts.category_id IN ?", categories)
+	}
+	if len(exclude) > 0 {
+		q = q.Where("podcasts.id NOT IN ?", exclude)
+	}
+	if userID != 0 {
+		q = q.Where("NOT EXISTS (SELECT 1 FROM library_items li WHERE li.user_id = ? AND li.podcast_id = podcasts.id)", userID).
+			Where("NOT EXISTS (SELECT 1 FROM favorites f WHERE f.user_id = ? AND f.podcast_id = podcasts.id)", userID)
+	}
+	out := []RecommendedPodcast{}
+	err := q.Order("score DESC, (SELECT COUNT(*) FROM favorites fc WHERE fc.podcast_id = podcasts.id) DESC, podcasts.id DESC").
+		Limit(limit).
+		Scan(&out).Error
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
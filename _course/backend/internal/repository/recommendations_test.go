@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"podcast-backend/internal/models"
+)
+
+func TestRecommendedPodcastJSON(t *testing.T) {
+	data, err := json.Marshal(RecommendedPodcast{Podcast: models.Podcast{ID: 1, Title: "Pilot"}, Score: 0.5, Reason: "similar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"episodes"`) {
+		t.Errorf("%s: episodes are not loaded and must be left out", data)
+	}
+	if !strings.Contains(string(data), `"title":"Pilot"`) || !strings.Contains(string(data), `"reason":"similar"`) {
+		t.Errorf("%s: want the podcast with its reason", data)
+	}
+}
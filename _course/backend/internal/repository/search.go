@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"strings"
+)
+
+// tsQuery parses user input the way web search boxes do ("quoted phrases", -exclusions, or)
+// with both configurations used by the search_vector columns. It takes the query twice.
+const tsQuery = `(websearch_to_tsquery('russian', ?) || websearch_to_tsquery('english', ?))`
+
+// headlineOptions keeps snippets short; the text is HTML-escaped before highlighting,
+// so <mark> is the only markup in a snippet. Headlines use the Russian configuration
+// alone: it stems ASCII words with the English stemmer, so both languages match.
+const headlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxWords=30, MinWords=12, MaxFragments=2, FragmentDelimiter=" … "`
+
+// SearchResult is a podcast or an episode matching a full-text query.
+type SearchResult struct {
+	Type           string  `json:"type"` // "podcast" or "episode"
+	ID             uint    `json:"id"`
+	PodcastID      uint    `json:"podcastId"`
+	Title          string  `json:"title"`
+	PodcastTitle   string  `json:"podcastTitle"`
+	Image          *string `json:"image"`
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"titleHighlight"`
+	Snippet        string  `json:"snippet"`
+}
+
+// SearchQuery selects what FullTextSearch returns; Type is "podcast", "episode" or empty for both.
+// Tag (a slug) restricts results to episodes carrying it.
+type SearchQuery struct {
+	Text   string
+	Type   string
+	Tag    string
+	Limit  int
+	Offset int
+}
+
+// episodeTagFilter is the SQL restricting episodes e to the query tag, if any.
+func (q SearchQuery) episodeTagFilter() (string, []interface{}) {
+	if q.Tag == "" {
+		return "", nil
+	}
+	return ` AND e.id IN (SELECT et.episode_id FROM episode_tags et JOIN tags t ON t.id = et.tag_id WHERE t.slug = ?)`, []interface{}{q.Tag}
+}
+
+// FullTextSearch returns public podcasts and their published episodes ranked together by relevance.
+// Snippets are only built for the returned page.
+func (r *PodcastRepository) FullTextSearch(ctx context.Context, q SearchQuery) ([]SearchResult, error) {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPageSize
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+
+	var parts []string
+	var args []interface{}
+	if (q.Type == "" || q.Type == "podcast") && q.Tag == "" {
+		parts = append(parts, `
+			SELECT 'podcast' AS type, p.id, p.id AS podcast_id, p.title, p.title AS podcast_title,
+				p.image, p.description AS body, ts_rank_cd(p.search_vector, query) AS rank
+			FROM podcasts p, (SELECT `+tsQuery+` AS query) q
+			WHERE p.search_vector @@ query AND p.visibility = 'public'`)
+		args = append(args, q.Text, q.Text)
+	}
+	if q.Type == "" || q.Type == "episode" {
+		parts = append(parts, `
+			SELECT 'episode', e.id, e.podcast_id, e.title, p.title,
+				p.image, e.description, ts_rank_cd(e.search_vector, query)
+			FROM episodes e JOIN podcasts p ON p.id = e.podcast_id, (SELECT `+tsQuery+` AS query) q
+			WHERE e.search_vector @@ query AND e.status = 'published' AND p.visibility = 'public'`)
+		args = append(args, q.Text, q.Text)
+		filter, filterArgs := q.episodeTagFilter()
+		parts[len(parts)-1] += filter
+		args = append(args, filterArgs...)
+	}
+	if len(parts) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	sql := `
+		SELECT type, id, podcast_id, title, podcast_title, image, rank,
+			ts_headline('russian', ` + escapeHTML("title") + `, query, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>') AS title_highlight,
+			ts_headline('russian', ` + escapeHTML("body") + `, query, '` + headlineOptions + `') AS snippet
+		FROM (` + strings.Join(parts, " UNION ALL ") + `
+			ORDER BY rank DESC, type, id DESC
+			LIMIT ? OFFSET ?
+		) hits, (SELECT ` + tsQuery + ` AS query) q
+		ORDER BY rank DESC, type, id DESC`
+	args = append(args, q.Limit, q.Offset, q.Text, q.Text)
+
+	results := []SearchResult{}
+	if err := r.db.WithContext(ctx).Raw(sql, args...).Scan(&results).Error; err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// SearchTagFacets counts the tags of all episodes matching the query, most frequent first.
+func (r *PodcastRepository) SearchTagFacets(ctx context.Context, q SearchQuery, limit int) ([]TagCount, error) {
+	facets := []TagCount{}
+	if q.Type == "podcast" {
+		return facets, nil
+	}
+	filter, filterArgs := q.episodeTagFilter()
+	args := append([]interface{}{q.Text, q.Text}, filterArgs...)
+	args = append(args, limit)
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT t.slug, t.name, COUNT(*) AS count
+		FROM episodes e
+		JOIN episode_tags et ON et.episode_id = e.id
+		JOIN tags t ON t.id = et.tag_id
+		WHERE e.status = 'published' AND e.search_vector @@ `+tsQuery+filter+`
+			AND e.podcast_id IN (SELECT id FROM podcasts WHERE visibility = 'public')
+		GROUP BY t.id
+		ORDER BY count DESC, t.slug
+		LIMIT ?`, args...).Scan(&facets).Error
+	if err != nil {
+		return nil, err
+	}
+	return facets, nil
+}
+
+// escapeHTML is the SQL for an HTML-escaped copy of column, so highlighted snippets
+// can be rendered as markup.
+func escapeHTML(column string) string {
+	return `replace(replace(replace(coalesce(` + column + `, ''), '&', '&amp;'), '<', '&lt;'), '>', '&gt;')`
+}
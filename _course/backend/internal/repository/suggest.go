@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	DefaultSuggestions = 8
+	MaxSuggestions     = 20
+
+	suggestCacheTTL = time.Minute
+	// maxCorrectedWords bounds the per-word lookups of DidYouMean.
+	maxCorrectedWords = 6
+)
+
+// Suggestion is one autocomplete entry. ID and PodcastID are zero for authors.
+type Suggestion struct {
+	Type      string  `json:"type"` // "podcast", "author" or "episode"
+	Text      string  `json:"text"`
+	ID        uint    `json:"id,omitempty"`
+	PodcastID uint    `json:"podcastId,omitempty"`
+	Score     float64 `json:"score"`
+}
+
+// Suggest returns titles and authors similar to a partially typed query. Prefix
+// matches come first, then trigram word similarity, so "podc" finds "Podcast"
+// and "podcsat" still does.
+func (r *PodcastRepository) Suggest(ctx context.Context, text string, limit int) ([]Suggestion, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	cacheKey := "suggest:" + text
+	if r.cacheEnable {
+		if data, err := r.redis.Get(ctx, cacheKey).Result(); err == nil {
+			var cached []Suggestion
+			if json.Unmarshal([]byte(data), &cached) == nil {
+				return cached[:min(limit, len(cached))], nil
+			}
+		}
+	}
+
+	prefix := escapeLike(text) + "%"
+	contains := "%" + escapeLike(text) + "%"
+	score := func(col string) string {
+		return "word_similarity(@q, " + col + ") + CASE WHEN " + col + " ILIKE @prefix THEN 1 ELSE 0 END"
+	}
+	match := func(col string) string {
+		return "(@q <% " + col + " OR " + col + " ILIKE @contains)"
+	}
+	query := `
+		(SELECT 'podcast' AS type, title AS text, id, id AS podcast_id, ` + score("title") + ` AS score
+			FROM podcasts WHERE visibility = 'public' AND ` + match("title") + ` ORDER BY score DESC LIMIT @limit)
+		UNION ALL
+		(SELECT 'author', author, 0, 0, ` + score("author") + ` AS score
+			FROM podcasts WHERE visibility = 'public' AND author <> '' AND ` + match("author") + `
+			GROUP BY author ORDER BY score DESC LIMIT @limit)
+		UNION ALL
+		(SELECT 'episode', title, id, podcast_id, ` + score("title") + ` AS score
+			FROM episodes WHERE status = 'published' AND ` + match("title") + `
+				AND podcast_id IN (SELECT id FROM podcasts WHERE visibility = 'public') ORDER BY score DESC LIMIT @limit)
+		ORDER BY score DESC, text
+		LIMIT @limit`
+
+	// Cache the largest page so any limit can be served from it.
+	suggestions := []Suggestion{}
+	err := r.db.WithContext(ctx).Raw(query, map[string]interface{}{
+		"q": text, "prefix": prefix, "contains": contains, "limit": MaxSuggestions,
+	}).Scan(&suggestions).Error
+	if err != nil {
+		return nil, err
+	}
+	if r.cacheEnable {
+		if b, err := json.Marshal(suggestions); err == nil {
+			_ = r.redis.Set(ctx, cacheKey, b, suggestCacheTTL).Err()
+		}
+	}
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+// DidYouMean replaces each unknown word of text with the closest indexed word and
+// returns the corrected query, or "" when there is nothing to correct.
+func (r *PodcastRepository) DidYouMean(ctx context.Context, text string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	if len(words) > maxCorrectedWords {
+		words = words[:maxCorrectedWords]
+	}
+	db := r.db.WithContext(ctx)
+	changed := false
+	for i, word := range words {
+		if len([]rune(word)) < 3 {
+			continue
+		}
+		var closest string
+		err := db.Raw(`SELECT word FROM search_terms WHERE word % ? ORDER BY word = ? DESC, similarity(word, ?) DESC, ndoc DESC LIMIT 1`,
+			word, word, word).Row().Scan(&closest)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if closest != word {
+			words[i] = closest
+			changed = true
+		}
+	}
+	if !changed {
+		return "", nil
+	}
+	return strings.Join(words, " "), nil
+}
+
+// RefreshSearchTerms rebuilds the DidYouMean vocabulary without blocking readers.
+func (r *PodcastRepository) RefreshSearchTerms(ctx context.Context) error {
+	return r.db.WithContext(ctx).Exec(`REFRESH MATERIALIZED VIEW CONCURRENTLY search_terms`).Error
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"podcast-backend/internal/models"
+)
+
+const (
+	MaxEpisodeTags = 20
+	MaxTagLength   = 50
+)
+
+// TagSlug normalizes a tag name: lower case words of letters and digits joined by "-".
+// It returns "" for names without any.
+func TagSlug(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	})
+	return strings.Join(words, "-")
+}
+
+// TagCount is a tag with the number of episodes carrying it.
+type TagCount struct {
+	Slug  string `json:"slug"`
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// SetEpisodeTags replaces the tags of an episode owned by userID. Tags are shared by
+// slug, so the first spelling of a tag becomes its display name.
+func (r *EpisodeRepository) SetEpisodeTags(ctx context.Context, episodeID uint, names []string, userID uint) ([]models.Tag, error) {
+	ep, err := r.GetForAuthor(ctx, episodeID, userID)
+	if err != nil || ep == nil {
+		return nil, err
+	}
+
+	bySlug := make(map[string]models.Tag)
+	var slugs []string
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		slug := TagSlug(name)
+		if _, dup := bySlug[slug]; slug == "" || dup {
+			continue
+		}
+		bySlug[slug] = models.Tag{Slug: slug, Name: name}
+		slugs = append(slugs, slug)
+	}
+
+	tags := []models.Tag{}
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(slugs) > 0 {
+			create := make([]models.Tag, 0, len(slugs))
+			for _, slug := range slugs {
+				create = append(create, bySlug[slug])
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&create).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("slug IN ?", slugs).Order("name").Find(&tags).Error; err != nil {
+				return err
+			}
+		}
+		ids := make([]uint, len(tags))
+		for i, t := range tags {
+			ids[i] = t.ID
+		}
+
+		del := tx.Where("episode_id = ?", episodeID)
+		if len(ids) > 0 {
+			del = del.Where("tag_id NOT IN ?", ids)
+		}
+		if err := del.Delete(&models.EpisodeTag{}).Error; err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			links := make([]models.EpisodeTag, len(ids))
+			for i, id := range ids {
+				links[i] = models.EpisodeTag{EpisodeID: episodeID, TagID: id}
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error; err != nil {
+				return err
+			}
+		}
+		var pod models.Podcast
+		if err := tx.First(&pod, ep.PodcastID).Error; err != nil {
+			return err
+		}
+		return recordEpisodeEvent(tx, "episode_tags", ep, &pod, map[string]interface{}{"episodeId": episodeID, "tags": tags})
+	})
+	if err != nil {
+		return nil, err
+	}
+	bumpCacheGen(ctx, r.redis)
+	return tags, nil
+}
+
+// TagCounts returns the tags most used by published episodes, within one podcast or
+// across public ones, for a tag cloud.
+func (r *PodcastRepository) TagCounts(ctx context.Context, podcastID uint, limit int) ([]TagCount, error) {
+	q := r.db.WithContext(ctx).
+		Table("tags").
+		Select("tags.slug, tags.name, COUNT(*) AS count").
+		Joins("JOIN episode_tags et ON et.tag_id = tags.id").
+		Joins("JOIN episodes e ON e.id = et.episode_id AND e.status = ?", models.EpisodePublished)
+	if podcastID != 0 {
+		q = q.Where("e.podcast_id = ?", podcastID)
+	} else {
+		q = q.Where("e.podcast_id IN (SELECT id FROM podcasts WHERE visibility = ?)", models.PodcastPublic)
+	}
+	counts := []TagCount{}
+	if err := q.Group("tags.id").Order("count DESC, tags.slug").Limit(limit).Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
+
+// Tag returns nil, nil for an unknown slug.
+func (r *PodcastRepository) Tag(ctx context.Context, slug string) (*models.Tag, error) {
+	var t models.Tag
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&t).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTagEpisodes returns a page of published episodes of public podcasts carrying a tag.
+func (r *PodcastRepository) ListTagEpisodes(ctx context.Context, tagID uint, q EpisodeQuery) (*EpisodePage, error) {
+	return r.episodePage(ctx, "tag:"+strconv.FormatUint(uint64(tagID), 10), func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(publishedEpisodes, publicPodcastEpisodes).Where("episodes.id IN (SELECT episode_id FROM episode_tags WHERE tag_id = ?)", tagID)
+	}, q)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"podcast-backend/internal/models"
 )
@@ -16,24 +17,37 @@ func NewUserContentRepository(db *gorm.DB) *UserContentRepository {
 	return &UserContentRepository{db: db}
 }
 
+// ToggleFavorite adds or removes a podcast and records favorite_added or favorite_removed
+// for the user's other devices.
 func (r *UserContentRepository) ToggleFavorite(ctx context.Context, userID, podcastID uint) (bool, error) {
-	var fav models.Favorite
-	err := r.db.WithContext(ctx).Where("user_id = ? AND podcast_id = ?", userID, podcastID).First(&fav).Error
-	if err == nil {
-		if err := r.db.WithContext(ctx).Delete(&fav).Error; err != nil {
-			return false, err
+	added := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var fav models.Favorite
+		err := tx.Where("user_id = ? AND podcast_id = ?", userID, podcastID).First(&fav).Error
+		if err == nil {
+			if err := tx.Delete(&fav).Error; err != nil {
+				return err
+			}
+		} else {
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+			fav = models.Favorite{UserID: userID, PodcastID: podcastID}
+			if err := tx.Create(&fav).Error; err != nil {
+				return err
+			}
+			added = true
 		}
-		return false, nil
-	}
-	if err != nil && err != gorm.ErrRecordNotFound {
-		return false, err
-	}
-
-	fav = models.Favorite{UserID: userID, PodcastID: podcastID}
-	if err := r.db.WithContext(ctx).Create(&fav).Error; err != nil {
+		evType := "favorite_removed"
+		if added {
+			evType = "favorite_added"
+		}
+		return recordEvent(tx, evType, podcastID, 0, userID, map[string]interface{}{"podcastId": podcastID})
+	})
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+	return added, nil
 }
 
 func (r *UserContentRepository) Favorites(ctx context.Context, userID uint) ([]models.Podcast, error) {
@@ -41,31 +55,45 @@ func (r *UserContentRepository) Favorites(ctx context.Context, userID uint) ([]m
 	if err := r.db.WithContext(ctx).
 		Joins("JOIN favorites ON favorites.podcast_id = podcasts.id").
 		Where("favorites.user_id = ?", userID).
-		Preload("Episodes").
+		Scopes(viewablePodcasts(userID)).
+		Preload("Episodes", publishedEpisodes).
 		Find(&podcasts).Error; err != nil {
 		return nil, err
 	}
 	return podcasts, nil
 }
 
+// ToggleLibrary adds or removes a podcast and records library_added or library_removed
+// for the user's other devices.
 func (r *UserContentRepository) ToggleLibrary(ctx context.Context, userID, podcastID uint) (bool, error) {
-	var item models.LibraryItem
-	err := r.db.WithContext(ctx).Where("user_id = ? AND podcast_id = ?", userID, podcastID).First(&item).Error
-	if err == nil {
-		if err := r.db.WithContext(ctx).Delete(&item).Error; err != nil {
-			return false, err
+	added := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var item models.LibraryItem
+		err := tx.Where("user_id = ? AND podcast_id = ?", userID, podcastID).First(&item).Error
+		if err == nil {
+			if err := tx.Delete(&item).Error; err != nil {
+				return err
+			}
+		} else {
+			if err != gorm.ErrRecordNotFound {
+				return err
+			}
+			item = models.LibraryItem{UserID: userID, PodcastID: podcastID}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			added = true
 		}
-		return false, nil
-	}
-	if err != nil && err != gorm.ErrRecordNotFound {
-		return false, err
-	}
-
-	item = models.LibraryItem{UserID: userID, PodcastID: podcastID}
-	if err := r.db.WithContext(ctx).Create(&item).Error; err != nil {
+		evType := "library_removed"
+		if added {
+			evType = "library_added"
+		}
+		return recordEvent(tx, evType, podcastID, 0, userID, map[string]interface{}{"podcastId": podcastID})
+	})
+	if err != nil {
 		return false, err
 	}
-	return true, nil
+	return added, nil
 }
 
 func (r *UserContentRepository) Library(ctx context.Context, userID uint) ([]models.Podcast, error) {
@@ -73,10 +101,72 @@ func (r *UserContentRepository) Library(ctx context.Context, userID uint) ([]mod
 	if err := r.db.WithContext(ctx).
 		Joins("JOIN library_items ON library_items.podcast_id = podcasts.id").
 		Where("library_items.user_id = ?", userID).
-		Preload("Episodes").
+		Scopes(viewablePodcasts(userID)).
+		Preload("Episodes", publishedEpisodes).
 		Find(&podcasts).Error; err != nil {
 		return nil, err
 	}
 	return podcasts, nil
 }
 
+// MatchPodcast resolves an OPML outline to a podcast userID may see: by our own id,
+// then by the external feed url, then by case-insensitive title. Ids reach unlisted
+// podcasts like links do; urls and titles only match public ones. Drafts match for
+// their author only. Returns nil when nothing matches.
+func (r *UserContentRepository) MatchPodcast(ctx context.Context, userID, podcastID uint, feedURL, title string) (*models.Podcast, error) {
+	var podcast models.Podcast
+	db := r.db.WithContext(ctx)
+	listed := db.Where("podcasts.visibility = ? OR podcasts.author_id = ?", models.PodcastPublic, userID)
+	var err error
+	switch {
+	case podcastID != 0:
+		err = db.First(&podcast, podcastID).Error
+		if err == nil && !visibleTo(&podcast, userID) {
+			return nil, nil
+		}
+	case feedURL != "":
+		err = db.Where(listed).Where("feed_url = ?", feedURL).First(&podcast).Error
+		if err == gorm.ErrRecordNotFound && title != "" {
+			err = db.Where(listed).Where("LOWER(title) = LOWER(?)", title).First(&podcast).Error
+		}
+	case title != "":
+		err = db.Where(listed).Where("LOWER(title) = LOWER(?)", title).First(&podcast).Error
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &podcast, nil
+}
+
+// AddToLibrary adds podcasts to the user's library, ignoring ones already there.
+// Returns how many were actually added.
+func (r *UserContentRepository) AddToLibrary(ctx context.Context, userID uint, podcastIDs []uint) (int, error) {
+	if len(podcastIDs) == 0 {
+		return 0, nil
+	}
+	items := make([]models.LibraryItem, 0, len(podcastIDs))
+	for _, id := range podcastIDs {
+		items = append(items, models.LibraryItem{UserID: userID, PodcastID: id})
+	}
+	var added int
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&items)
+		if res.Error != nil {
+			return res.Error
+		}
+		added = int(res.RowsAffected)
+		if added == 0 {
+			return nil
+		}
+		return recordEvent(tx, "library_imported", 0, 0, userID, map[string]interface{}{"added": added})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return added, nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"podcast-backend/internal/models"
+)
+
+// ErrInvalidVisibility is returned for an unknown podcast visibility.
+var ErrInvalidVisibility = errors.New("invalid visibility")
+
+// publicPodcasts restricts a podcasts query to what lists, search, charts and
+// recommendations may show.
+func publicPodcasts(db *gorm.DB) *gorm.DB {
+	return db.Where("podcasts.visibility = ?", models.PodcastPublic)
+}
+
+// publicPodcastEpisodes restricts an episodes query to episodes of public podcasts.
+func publicPodcastEpisodes(db *gorm.DB) *gorm.DB {
+	return db.Where("episodes.podcast_id IN (SELECT id FROM podcasts WHERE visibility = ?)", models.PodcastPublic)
+}
+
+// applyVisibility defaults an empty visibility to public and rejects unknown ones.
+func applyVisibility(p *models.Podcast) error {
+	switch p.Visibility {
+	case "":
+		p.Visibility = models.PodcastPublic
+	case models.PodcastDraft, models.PodcastUnlisted, models.PodcastPublic:
+	default:
+		return fmt.Errorf("%w: %q", ErrInvalidVisibility, p.Visibility)
+	}
+	return nil
+}
+
+// visibleTo reports whether viewerID (0 for anonymous) may open the podcast by id:
+// drafts are for their author only.
+func visibleTo(p *models.Podcast, viewerID uint) bool {
+	return p.Visibility != models.PodcastDraft || (viewerID != 0 && viewerID == p.AuthorID)
+}
+
+// viewableEpisodes restricts an episodes query to what viewerID (0 for anonymous) may
+// open, as EpisodeRepository.GetForViewer decides: published episodes of podcasts that
+// are not drafts, and every episode of the viewer's own podcasts.
+func viewableEpisodes(viewerID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(episodes.status = ? AND episodes.podcast_id IN (SELECT id FROM podcasts WHERE visibility <> ?)) OR episodes.podcast_id IN (SELECT id FROM podcasts WHERE author_id = ?)",
+			models.EpisodePublished, models.PodcastDraft, viewerID)
+	}
+}
+
+// viewablePodcasts restricts a podcasts query to what viewerID may open: anything but
+// drafts, and the viewer's own drafts.
+func viewablePodcasts(viewerID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("podcasts.visibility <> ? OR podcasts.author_id = ?", models.PodcastDraft, viewerID)
+	}
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"podcast-backend/internal/models"
+)
+
+// Waveform returns the peak generation state of an episode, or nil if none was queued.
+func (r *EpisodeRepository) Waveform(ctx context.Context, episodeID uint) (*models.Waveform, error) {
+	var w models.Waveform
+	if err := r.db.WithContext(ctx).First(&w, "episode_id = ?", episodeID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &w, nil
+}
+
+// QueueWaveform marks peaks of the given audio object as pending. The previous
+// peaks object key is kept so it can be removed once the new one is ready.
+func (r *EpisodeRepository) QueueWaveform(ctx context.Context, episodeID uint, audioKey string) (*models.Waveform, error) {
+	w := models.Waveform{EpisodeID: episodeID, Status: models.WaveformPending, AudioKey: audioKey}
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "episode_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"status", "audio_key", "error", "updated_at"}),
+	}).Omit(clause.Associations).Create(&w).Error
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// PendingWaveforms returns queued waveforms, oldest first.
+func (r *EpisodeRepository) PendingWaveforms(ctx context.Context, limit int) ([]models.Waveform, error) {
+	var items []models.Waveform
+	if err := r.db.WithContext(ctx).
+		Where("status = ?", models.WaveformPending).
+		Order("updated_at").
+		Limit(limit).
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// FinishWaveform stores the outcome of generation. It only applies while the row
+// still refers to w.AudioKey, so a result for replaced audio is dropped; the
+// returned flag reports whether the row was updated.
+func (r *EpisodeRepository) FinishWaveform(ctx context.Context, w *models.Waveform) (bool, error) {
+	res := r.db.WithContext(ctx).Model(&models.Waveform{}).
+		Where("episode_id = ? AND audio_key = ? AND status = ?", w.EpisodeID, w.AudioKey, models.WaveformPending).
+		Updates(map[string]interface{}{"status": w.Status, "key": w.Key, "error": w.Error})
+	return res.RowsAffected > 0, res.Error
+}
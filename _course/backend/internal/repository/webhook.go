@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"podcast-backend/internal/models"
+	"podcast-backend/internal/webhooks"
+)
+
+const (
+	MaxPodcastWebhooks = 10
+	minWebhookSecret   = 16
+)
+
+// ErrInvalidWebhook is returned for a bad URL, event type or secret, and when a
+// podcast has too many webhooks.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// applyWebhook validates a webhook set by an author and generates a secret when
+// none was given.
+func applyWebhook(w *models.Webhook) error {
+	if !webhooks.ValidURL(w.URL) {
+		return fmt.Errorf("%w: url must be an absolute http(s) url", ErrInvalidWebhook)
+	}
+	events := []string{}
+	for _, e := range w.Events {
+		if !slices.Contains(models.WebhookEvents, e) {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, e)
+		}
+		if !slices.Contains(events, e) {
+			events = append(events, e)
+		}
+	}
+	w.Events = events
+	switch {
+	case w.Secret == "":
+		b := make([]byte, 32)
+		if _, err := io.ReadFull(rand.Reader, b); err != nil {
+			return err
+		}
+		w.Secret = hex.EncodeToString(b)
+	case len(w.Secret) < minWebhookSecret:
+		return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidWebhook, minWebhookSecret)
+	}
+	return nil
+}
+
+// authorPodcast loads a podcast and checks that userID owns it; nil, nil if it does not exist.
+func (r *WebhookRepository) authorPodcast(ctx context.Context, podcastID, userID uint) (*models.Podcast, error) {
+	var podcast models.Podcast
+	if err := r.db.WithContext(ctx).First(&podcast, podcastID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if podcast.AuthorID != userID {
+		return nil, errors.New("forbidden")
+	}
+	return &podcast, nil
+}
+
+// authorWebhook loads a webhook whose podcast userID owns; nil, nil if it does not exist.
+func (r *WebhookRepository) authorWebhook(ctx context.Context, id, userID uint) (*models.Webhook, error) {
+	var w models.Webhook
+	if err := r.db.WithContext(ctx).First(&w, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if _, err := r.authorPodcast(ctx, w.PodcastID, userID); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// Webhooks lists the webhooks of a podcast for its author; nil, nil if the podcast does not exist.
+func (r *WebhookRepository) Webhooks(ctx context.Context, podcastID, userID uint) ([]models.Webhook, error) {
+	podcast, err := r.authorPodcast(ctx, podcastID, userID)
+	if err != nil || podcast == nil {
+		return nil, err
+	}
+	hooks := []models.Webhook{}
+	if err := r.db.WithContext(ctx).Where("podcast_id = ?", podcastID).Order("id").Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+	return hooks, nil
+}
+
+// CreateWebhook adds a webhook to a podcast owned by userID; nil, nil if the podcast
+// does not exist. The returned webhook carries its secret.
+func (r *WebhookRepository) CreateWebhook(ctx context.Context, podcastID, userID uint, w *models.Webhook) (*models.Webhook, error) {
+	podcast, err := r.authorPodcast(ctx, podcastID, userID)
+	if err != nil || podcast == nil {
+		return nil, err
+	}
+	if err := applyWebhook(w); err != nil {
+		return nil, err
+	}
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.Webhook{}).Where("podcast_id = ?", podcastID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count >= MaxPodcastWebhooks {
+		return nil, fmt.Errorf("%w: a podcast can have at most %d webhooks", ErrInvalidWebhook, MaxPodcastWebhooks)
+	}
+	w.ID = 0
+	w.PodcastID = podcastID
+	if err := r.db.WithContext(ctx).Create(w).Error; err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// UpdateWebhook replaces the URL and events of a webhook, and its active flag when
+// setActive is true; the secret only changes when data carries one. nil, nil if the
+// webhook does not exist.
+func (r *WebhookRepository) UpdateWebhook(ctx context.Context, id, userID uint, data *models.Webhook, setActive bool) (*models.Webhook, error) {
+	w, err := r.authorWebhook(ctx, id, userID)
+	if err != nil || w == nil {
+		return nil, err
+	}
+	w.URL = data.URL
+	w.Events = data.Events
+	if setActive {
+		w.Active = data.Active
+	}
+	if data.Secret != "" {
+		w.Secret = data.Secret
+	}
+	if err := applyWebhook(w); err != nil {
+		return nil, err
+	}
+	if err := r.db.WithContext(ctx).Select("url", "events", "active", "secret", "updated_at").Updates(w).Error; err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// DeleteWebhook removes a webhook together with its delivery log.
+func (r *WebhookRepository) DeleteWebhook(ctx context.Context, id, userID uint) error {
+	w, err := r.authorWebhook(ctx, id, userID)
+	if err != nil {
+		return err
+	}
+	if w == nil {
+		return gorm.ErrRecordNotFound
+	}
+	return r.db.WithContext(ctx).Delete(w).Error
+}
+
+// Deliveries returns the delivery log of a webhook, newest first, before the given
+// delivery id when it is not 0; nil, nil if the webhook does not exist.
+func (r *WebhookRepository) Deliveries(ctx context.Context, webhookID, userID, before uint, limit int) ([]models.WebhookDelivery, error) {
+	w, err := r.authorWebhook(ctx, webhookID, userID)
+	if err != nil || w == nil {
+		return nil, err
+	}
+	q := r.db.WithContext(ctx).Where("webhook_id = ?", webhookID)
+	if before != 0 {
+		q = q.Where("id < ?", before)
+	}
+	deliveries := []models.WebhookDelivery{}
+	if err := q.Order("id desc").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues the payload of an earlier delivery again as a new delivery;
+// nil, nil if the webhook or the delivery does not exist.
+func (r *WebhookRepository) Redeliver(ctx context.Context, webhookID, deliveryID, userID uint) (*models.WebhookDelivery, error) {
+	w, err := r.authorWebhook(ctx, webhookID, userID)
+	if err != nil || w == nil {
+		return nil, err
+	}
+	var orig models.WebhookDelivery
+	if err := r.db.WithContext(ctx).Where("webhook_id = ?", webhookID).First(&orig, deliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	now := time.Now()
+	d := models.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventKey:      "redeliver:" + strconv.FormatUint(uint64(orig.ID), 10) + ":" + strconv.FormatInt(now.UnixNano(), 10),
+		Event:         orig.Event,
+		Payload:       orig.Payload,
+		RedeliveryOf:  &orig.ID,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: now,
+	}
+	if err := r.db.WithContext(ctx).Create(&d).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// EnqueueDeliveries queues payload for the active webhooks of a podcast subscribed
+// to event. key identifies the occurrence: one already queued for a webhook under
+// the same key is skipped, so enqueueing is idempotent. It returns how many
+// deliveries were queued.
+func (r *WebhookRepository) EnqueueDeliveries(ctx context.Context, podcastID uint, event, key string, payload []byte) (int, error) {
+	var hooks []models.Webhook
+	if err := r.db.WithContext(ctx).Where("podcast_id = ? AND active", podcastID).Find(&hooks).Error; err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	var deliveries []models.WebhookDelivery
+	for _, w := range hooks {
+		if len(w.Events) > 0 && !slices.Contains(w.Events, event) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     w.ID,
+			EventKey:      key,
+			Event:         event,
+			Payload:       payload,
+			Status:        models.DeliveryPending,
+			NextAttemptAt: now,
+		})
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+	res := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "webhook_id"}, {Name: "event_key"}},
+		DoNothing: true,
+	}).Create(&deliveries)
+	return int(res.RowsAffected), res.Error
+}
+
+// ClaimDeliveries takes up to limit due deliveries, with their webhooks, and puts
+// them off by lease, so no other instance sends them meanwhile and a crashed sender's
+// deliveries are retried once the lease runs out.
+func (r *WebhookRepository) ClaimDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at, id
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id`,
+		now.Add(lease), models.DeliveryPending, now, limit).Scan(&ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+	var due []models.WebhookDelivery
+	if err := r.db.WithContext(ctx).Preload("Webhook").Order("id").Find(&due, ids).Error; err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// FinishAttempt stores the outcome of a delivery attempt.
+func (r *WebhookRepository) FinishAttempt(ctx context.Context, d *models.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Model(d).
+		Select("status", "attempts", "next_attempt_at", "response_code", "response_body", "error", "delivered_at", "updated_at").
+		Updates(d).Error
+}
+
+// PruneDeliveries deletes finished deliveries created before the given time and returns how many.
+func (r *WebhookRepository) PruneDeliveries(ctx context.Context, before time.Time) (int, error) {
+	res := r.db.WithContext(ctx).
+		Where("status <> ? AND created_at < ?", models.DeliveryPending, before).
+		Delete(&models.WebhookDelivery{})
+	return int(res.RowsAffected), res.Error
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"podcast-backend/internal/models"
+)
+
+func TestWebhookRedeliver(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	user, p := testPodcast(t, tx)
+	r := NewWebhookRepository(tx)
+
+	hook, err := r.CreateWebhook(ctx, p.ID, user.ID, &models.Webhook{URL: "https://example.com/hook", Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"event":"episode_created","podcastId":1,"data":{"id":5}}`)
+	for i := 0; i < 2; i++ {
+		// the same key twice queues one delivery
+		if _, err := r.EnqueueDeliveries(ctx, p.ID, models.WebhookEpisodeCreated, "outbox:1", payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due, err := r.ClaimDeliveries(ctx, time.Now(), time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].Webhook == nil || due[0].Webhook.ID != hook.ID {
+		t.Fatalf("claimed %+v, want one delivery of webhook %d", due, hook.ID)
+	}
+	if again, err := r.ClaimDeliveries(ctx, time.Now(), time.Minute, 10); err != nil || len(again) != 0 {
+		t.Fatalf("claimed %d leased deliveries again (err %v)", len(again), err)
+	}
+	orig := due[0]
+	orig.Status, orig.Attempts, orig.Error = models.DeliveryFailed, 10, "receiver responded with 500"
+	if err := r.FinishAttempt(ctx, &orig); err != nil {
+		t.Fatal(err)
+	}
+
+	redo, err := r.Redeliver(ctx, hook.ID, orig.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redo == nil || redo.ID == orig.ID || redo.RedeliveryOf == nil || *redo.RedeliveryOf != orig.ID {
+		t.Fatalf("redelivery = %+v, want a new delivery of %d", redo, orig.ID)
+	}
+	if string(redo.Payload) != string(orig.Payload) || redo.Event != orig.Event || redo.Status != models.DeliveryPending {
+		t.Errorf("redelivery = event %s, status %s, payload %s", redo.Event, redo.Status, redo.Payload)
+	}
+	due, err = r.ClaimDeliveries(ctx, time.Now(), time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].ID != redo.ID {
+		t.Fatalf("claimed %+v, want the redelivery %d", due, redo.ID)
+	}
+
+	if _, err := r.Redeliver(ctx, hook.ID, orig.ID, user.ID+1000); err == nil || err.Error() != "forbidden" {
+		t.Errorf("redeliver by another user: err = %v, want forbidden", err)
+	}
+	if d, err := r.Redeliver(ctx, hook.ID, orig.ID+1000, user.ID); err != nil || d != nil {
+		t.Errorf("redeliver of unknown delivery = %+v, %v; want nil, nil", d, err)
+	}
+}
+
+func TestUpdateWebhookKeepsActive(t *testing.T) {
+	tx := testDB(t)
+	ctx := context.Background()
+	user, p := testPodcast(t, tx)
+	r := NewWebhookRepository(tx)
+
+	hook, err := r.CreateWebhook(ctx, p.ID, user.ID, &models.Webhook{URL: "https://example.com/hook", Active: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.UpdateWebhook(ctx, hook.ID, user.ID, &models.Webhook{URL: "https://example.com/other", Active: true}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Active || w.URL != "https://example.com/other" || w.Secret != hook.Secret {
+		t.Errorf("after update without active: active %v, url %s, secret kept %v", w.Active, w.URL, w.Secret == hook.Secret)
+	}
+}
@@ -2,13 +2,18 @@ package seed
 
 import (
 	"log"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"podcast-backend/internal/categories"
 	"podcast-backend/internal/models"
 )
 
+// Run fills an empty database with a demo author and podcasts. It runs after
+// db.Migrate, so it resolves categories and publication times itself like the
+// repository does for new podcasts.
 func Run(db *gorm.DB) {
 	var count int64
 	if err := db.Model(&models.Podcast{}).Count(&count).Error; err != nil {
@@ -57,10 +62,33 @@ func Run(db *gorm.DB) {
 		},
 	}
 
+	now := time.Now()
+	for i := range sample {
+		p := &sample[i]
+		p.CategoryID = categoryID(db, *p.Category)
+		for j := range p.Episodes {
+			p.Episodes[j].Status = models.EpisodePublished
+			p.Episodes[j].PublishAt = &now
+		}
+	}
 	if err := db.Create(&sample).Error; err != nil {
 		log.Printf("seed create error: %v", err)
 	}
 }
 
+// categoryID resolves a free-form category to the tree created by db.Migrate.
+func categoryID(db *gorm.DB, name string) *uint {
+	slug := categories.Match(name)
+	if slug == "" {
+		return nil
+	}
+	var c models.Category
+	if err := db.Where("slug = ?", slug).First(&c).Error; err != nil {
+		log.Printf("seed category %q: %v", name, err)
+		return nil
+	}
+	return &c.ID
+}
+
 func strPtr(s string) *string { return &s }
 
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrNotFound is returned when an object does not exist in the backend.
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object.
+type ObjectInfo struct {
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// Storage is a blob store for uploaded media. Objects returned by Open are seekable so
+// they can be served with http.ServeContent and honour Range requests.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Config selects and configures a storage driver.
+type Config struct {
+	Driver   string // "local" or "s3"
+	LocalDir string
+
+	S3Endpoint  string
+	S3Region    string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+}
+
+// New builds the driver selected by cfg.Driver.
+func New(cfg Config) (Storage, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocal(cfg.LocalDir)
+	case "s3":
+		return NewS3(cfg.S3Endpoint, cfg.S3Region, cfg.S3Bucket, cfg.S3AccessKey, cfg.S3SecretKey, cfg.S3UseSSL)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
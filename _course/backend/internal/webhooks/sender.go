@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"podcast-backend/internal/netguard"
+)
+
+// maxResponseBody is how much of a receiver's answer is kept in the delivery log.
+const maxResponseBody = 1 << 10
+
+// Payload is the JSON body of every delivery.
+type Payload struct {
+	Event     string          `json:"event"`
+	PodcastID uint            `json:"podcastId"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Result is a receiver's answer to one attempt.
+type Result struct {
+	StatusCode int
+	Body       string // the first maxResponseBody bytes
+}
+
+// Sender POSTs signed deliveries. Client is replaceable so tests can point it at
+// httptest servers; the default one only connects to public addresses (see netguard),
+// since authors choose the URLs and see the responses.
+type Sender struct {
+	Client *http.Client
+}
+
+func NewSender() *Sender {
+	dialer := &net.Dialer{Timeout: 5 * time.Second, Control: netguard.Control}
+	return &Sender{Client: &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 5 * time.Second},
+		// a redirect is an answer, not something to follow with the signature attached
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+}
+
+// ValidURL reports whether rawURL can be a webhook target.
+func ValidURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Send delivers body once. Any answer but 2xx is an error; the result is returned
+// whenever the receiver answered.
+func (s *Sender) Send(ctx context.Context, rawURL, secret, event string, deliveryID uint, body []byte) (*Result, error) {
+	if !ValidURL(rawURL) {
+		return nil, fmt.Errorf("invalid webhook url %q", rawURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "podcast-backend/1.0")
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(deliveryID), 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, Sign(secret, ts, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	answer, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	res := &Result{StatusCode: resp.StatusCode, Body: string(answer)}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return res, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return res, nil
+}
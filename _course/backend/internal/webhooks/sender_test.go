@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"podcast-backend/internal/netguard"
+)
+
+func TestSenderRefusesLoopback(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer srv.Close()
+
+	_, err := NewSender().Send(context.Background(), srv.URL, "secret", "episode_created", 1, []byte(`{}`))
+	if !errors.Is(err, netguard.ErrPrivateAddress) {
+		t.Errorf("Send to %s: err = %v, want %v", srv.URL, err, netguard.ErrPrivateAddress)
+	}
+	if called {
+		t.Error("receiver on loopback was called")
+	}
+}
+
+func TestVerifyTolerance(t *testing.T) {
+	body := []byte(`{"event":"episode_created"}`)
+	sent := time.Unix(1700000000, 0)
+	h := http.Header{}
+	h.Set(TimestampHeader, "1700000000")
+	h.Set(SignatureHeader, Sign("secret", sent.Unix(), body))
+
+	if err := Verify("secret", h, body, 5*time.Minute, sent.Add(time.Minute)); err != nil {
+		t.Errorf("within tolerance: %v", err)
+	}
+	if err := Verify("secret", h, body, 5*time.Minute, sent.Add(10*time.Minute)); err != ErrInvalidSignature {
+		t.Errorf("replayed late: err = %v, want %v", err, ErrInvalidSignature)
+	}
+	h.Set(TimestampHeader, "1700000001")
+	if err := Verify("secret", h, body, 5*time.Minute, sent); err != ErrInvalidSignature {
+		t.Errorf("changed timestamp: err = %v, want %v", err, ErrInvalidSignature)
+	}
+}
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -15,11 +17,14 @@ import (
 	"podcast-backend/internal/config"
 	"podcast-backend/internal/db"
 	"podcast-backend/internal/events"
+	"podcast-backend/internal/feed"
 	"podcast-backend/internal/handlers"
+	"podcast-backend/internal/jobs"
 	"podcast-backend/internal/middleware"
-	"podcast-backend/internal/models"
 	"podcast-backend/internal/repository"
 	"podcast-backend/internal/seed"
+	"podcast-backend/internal/storage"
+	"podcast-backend/internal/webhooks"
 )
 
 func main() {
@@ -28,7 +33,10 @@ func main() {
 
 	// DB
 	pg := db.Connect(cfg.PostgresURL)
-	if err := pg.AutoMigrate(&models.User{}, &models.Podcast{}, &models.Episode{}, &models.EpisodeLike{}, &models.Favorite{}, &models.LibraryItem{}); err != nil {
+	if err := db.AutoMigrate(pg); err != nil {
+		log.Fatalf("failed to migrate: %v", err)
+	}
+	if err := db.Migrate(pg); err != nil {
 		log.Fatalf("failed to migrate: %v", err)
 	}
 	seed.Run(pg)
@@ -49,11 +57,64 @@ func main() {
 	podcastRepo := repository.NewPodcastRepository(pg, redisClient)
 	userRepo := repository.NewUserRepository(pg)
 	contentRepo := repository.NewUserContentRepository(pg)
-	episodeRepo := repository.NewEpisodeRepository(pg)
+	episodeRepo := repository.NewEpisodeRepository(pg, redisClient)
+	outboxRepo := repository.NewOutboxRepository(pg)
+	webhookRepo := repository.NewWebhookRepository(pg)
 	jwtService := auth.NewJWTService(getJWTSecret(), 72*time.Hour)
-	eventsHub := events.NewHub(jwtService)
+	// SSE events: shared replay stream and fan-out between instances with Redis
+	var eventStore events.Store = events.NewMemoryStore(cfg.EventsReplaySize)
+	var eventBroker events.Broker
+	if redisClient != nil {
+		eventStore = events.NewRedisStore(redisClient, "events:stream", cfg.EventsReplaySize)
+		eventBroker = events.NewRedisBroker(redisClient, "events:fanout")
+	}
+	eventsHub := events.NewHub(jwtService, eventStore, eventBroker)
+	feedFetcher := feed.NewFetcher()
+
+	// Media storage
+	mediaStore, err := storage.New(storage.Config{
+		Driver:      cfg.StorageDriver,
+		LocalDir:    cfg.StorageDir,
+		S3Endpoint:  cfg.S3Endpoint,
+		S3Region:    cfg.S3Region,
+		S3Bucket:    cfg.S3Bucket,
+		S3AccessKey: cfg.S3AccessKey,
+		S3SecretKey: cfg.S3SecretKey,
+		S3UseSSL:    cfg.S3UseSSL,
+	})
+	if err != nil {
+		log.Fatalf("failed to init storage: %v", err)
+	}
+
+	// Background jobs
+	go eventsHub.Run(context.Background())
+	webhookDispatcher := jobs.NewWebhookDispatcher(webhookRepo, webhooks.NewSender())
+	go webhookDispatcher.Run(context.Background())
+	// webhooks first: if queueing their deliveries fails, SSE clients get no duplicate on retry
+	go jobs.NewOutboxDispatcher(outboxRepo, cfg.OutboxPollInterval, webhookDispatcher, eventsHub).Run(context.Background())
+	go jobs.NewFeedRefresher(podcastRepo, feedFetcher, cfg.FeedRefreshInterval).Run(context.Background())
+	waveforms := jobs.NewWaveformGenerator(episodeRepo, mediaStore)
+	go waveforms.Run(context.Background())
+	go jobs.NewSearchTermsRefresher(podcastRepo, cfg.SearchTermsInterval).Run(context.Background())
+	go jobs.NewChartBuilder(podcastRepo, cfg.ChartsInterval).Run(context.Background())
+	go jobs.NewRecommendationBuilder(podcastRepo, cfg.RecommendationsInterval).Run(context.Background())
+	publisher := jobs.NewEpisodePublisher(podcastRepo, cfg.PublishCheckInterval)
+	go publisher.Run(context.Background())
+	if redisClient != nil {
+		go jobs.NewProgressFlusher(podcastRepo, cfg.ProgressFlushInterval).Run(context.Background())
+		go jobs.NewPlayEventFlusher(podcastRepo, cfg.PlayFlushInterval).Run(context.Background())
+	}
+
+	audioHandler := handlers.NewAudioHandler(episodeRepo, mediaStore, waveforms, cfg.MaxAudioSize)
+	webhookHandler := handlers.NewWebhookHandler(webhookRepo, webhookDispatcher)
 
-	router := setupRouter(podcastRepo, userRepo, contentRepo, episodeRepo, jwtService, eventsHub)
+	router := setupRouter(cfg, podcastRepo, userRepo, contentRepo, episodeRepo, jwtService, eventsHub, feedFetcher, audioHandler, webhookHandler, publisher)
+
+	// expvar counters, including SSE delivery metrics, on a listener of their own so
+	// they are only reachable where DEBUG_ADDR is (e.g. 127.0.0.1:6060)
+	if cfg.DebugAddr != "" {
+		go serveDebug(cfg.DebugAddr)
+	}
 
 	addr := ":" + cfg.Port
 	log.Printf("starting server on %s", addr)
@@ -62,13 +123,26 @@ func main() {
 	}
 }
 
-func setupRouter(podcastRepo *repository.PodcastRepository, userRepo *repository.UserRepository, contentRepo *repository.UserContentRepository, episodeRepo *repository.EpisodeRepository, jwtService *auth.JWTService, eventsHub *events.Hub) *gin.Engine {
+func setupRouter(cfg config.Config, podcastRepo *repository.PodcastRepository, userRepo *repository.UserRepository, contentRepo *repository.UserContentRepository, episodeRepo *repository.EpisodeRepository, jwtService *auth.JWTService, eventsHub *events.Hub, feedFetcher *feed.Fetcher, audioHandler *handlers.AudioHandler, webhookHandler *handlers.WebhookHandler, publisher *jobs.EpisodePublisher) *gin.Engine {
 	r := gin.Default()
+	// ClientIP keys play analytics: only proxies we run may speak for the client
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	// feeds are cached publicly, so their links are built on the configured origin,
+	// never on forwarded headers any client may send
+	if cfg.PublicURL != "" {
+		if u, err := url.Parse(cfg.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Fatalf("invalid PUBLIC_URL %q", cfg.PublicURL)
+		}
+		r.Use(middleware.PublicURL(cfg.PublicURL))
+	}
 
 	r.Use(cors.New(cors.Config{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders: []string{"Origin", "Content-Type", "Authorization"},
+		AllowOrigins:  []string{"*"},
+		AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:  []string{"Origin", "Content-Type", "Authorization"},
+		ExposeHeaders: []string{"X-Next-Cursor", "X-Did-You-Mean"},
 	}))
 
 	// Health root
@@ -82,9 +156,12 @@ func setupRouter(podcastRepo *repository.PodcastRepository, userRepo *repository
 	authHandler := handlers.NewAuthHandler(userRepo, jwtService)
 	authHandler.Register(r)
 
-	podcastHandler := handlers.NewPodcastHandler(podcastRepo)
+	podcastHandler := handlers.NewPodcastHandler(podcastRepo, feedFetcher, publisher, cfg.FeedLanguage)
 	contentHandler := handlers.NewUserContentHandler(contentRepo)
-	episodeHandler := handlers.NewEpisodeHandler(episodeRepo, eventsHub)
+	episodeHandler := handlers.NewEpisodeHandler(episodeRepo, publisher)
+	progressHandler := handlers.NewProgressHandler(podcastRepo)
+	recommendationHandler := handlers.NewRecommendationHandler(podcastRepo)
+	analyticsHandler := handlers.NewAnalyticsHandler(podcastRepo)
 
 	// Protected routes
 	protected := r.Group("/")
@@ -93,24 +170,43 @@ func setupRouter(podcastRepo *repository.PodcastRepository, userRepo *repository
 		api := protected.Group("/api")
 		{
 			api.POST("/podcasts", podcastHandler.Create)
+			api.POST("/podcasts/import", podcastHandler.Import)
 			api.PUT("/podcasts/:id", podcastHandler.Update)
 			api.DELETE("/podcasts/:id", podcastHandler.Delete)
 			api.POST("/podcasts/:id/episodes", podcastHandler.AddEpisode)
+			api.GET("/podcasts/:id/feed-status", podcastHandler.FeedStatus)
 
 			// routes under /api/episodes..., /api/me/episode-likes
 			episodeHandler.Register(api)
+			api.POST("/episodes/:id/audio", audioHandler.Upload)
+			// author webhooks under /api/podcasts/:id/webhooks and /api/webhooks/...
+			webhookHandler.Register(api)
 
 			// user-specific content routes under /api/...
 			contentHandler.Register(protected)
+			progressHandler.Register(protected)
+			recommendationHandler.Register(protected)
+			analyticsHandler.Register(protected)
 		}
 	}
 
 	// Public routes
-	podcastHandler.Register(r)
+	podcastHandler.Register(r.Group("/", middleware.AuthOptional(jwtService)))
+	r.POST("/api/events/play", middleware.AuthOptional(jwtService), analyticsHandler.Ingest)
+	audioHandler.Register(r.Group("/", middleware.AuthOptional(jwtService)))
 
 	return r
 }
 
+func serveDebug(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	log.Printf("debug listener on %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("debug listener: %v", err)
+	}
+}
+
 func pingRedis(client *redis.Client) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
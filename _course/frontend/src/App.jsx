@@ -1,4 +1,4 @@
-import { useState, useEffect } from 'react'
+import { useState, useEffect, useCallback, useRef } from 'react'
 import { AuthProvider, useAuth } from './contexts/AuthContext'
 import Header from './components/Header/Header'
 import Sidebar from './components/Sidebar/Sidebar'
@@ -26,6 +26,9 @@ function AppContent() {
   const [tabs, setTabs] = useState([HOME_TAB])
   const [activeTabId, setActiveTabId] = useState('home')
   const [podcasts, setPodcasts] = useState([])
+  const [podcastsCursor, setPodcastsCursor] = useState(null) // курсор следующей страницы каталога
+  const loadingMoreRef = useRef(false)
+  const [myPodcasts, setMyPodcasts] = useState([])
   const [favorites, setFavorites] = useState([])
   const [library, setLibrary] = useState([])
   const [likedEpisodeIds, setLikedEpisodeIds] = useState([])
@@ -53,13 +56,16 @@ function AppContent() {
   useEffect(() => {
     const loadData = async () => {
       try {
-        const [all, favs, lib, likedEps] = await Promise.all([
-          api.getAllPodcasts(),
+        const [firstPage, mine, favs, lib, likedEps] = await Promise.all([
+          api.getPodcastsPage(),
+          api.getMyPodcasts(user.id),
           api.getFavorites(),
           api.getLibrary(),
           api.getEpisodeLikes(),
         ])
-        setPodcasts(all || [])
+        setPodcasts(firstPage.items)
+        setPodcastsCursor(firstPage.next)
+        setMyPodcasts(mine || [])
         setFavorites(favs || [])
         setLibrary(lib || [])
         setLikedEpisodeIds(likedEps || [])
@@ -70,6 +76,24 @@ function AppContent() {
     if (user) loadData()
   }, [user])
 
+  // следующая страница каталога, по мере прокрутки главной
+  const loadMorePodcasts = useCallback(async () => {
+    if (!podcastsCursor || loadingMoreRef.current) return
+    loadingMoreRef.current = true
+    try {
+      const page = await api.getPodcastsPage(podcastsCursor)
+      setPodcasts((prev) => {
+        const known = new Set(prev.map((p) => p.id))
+        return [...prev, ...page.items.filter((p) => !known.has(p.id))]
+      })
+      setPodcastsCursor(page.next)
+    } catch (e) {
+      console.error('Load podcasts page error', e)
+    } finally {
+      loadingMoreRef.current = false
+    }
+  }, [podcastsCursor])
+
   // SSE события для моментального обновления (лайки эпизодов и т.п.)
   useEffect(() => {
     if (!user) {
@@ -92,42 +116,37 @@ function AppContent() {
     if (!token) return
 
     const src = new EventSource(`${import.meta.env.VITE_API_URL || 'http://localhost:8080/api'}/events?token=${encodeURIComponent(token)}`)
-    src.onmessage = (event) => {
+    src.addEventListener('episode_likes', (event) => {
       try {
-        const payload = JSON.parse(event.data)
-        if (payload.type === 'episode_likes') {
-          const { episodeId, likes } = payload.data || {}
-          if (!episodeId) return
-          setPodcasts((prevPods) => {
-            const updatedPods = prevPods.map((p) => ({
-              ...p,
-              episodes: (p.episodes || []).map((ep) =>
-                ep.id === episodeId ? { ...ep, likes } : ep
-              ),
-            }))
-            // синхронизируем подкасты во вкладках, в том числе закреплённых
-            setTabs((prevTabs) =>
-              prevTabs.map((tab) =>
-                tab.type === 'podcast'
-                  ? {
-                      ...tab,
-                      podcast:
-                        updatedPods.find((p) => p.id === tab.podcast.id) ||
-                        tab.podcast,
-                    }
-                  : tab
-              )
+        const { episodeId, likes } = JSON.parse(event.data).data || {}
+        if (!episodeId) return
+        setPodcasts((prevPods) => {
+          const updatedPods = prevPods.map((p) => ({
+            ...p,
+            episodes: (p.episodes || []).map((ep) =>
+              ep.id === episodeId ? { ...ep, likes } : ep
+            ),
+          }))
+          // синхронизируем подкасты во вкладках, в том числе закреплённых
+          setTabs((prevTabs) =>
+            prevTabs.map((tab) =>
+              tab.type === 'podcast'
+                ? {
+                    ...tab,
+                    podcast:
+                      updatedPods.find((p) => p.id === tab.podcast.id) ||
+                      tab.podcast,
+                  }
+                : tab
             )
-            return updatedPods
-          })
-        }
+          )
+          return updatedPods
+        })
       } catch {
         // игнорируем некорректные события
       }
-    }
-    src.onerror = () => {
-      src.close()
-    }
+    })
+    // без onerror: браузер сам переподключится и передаст Last-Event-ID
     setEventsSource(src)
 
     return () => {
@@ -392,6 +411,9 @@ function AppContent() {
         setPodcasts((prev) =>
           prev.map((p) => (p.id === podcastId ? updated : p))
         )
+        setMyPodcasts((prev) =>
+          prev.map((p) => (p.id === podcastId ? updated : p))
+        )
         setTabs((prevTabs) =>
           prevTabs.map((tab) =>
             tab.type === 'podcast' && tab.podcast.id === podcastId
@@ -522,6 +544,7 @@ function AppContent() {
 
   const handlePodcastCreated = (newPodcast) => {
     setPodcasts([...podcasts, newPodcast])
+    setMyPodcasts((prev) => [...prev, newPodcast])
     const newTabs = tabs.filter(tab => tab.id !== 'create-podcast')
     const tabId = `podcast-${newPodcast.id}`
     const newTab = {
@@ -591,6 +614,8 @@ function AppContent() {
           <PodcastList
             podcasts={podcasts}
             onPodcastSelect={handlePodcastSelect}
+            hasMore={Boolean(podcastsCursor)}
+            onLoadMore={loadMorePodcasts}
           />
         )
       case 'search':
@@ -623,7 +648,7 @@ function AppContent() {
       case 'mypodcasts':
         return (
           <MyPodcasts
-            podcasts={podcasts.filter((p) => user && p.authorId === user.id)}
+            podcasts={myPodcasts}
             onPodcastSelect={handlePodcastSelect}
           />
         )
@@ -1,7 +1,24 @@
+import { useEffect, useRef } from 'react'
 import PodcastCard from '../PodcastCard/PodcastCard'
 import './PodcastList.css'
 
-function PodcastList({ podcasts, onPodcastSelect }) {
+function PodcastList({ podcasts, onPodcastSelect, hasMore = false, onLoadMore }) {
+  const sentinelRef = useRef(null)
+
+  // подгружаем следующую страницу, когда конец списка приближается к экрану
+  useEffect(() => {
+    const sentinel = sentinelRef.current
+    if (!sentinel || !hasMore || !onLoadMore) return
+    const observer = new IntersectionObserver(
+      (entries) => {
+        if (entries.some((entry) => entry.isIntersecting)) onLoadMore()
+      },
+      { rootMargin: '400px' }
+    )
+    observer.observe(sentinel)
+    return () => observer.disconnect()
+  }, [hasMore, onLoadMore, podcasts.length])
+
   return (
     <div className="podcast-list">
       <div className="podcast-list-header">
@@ -16,9 +33,9 @@ function PodcastList({ podcasts, onPodcastSelect }) {
           />
         ))}
       </div>
+      {hasMore && <div ref={sentinelRef} className="podcast-list-sentinel" />}
     </div>
   )
 }
 
 export default PodcastList
-
@@ -6,34 +6,48 @@ import './Search.css'
 function Search({ podcasts, onPodcastSelect }) {
   const [searchQuery, setSearchQuery] = useState('')
   const [searchResults, setSearchResults] = useState([])
+  const [didYouMean, setDidYouMean] = useState(null)
   const [isSearching, setIsSearching] = useState(false)
 
-  const handleSearch = async (e) => {
-    e.preventDefault()
-    if (!searchQuery.trim()) {
+  const runSearch = async (query) => {
+    if (!query.trim()) {
       setSearchResults([])
+      setDidYouMean(null)
       return
     }
 
     setIsSearching(true)
     try {
-      const results = await api.searchPodcasts(searchQuery)
+      const { results, didYouMean } = await api.searchPodcasts(query)
       setSearchResults(results)
+      setDidYouMean(didYouMean)
     } catch (error) {
       console.error('Ошибка поиска:', error)
       setSearchResults([])
+      setDidYouMean(null)
     } finally {
       setIsSearching(false)
     }
   }
 
+  const handleSearch = (e) => {
+    e.preventDefault()
+    runSearch(searchQuery)
+  }
+
+  const applySuggestion = () => {
+    setSearchQuery(didYouMean)
+    runSearch(didYouMean)
+  }
+
   const handleInputChange = (e) => {
     const value = e.target.value
     setSearchQuery(value)
     if (value.trim()) {
-      handleSearch(e)
+      runSearch(value)
     } else {
       setSearchResults([])
+      setDidYouMean(null)
     }
   }
 
@@ -56,7 +70,15 @@ function Search({ podcasts, onPodcastSelect }) {
       </div>
       <div className="search-results">
         {searchQuery && searchResults.length === 0 && !isSearching && (
-          <div className="search-empty">Ничего не найдено</div>
+          <div className="search-empty">
+            Ничего не найдено
+            {didYouMean && (
+              <div className="search-did-you-mean">
+                Возможно, вы имели в виду{' '}
+                <button type="button" onClick={applySuggestion}>{didYouMean}</button>?
+              </div>
+            )}
+          </div>
         )}
         {searchResults.length > 0 && (
           <>
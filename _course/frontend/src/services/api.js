@@ -1,8 +1,10 @@
 const API_BASE_URL = import.meta.env.VITE_API_URL || 'http://localhost:8080/api'
 
+const PODCASTS_PAGE_SIZE = 24
+
 let authToken = null
 
-const request = async (path, options = {}) => {
+const send = async (path, options = {}) => {
   const headers = { 'Content-Type': 'application/json', ...(options.headers || {}) }
   if (authToken) headers.Authorization = `Bearer ${authToken}`
   const res = await fetch(`${API_BASE_URL}${path}`, { ...options, headers })
@@ -17,7 +19,22 @@ const request = async (path, options = {}) => {
     const error = data?.error || res.statusText
     throw new Error(error)
   }
-  return data
+  return { data, next: res.headers.get('X-Next-Cursor'), didYouMean: res.headers.get('X-Did-You-Mean') }
+}
+
+const request = async (path, options = {}) => (await send(path, options)).data
+
+// requestAll follows X-Next-Cursor through every page of a list endpoint.
+const requestAll = async (path) => {
+  const sep = path.includes('?') ? '&' : '?'
+  const items = []
+  let cursor = null
+  do {
+    const page = await send(cursor ? `${path}${sep}cursor=${encodeURIComponent(cursor)}` : path)
+    items.push(...(page.data || []))
+    cursor = page.next
+  } while (cursor)
+  return items
 }
 
 export const api = {
@@ -31,9 +48,21 @@ export const api = {
     request('/auth/register', { method: 'POST', body: JSON.stringify({ name, email, password }) }),
 
   // Podcasts
-  getAllPodcasts: async () => request('/podcasts'),
+  // getPodcastsPage resolves to { items, next }: one page of the catalogue without
+  // episodes, which getPodcastById loads when a podcast is opened
+  getPodcastsPage: async (cursor) => {
+    const query = cursor ? `&cursor=${encodeURIComponent(cursor)}` : ''
+    const page = await send(`/podcasts?limit=${PODCASTS_PAGE_SIZE}${query}`)
+    return { items: page.data || [], next: page.next }
+  },
+  // getMyPodcasts lists the caller's podcasts, drafts included
+  getMyPodcasts: async (userId) => requestAll(`/podcasts?authorId=${userId}&limit=100`),
   getPodcastById: async (id) => request(`/podcasts/${id}`),
-  searchPodcasts: async (query) => request(`/podcasts/search?q=${encodeURIComponent(query)}`),
+  // searchPodcasts resolves to { results, didYouMean }; didYouMean is set when nothing matched
+  searchPodcasts: async (query) => {
+    const page = await send(`/podcasts/search?q=${encodeURIComponent(query)}&include=episodes`)
+    return { results: page.data || [], didYouMean: page.didYouMean }
+  },
   createPodcast: async (data) => request('/podcasts', { method: 'POST', body: JSON.stringify(data) }),
   updatePodcast: async (id, data) =>
     request(`/podcasts/${id}`, { method: 'PUT', body: JSON.stringify(data) }),